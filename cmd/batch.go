@@ -163,10 +163,10 @@ func calculateScoreFromResult(result map[string]interface{}) *scoring.ScoreResul
 		return nil
 	}
 
-	meanMs, ok1 := latencyStats["meanMs"].(float64)
-	stdMs, ok2 := latencyStats["stdMs"].(float64)
-	p95Ms, ok3 := latencyStats["p95Ms"].(float64)
-	p50Ms, ok4 := latencyStats["p50Ms"].(float64)
+	meanMs, ok1 := latencyMs(latencyStats, "mean")
+	stdMs, ok2 := latencyMs(latencyStats, "std")
+	p95Ms, ok3 := latencyMs(latencyStats, "p95")
+	p50Ms, ok4 := latencyMs(latencyStats, "p50")
 
 	if !ok1 || !ok2 || !ok3 || !ok4 {
 		return nil
@@ -180,10 +180,10 @@ func calculateScoreFromResult(result map[string]interface{}) *scoring.ScoreResul
 		TotalIOErrors:         int64(totalIOError),
 		QueriesPerSecond:      qps,
 		LatencyStats: scoring.LatencyMetrics{
-			MeanMs: int64(meanMs),
-			StdMs:  int64(stdMs),
-			P95Ms:  int64(p95Ms),
-			P50Ms:  int64(p50Ms),
+			MeanMs: meanMs,
+			StdMs:  stdMs,
+			P95Ms:  p95Ms,
+			P50Ms:  p50Ms,
 		},
 	}
 
@@ -192,6 +192,16 @@ func calculateScoreFromResult(result map[string]interface{}) *scoring.ScoreResul
 	return &scoreResult
 }
 
+// latencyMs returns the latency statistic in milliseconds, the latency in microseconds (schema version 2) is preferred over
+// the latency rounded to milliseconds.
+func latencyMs(latencyStats map[string]interface{}, stat string) (float64, bool) {
+	if us, ok := latencyStats[stat+"Us"].(float64); ok {
+		return us / 1000, true
+	}
+	ms, ok := latencyStats[stat+"Ms"].(float64)
+	return ms, ok
+}
+
 // writeResultsToFile writes the batch results to a JSON file
 func writeResultsToFile(results BatchResult, outputPath string) error {
 	// Ensure the output file has .json extension
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
+)
+
+// runCompare compares JSON results of the baseline and current runs and prints the comparison. Returns true, if no regression
+// threshold was exceeded.
+func runCompare(baselinePath, currentPath string) (bool, error) {
+	thresholds, err := reporter.ParseRegressionThresholds(*compareMaxRegressions)
+	if err != nil {
+		return false, err
+	}
+	baseline, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	current, err := os.ReadFile(currentPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read current results: %w", err)
+	}
+
+	c, err := reporter.Compare(baseline, current, reporter.CompareOptions{
+		MaxRegressions:    thresholds,
+		Alpha:             *compareAlpha,
+		FailOnSignificant: *compareFailSignificant,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case benchmark.Silent:
+	case benchmark.JSON || benchmark.Format == dnsbench.FormatJSON:
+		if err := json.NewEncoder(os.Stdout).Encode(c); err != nil {
+			return false, err
+		}
+	default:
+		reporter.PrintComparison(os.Stdout, c)
+	}
+	return c.Passed, nil
+}
@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFlag   = "config"
+	profileFlag  = "profile"
+	queriesArg   = "queries"
+	profilesKey  = "profiles"
+	envDefaultOp = ":-"
+)
+
+var (
+	// configFileValues are the values loaded from the configuration file.
+	configFileValues map[string][]string
+	// commandLineValues are the values of the configurable flags and arguments provided on the command line.
+	commandLineValues map[string][]string
+	// originalDefaults are the defaults of the flags and arguments before they were replaced by the configured values.
+	originalDefaults = make(map[string][]string)
+)
+
+// cumulative is implemented by the values of the repeatable flags and arguments.
+type cumulative interface {
+	IsCumulative() bool
+}
+
+// applyConfigFile loads the configuration file and profile referenced by --config and --profile flags in the arguments and uses
+// the configured values as defaults of the corresponding flags, so the flags provided on the command line take precedence.
+// The effective configuration (configuration file merged with the command line) is stored into benchmark.Config, the environment
+// variables are not expanded in the stored configuration, so their values (e.g. secrets) do not leak into the outputs.
+func applyConfigFile(args []string) error {
+	// the arguments are parsed twice, first parsing only finds the configuration file, the values are set by the second parsing
+	parseCtx, _ := pApp.ParseContext(args)
+	if parseCtx == nil {
+		return nil
+	}
+	var path, profile string
+	for _, el := range parseCtx.Elements {
+		if flag, ok := el.Clause.(*kingpin.FlagClause); ok && el.Value != nil {
+			switch flag.Model().Name {
+			case configFlag:
+				path = *el.Value
+			case profileFlag:
+				profile = *el.Value
+			}
+		}
+	}
+	if len(path) == 0 && len(profile) != 0 {
+		return fmt.Errorf("--%s requires --%s", profileFlag, configFlag)
+	}
+
+	if len(path) != 0 {
+		values, err := loadConfig(path, profile)
+		if err != nil {
+			return err
+		}
+		for key, v := range expandValues(values) {
+			if err := setConfigDefault(key, v); err != nil {
+				return err
+			}
+		}
+		configFileValues = values
+	}
+	commandLineValues = parsedValues(parseCtx)
+	benchmark.Config = effectiveConfig(mergeValues(configFileValues, commandLineValues))
+	return nil
+}
+
+// loadConfig reads YAML or JSON configuration file and returns values of the flags keyed by the flag name. The values
+// of the profile are merged over the top level values. The environment variables in the values are not expanded.
+func loadConfig(path, profile string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	// JSON is a subset of YAML, so both formats are parsed by YAML parser
+	var raw configMap
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%s': %w", path, err)
+	}
+
+	profiles, err := configProfiles(raw[profilesKey])
+	if err != nil {
+		return nil, err
+	}
+	delete(raw, profilesKey)
+
+	values, err := configValues(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(profile) == 0 {
+		return values, nil
+	}
+	p, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile '%s' not found in config file '%s', available profiles: %s", profile, path, strings.Join(profileNames(profiles), ", "))
+	}
+	profileValues, err := configValues(p)
+	if err != nil {
+		return nil, fmt.Errorf("profile '%s': %w", profile, err)
+	}
+	for k, v := range profileValues {
+		values[k] = v
+	}
+	return values, nil
+}
+
+func configProfiles(raw interface{}) (map[string]map[string]interface{}, error) {
+	profiles := make(map[string]map[string]interface{})
+	if raw == nil {
+		return profiles, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'%s' has to be a mapping of profile names to configurations", profilesKey)
+	}
+	for name, p := range m {
+		pm, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile '%s' has to be a mapping of flag names to values", name)
+		}
+		profiles[name] = pm
+	}
+	return profiles, nil
+}
+
+func profileNames(profiles map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configMap is mapping of the configuration file, which keeps the original text of the YAML scalars, so for example
+// version 1.0 is not converted to 1 by parsing it as a float.
+type configMap map[string]interface{}
+
+// rawScalar is the original text of not string YAML scalar (number or boolean).
+type rawScalar string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (m *configMap) UnmarshalYAML(node *yaml.Node) error {
+	v, err := yamlNodeValue(node)
+	if err != nil {
+		return err
+	}
+	mapping, ok := v.(map[string]interface{})
+	if v != nil && !ok {
+		return fmt.Errorf("line %d: config has to be a mapping of flag names to values", node.Line)
+	}
+	*m = mapping
+	return nil
+}
+
+// yamlNodeValue converts YAML node to maps, lists, strings and raw scalars.
+func yamlNodeValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeValue(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := yamlNodeValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, n := range node.Content {
+			v, err := yamlNodeValue(n)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	default:
+		switch node.ShortTag() {
+		case "!!str":
+			return node.Value, nil
+		case "!!null":
+			return nil, nil
+		default:
+			return rawScalar(node.Value), nil
+		}
+	}
+}
+
+// configValues converts the configured scalars and lists of scalars to the string values of the flags, the environment
+// variables are expanded later by expandValues.
+func configValues(raw map[string]interface{}) (map[string][]string, error) {
+	values := make(map[string][]string, len(raw))
+	for key, v := range raw {
+		if key == configFlag || key == profileFlag {
+			return nil, fmt.Errorf("'%s' cannot be set in the config file", key)
+		}
+		switch val := v.(type) {
+		case []interface{}:
+			list := make([]string, 0, len(val))
+			for _, item := range val {
+				s, err := configScalar(key, item)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, s)
+			}
+			values[key] = list
+		default:
+			s, err := configScalar(key, val)
+			if err != nil {
+				return nil, err
+			}
+			if negated, ok := negatedBoolFlag(key); ok {
+				// negated boolean flags like no-distribution are stored as the value of the flag they negate
+				b, err := strconv.ParseBool(expandEnv(s))
+				if err != nil {
+					return nil, fmt.Errorf("value of '%s' has to be a boolean", key)
+				}
+				key, s = negated, strconv.FormatBool(!b)
+			}
+			values[key] = []string{s}
+		}
+	}
+	return values, nil
+}
+
+// negatedBoolFlag returns name of the boolean flag negated by the key with no- prefix.
+func negatedBoolFlag(key string) (string, bool) {
+	name, ok := strings.CutPrefix(key, "no-")
+	if !ok || configurableFlag(key) != nil {
+		return "", false
+	}
+	flag := configurableFlag(name)
+	return name, flag != nil && flag.Model().IsBoolFlag()
+}
+
+func configScalar(key string, v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case rawScalar:
+		return string(val), nil
+	case float64:
+		// numbers of the JSON configuration distributed to the agents
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case bool, int, int64, uint64:
+		return fmt.Sprint(val), nil
+	default:
+		return "", fmt.Errorf("value of '%s' has to be a scalar or a list of scalars", key)
+	}
+}
+
+// expandValues returns copy of the values with expanded environment variables.
+func expandValues(values map[string][]string) map[string][]string {
+	expanded := make(map[string][]string, len(values))
+	for k, v := range values {
+		list := make([]string, 0, len(v))
+		for _, s := range v {
+			list = append(list, expandEnv(s))
+		}
+		expanded[k] = list
+	}
+	return expanded
+}
+
+// expandEnv replaces $VAR and ${VAR} with the value of the environment variable, ${VAR:-default} is replaced by
+// the default value, when the variable is not set or empty.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if i := strings.Index(name, envDefaultOp); i >= 0 {
+			if v := os.Getenv(name[:i]); len(v) != 0 {
+				return v
+			}
+			return name[i+len(envDefaultOp):]
+		}
+		return os.Getenv(name)
+	})
+}
+
+// setConfigDefault sets the configured values as defaults of the benchmark flag, global flag or queries argument.
+func setConfigDefault(key string, values []string) error {
+	if key == queriesArg {
+		arg := benchmarkCmd.GetArg(queriesArg)
+		if _, ok := originalDefaults[key]; !ok {
+			originalDefaults[key] = arg.Model().Default
+		}
+		arg.Default(values...)
+		return nil
+	}
+	flag := configurableFlag(key)
+	if flag == nil {
+		return fmt.Errorf("unknown key '%s' in config file, the keys have to be names of the benchmark flags or '%s'", key, queriesArg)
+	}
+	if c, ok := flag.Model().Value.(cumulative); (!ok || !c.IsCumulative()) && len(values) > 1 {
+		return fmt.Errorf("'%s' is not a repeatable flag, it cannot be set to a list of values", key)
+	}
+	if _, ok := originalDefaults[key]; !ok {
+		originalDefaults[key] = flag.Model().Default
+	}
+	flag.Default(values...)
+	return nil
+}
+
+// restoreDefaults restores the original defaults of all the flags and arguments changed by setConfigDefault.
+func restoreDefaults() {
+	for key, values := range originalDefaults {
+		if key == queriesArg {
+			benchmarkCmd.GetArg(queriesArg).Default(values...)
+		} else {
+			configurableFlag(key).Default(values...)
+		}
+	}
+}
+
+// configurableFlag returns benchmark or global flag, which can be configured in the config file, nil is returned for other flags.
+func configurableFlag(name string) *kingpin.FlagClause {
+	switch name {
+	case configFlag, profileFlag, "help", "version":
+		return nil
+	}
+	if flag := benchmarkCmd.GetFlag(name); flag != nil {
+		return flag
+	}
+	return pApp.GetFlag(name)
+}
+
+// parsedValues returns values of the configurable flags and queries argument found by parsing the command line.
+func parsedValues(parseCtx *kingpin.ParseContext) map[string][]string {
+	values := make(map[string][]string)
+	for _, el := range parseCtx.Elements {
+		if el.Value == nil {
+			continue
+		}
+		switch clause := el.Clause.(type) {
+		case *kingpin.FlagClause:
+			name := clause.Model().Name
+			if configurableFlag(name) != nil {
+				values[name] = append(values[name], *el.Value)
+			}
+		case *kingpin.ArgClause:
+			if clause.Model().Name == queriesArg {
+				values[queriesArg] = append(values[queriesArg], *el.Value)
+			}
+		}
+	}
+	return values
+}
+
+// mergeValues merges the layers of values, the values of the later layers take precedence. Repeatable flags are not merged,
+// the later layer replaces the whole list.
+func mergeValues(layers ...map[string][]string) map[string][]string {
+	merged := make(map[string][]string)
+	for _, layer := range layers {
+		for k, v := range layer {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// effectiveConfig converts the values to the format of the configuration file.
+func effectiveConfig(values map[string][]string) map[string]interface{} {
+	config := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if isCumulative(k) {
+			config[k] = v
+		} else {
+			// the last occurrence of not repeatable flag wins
+			config[k] = v[len(v)-1]
+		}
+	}
+	return config
+}
+
+func isCumulative(key string) bool {
+	if key == queriesArg {
+		return true
+	}
+	c, ok := configurableFlag(key).Model().Value.(cumulative)
+	return ok && c.IsCumulative()
+}
+
+// parseBenchmark returns benchmark configured by the configured values merged with the command line values as if they were
+// provided on the command line of the benchmark command. The environment variables are expanded only in the configured values
+// and the effective configuration is stored without the expansion.
+func parseBenchmark(configured, commandLine map[string][]string) (dnsbench.Benchmark, error) {
+	restoreDefaults()
+	for key, v := range mergeValues(expandValues(configured), commandLine) {
+		if err := setConfigDefault(key, v); err != nil {
+			return dnsbench.Benchmark{}, err
+		}
+	}
+	// the flags are bound to the global benchmark, so it has to be reset before parsing, otherwise the repeatable flags would be appended
+	benchmark = dnsbench.Benchmark{Writer: os.Stdout}
+	if _, err := pApp.Parse([]string{benchmarkCmd.FullCommand()}); err != nil {
+		return dnsbench.Benchmark{}, err
+	}
+	benchmark.Config = effectiveConfig(mergeValues(configured, commandLine))
+	return benchmark, nil
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("DNSPYRE_TEST_SERVER", "10.0.0.1")
+	t.Setenv("DNSPYRE_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		config  string
+		profile string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:   "scalars and lists",
+			config: "server: 8.8.8.8\nconcurrency: 10\nrecurse: false\ntype: [A, AAAA]\nqueries: [google.com]\n",
+			want: map[string][]string{
+				"server":      {"8.8.8.8"},
+				"concurrency": {"10"},
+				"recurse":     {"false"},
+				"type":        {"A", "AAAA"},
+				"queries":     {"google.com"},
+			},
+		},
+		{
+			name:   "floats keep their text",
+			config: "tls-min-version: 1.0\nprobability: 0.50\n",
+			want: map[string][]string{
+				"tls-min-version": {"1.0"},
+				"probability":     {"0.50"},
+			},
+		},
+		{
+			name:   "JSON",
+			config: `{"server": "8.8.8.8", "tls-min-version": 1.0, "type": ["A"]}`,
+			want: map[string][]string{
+				"server":          {"8.8.8.8"},
+				"tls-min-version": {"1.0"},
+				"type":            {"A"},
+			},
+		},
+		{
+			name:   "environment variables are not expanded",
+			config: "server: ${DNSPYRE_TEST_SERVER}\nqueries: [\"${DNSPYRE_TEST_EMPTY:-google.com}\"]\n",
+			want: map[string][]string{
+				"server":  {"${DNSPYRE_TEST_SERVER}"},
+				"queries": {"${DNSPYRE_TEST_EMPTY:-google.com}"},
+			},
+		},
+		{
+			name:   "negated boolean flag",
+			config: "no-distribution: true\nno-color: ${DNSPYRE_TEST_EMPTY:-false}\n",
+			want: map[string][]string{
+				"distribution": {"false"},
+				"color":        {"true"},
+			},
+		},
+		{
+			name:    "profile is merged over top level values",
+			config:  "server: 8.8.8.8\nconcurrency: 10\nprofiles:\n  local:\n    server: 127.0.0.1\n    type: [TXT]\n",
+			profile: "local",
+			want: map[string][]string{
+				"server":      {"127.0.0.1"},
+				"concurrency": {"10"},
+				"type":        {"TXT"},
+			},
+		},
+		{
+			name:   "profiles are ignored without profile",
+			config: "server: 8.8.8.8\nprofiles:\n  local:\n    server: 127.0.0.1\n",
+			want: map[string][]string{
+				"server": {"8.8.8.8"},
+			},
+		},
+		{
+			name:    "unknown profile",
+			config:  "server: 8.8.8.8\nprofiles:\n  local:\n    server: 127.0.0.1\n",
+			profile: "remote",
+			wantErr: true,
+		},
+		{
+			name:    "profile is not a mapping",
+			config:  "profiles:\n  local: 127.0.0.1\n",
+			profile: "local",
+			wantErr: true,
+		},
+		{
+			name:    "negated flag is not a boolean",
+			config:  "no-distribution: maybe\n",
+			wantErr: true,
+		},
+		{
+			name:    "config is not a mapping",
+			config:  "- server\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dnspyre.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.config), 0o600))
+
+			got, err := loadConfig(path, tt.profile)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("DNSPYRE_TEST_SERVER", "10.0.0.1")
+	t.Setenv("DNSPYRE_TEST_EMPTY", "")
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "8.8.8.8", want: "8.8.8.8"},
+		{value: "$DNSPYRE_TEST_SERVER", want: "10.0.0.1"},
+		{value: "${DNSPYRE_TEST_SERVER}:53", want: "10.0.0.1:53"},
+		{value: "${DNSPYRE_TEST_SERVER:-8.8.8.8}", want: "10.0.0.1"},
+		{value: "${DNSPYRE_TEST_EMPTY:-8.8.8.8}", want: "8.8.8.8"},
+		{value: "${DNSPYRE_TEST_UNSET:-8.8.8.8}", want: "8.8.8.8"},
+		{value: "${DNSPYRE_TEST_UNSET}", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.want, expandEnv(tt.value))
+		})
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	got := effectiveConfig(mergeValues(
+		map[string][]string{"server": {"8.8.8.8"}, "type": {"A", "AAAA"}, "concurrency": {"10"}},
+		map[string][]string{"server": {"1.1.1.1", "9.9.9.9"}, "type": {"TXT"}, "queries": {"google.com", "example.com"}},
+	))
+
+	assert.Equal(t, map[string]interface{}{
+		"server":      "9.9.9.9",
+		"type":        []string{"TXT"},
+		"concurrency": "10",
+		"queries":     []string{"google.com", "example.com"},
+	}, got)
+}
+
+func TestParseBenchmark(t *testing.T) {
+	t.Setenv("DNSPYRE_TEST_SERVER", "10.0.0.1")
+	t.Cleanup(restoreDefaults)
+
+	b, err := parseBenchmark(
+		map[string][]string{"server": {"${DNSPYRE_TEST_SERVER}"}, "tls-min-version": {"1.0"}, "concurrency": {"10"}},
+		map[string][]string{"concurrency": {"20"}, "queries": {"google.com"}},
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", b.Server)
+	assert.Equal(t, "1.0", b.TLSMinVersion)
+	assert.Equal(t, uint32(20), b.Concurrency)
+	assert.Equal(t, []string{"google.com"}, b.Queries)
+	assert.Equal(t, map[string]interface{}{
+		"server":          "${DNSPYRE_TEST_SERVER}",
+		"tls-min-version": "1.0",
+		"concurrency":     "20",
+		"queries":         []string{"google.com"},
+	}, b.Config)
+}
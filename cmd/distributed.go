@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/distributed"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
+)
+
+// coordinatorFlags are the flags of the coordinator, which are not distributed to the agents.
+var coordinatorFlags = []string{"agents", "coordinator-listen", "coordinator-token", "start-delay", "agent-timeout"}
+
+// signalContext returns context canceled by SIGINT or SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
+// runCoordinator distributes the configured benchmark to the agents, waits for their results and prints the merged results.
+// The fail conditions and SLOs are evaluated by the coordinator against the merged results, false is returned, when any of them is violated.
+func runCoordinator(agents int, listen, token string, startDelay, agentTimeout time.Duration) (bool, error) {
+	config := make(map[string]interface{}, len(benchmark.Config))
+	for k, v := range benchmark.Config {
+		config[k] = v
+	}
+	for _, k := range coordinatorFlags {
+		delete(config, k)
+	}
+
+	if agentTimeout <= 0 && benchmark.Duration > 0 {
+		agentTimeout = benchmark.Duration + distributed.DefaultResultGracePeriod
+	}
+
+	c, err := distributed.NewCoordinator(distributed.CoordinatorConfig{
+		Addr: listen, Token: token, Agents: agents, Config: config, StartDelay: startDelay, ResultTimeout: agentTimeout,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := c.Start(); err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if !benchmark.Silent {
+		printutils.NeutralFprintf(os.Stderr, "Coordinator listening on %s, waiting for %s agents\n",
+			printutils.HighlightSprint(c.Addr), printutils.HighlightSprint(agents))
+	}
+	if len(token) == 0 && !isLoopback(c.Addr) {
+		printutils.ErrFprintf(os.Stderr, "Warning: coordinator listens on %s without --coordinator-token, anyone reaching it can register as an agent\n", c.Addr)
+	}
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-c.Ready():
+	}
+	if !benchmark.Silent {
+		printutils.NeutralFprintf(os.Stderr, "All agents registered, benchmark of %s starts at %s\n",
+			printutils.HighlightSprint(benchmark.Server), printutils.HighlightSprint(c.StartAt().Format(time.RFC3339)))
+	}
+
+	results, err := c.Wait(ctx)
+	if err != nil {
+		return false, err
+	}
+	outputs := make([][]byte, 0, len(results))
+	for _, r := range results {
+		if len(r.Error) != 0 {
+			printutils.ErrFprintf(os.Stderr, "Agent %s failed: %s\n", r.Name, r.Error)
+			continue
+		}
+		outputs = append(outputs, r.Output)
+	}
+	if len(outputs) == 0 {
+		return false, errors.New("all agents failed")
+	}
+
+	var merged bytes.Buffer
+	if err := reporter.MergeResults(&merged, outputs...); err != nil {
+		return false, err
+	}
+	switch {
+	case benchmark.Silent:
+	case benchmark.JSON || benchmark.Format == dnsbench.FormatJSON:
+		if _, err := os.Stdout.Write(merged.Bytes()); err != nil {
+			return false, err
+		}
+	default:
+		if err := reporter.PrintMergedResults(os.Stdout, merged.Bytes()); err != nil {
+			return false, err
+		}
+	}
+
+	suites, err := reporter.EvaluateMergedAssertions(&benchmark, merged.Bytes(), c.StartAt())
+	if err != nil {
+		return false, err
+	}
+	if len(benchmark.JUnit) != 0 {
+		if err := writeJUnitFile(benchmark.JUnit, suites); err != nil {
+			return false, fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+	}
+	passed := true
+	for _, s := range suites {
+		passed = passed && s.Passed()
+		switch {
+		case len(s.SLO) == 0:
+		case !benchmark.Silent && !benchmark.JSON && benchmark.Format == dnsbench.FormatStandard:
+			reporter.PrintThresholds(os.Stdout, s.SLO)
+		default:
+			for _, r := range s.SLO {
+				if !r.Passed {
+					printutils.ErrFprintf(os.Stderr, "SLO violated: %s\n", r.Explanation)
+				}
+			}
+		}
+	}
+
+	if failed := len(results) - len(outputs); failed > 0 {
+		return false, fmt.Errorf("%d of %d agents failed", failed, len(results))
+	}
+	return passed, nil
+}
+
+// writeJUnitFile writes the assertion suites in JUnit XML format to the file.
+func writeJUnitFile(path string, suites []reporter.AssertionSuite) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := reporter.WriteJUnit(f, suites); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isLoopback returns true, if the address listens only on loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runAgent registers the agent with the coordinator and runs the benchmark distributed by the coordinator.
+func runAgent(coordinator, name, token string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	printutils.NeutralFprintf(os.Stderr, "Registering with coordinator %s\n", printutils.HighlightSprint(coordinator))
+	a := distributed.Agent{Coordinator: coordinator, Name: name, Token: token}
+	return a.Run(ctx, runAgentJob)
+}
+
+// runAgentJob runs the benchmark configured by the coordinator and returns its JSON output.
+func runAgentJob(ctx context.Context, job distributed.Job) (json.RawMessage, error) {
+	values, err := configValues(job.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid benchmark config: %w", err)
+	}
+	b, err := parseBenchmark(values, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid benchmark config: %w", err)
+	}
+	printutils.NeutralFprintf(os.Stderr, "Running benchmark of %s together with %s agents\n",
+		printutils.HighlightSprint(b.Server), printutils.HighlightSprint(job.Agents))
+
+	// the results are merged by the coordinator, so the agent reports only JSON and the local outputs are disabled,
+	// the fail conditions and SLOs are evaluated by the coordinator against the merged results
+	var buf bytes.Buffer
+	b.Writer = &buf
+	b.JSON = true
+	b.Format = dnsbench.FormatJSON
+	b.Silent = false
+	b.PlotDir, b.Csv, b.HTML, b.JUnit, b.PrometheusMetricsAddr = "", "", "", "", ""
+	b.SLOs, b.FailConditions = nil, nil
+
+	start := time.Now()
+	stats, err := b.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reporter.PrintReport(&b, stats, start, time.Since(start), getServerGeocode(b.Server)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
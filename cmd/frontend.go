@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/skratchdot/open-golang/open"
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
 )
 
 // FrontendConfig holds configuration for the frontend server
@@ -22,6 +24,15 @@ type FrontendConfig struct {
 	PreloadFile string
 }
 
+// compareRequest is the body of /api/compare request, baseline and current are JSON results of the benchmark runs.
+type compareRequest struct {
+	Baseline        json.RawMessage `json:"baseline"`
+	Current         json.RawMessage `json:"current"`
+	MaxRegressions  []string        `json:"maxRegressions"`
+	Alpha           float64         `json:"alpha"`
+	FailSignificant bool            `json:"failSignificant"`
+}
+
 // StartFrontendServer starts the web frontend server
 func StartFrontendServer(config FrontendConfig) error {
 	fs := GetFrontendFileSystem()
@@ -118,9 +129,27 @@ func StartFrontendServer(config FrontendConfig) error {
 			return
 		}
 
-		// TODO: Implement results comparison logic
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status": "comparison ready", "message": "Results comparison feature coming soon"}`))
+		var req compareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		thresholds, err := reporter.ParseRegressionThresholds(req.MaxRegressions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c, err := reporter.Compare(req.Baseline, req.Current, reporter.CompareOptions{
+			MaxRegressions:    thresholds,
+			Alpha:             req.Alpha,
+			FailOnSignificant: req.FailSignificant,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c)
 	})
 
 	// API endpoint for getting server rankings
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
+)
+
+// runMerge merges JSON results stored in the files and prints the merged JSON result.
+func runMerge(paths []string) error {
+	results := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read results: %w", err)
+		}
+		results = append(results, data)
+	}
+	return reporter.MergeResults(os.Stdout, results...)
+}
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
+	"gopkg.in/yaml.v3"
+)
+
+// testPlan represents ordered list of benchmark scenarios executed sequentially.
+type testPlan struct {
+	// Cooldown is the default pause between the scenarios.
+	Cooldown time.Duration `yaml:"cooldown"`
+	// Config is shared by all the scenarios, it has the same format as the configuration file.
+	Config    configMap      `yaml:"config"`
+	Scenarios []planScenario `yaml:"scenarios"`
+}
+
+// planScenario is a single benchmark of the test plan.
+type planScenario struct {
+	Name     string         `yaml:"name"`
+	Duration time.Duration  `yaml:"duration"`
+	Cooldown *time.Duration `yaml:"cooldown"`
+	// Config is merged over the config of the plan.
+	Config     configMap `yaml:"config"`
+	Thresholds []string  `yaml:"thresholds"`
+}
+
+// preparedScenario is the scenario with parsed benchmark and thresholds.
+type preparedScenario struct {
+	name       string
+	benchmark  dnsbench.Benchmark
+	thresholds []reporter.Threshold
+	cooldown   time.Duration
+}
+
+// scenarioResult represents results of the executed scenario.
+type scenarioResult struct {
+	Name            string                     `json:"name"`
+	Server          string                     `json:"server"`
+	DurationSeconds float64                    `json:"durationSeconds"`
+	Result          json.RawMessage            `json:"result,omitempty"`
+	Thresholds      []reporter.ThresholdResult `json:"thresholds,omitempty"`
+	Passed          bool                       `json:"passed"`
+	Error           string                     `json:"error,omitempty"`
+
+	stats      reporter.BenchmarkResultStats
+	duration   time.Duration
+	assertions reporter.AssertionSuite
+}
+
+// planResult is the combined JSON report of the test plan.
+type planResult struct {
+	Scenarios []scenarioResult `json:"scenarios"`
+	Passed    bool             `json:"passed"`
+}
+
+func loadPlan(path string) (testPlan, error) {
+	var plan testPlan
+	f, err := os.Open(path)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read test plan: %w", err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&plan); err != nil {
+		return plan, fmt.Errorf("failed to parse test plan '%s': %w", path, err)
+	}
+	if len(plan.Scenarios) == 0 {
+		return plan, fmt.Errorf("test plan '%s' does not contain any scenarios", path)
+	}
+	return plan, nil
+}
+
+// preparePlan parses benchmarks and thresholds of all the scenarios, so the plan fails before running any scenario, when
+// it is misconfigured. The values are layered, configuration file < plan config < scenario config < command line flags.
+func preparePlan(plan testPlan) (dnsbench.Benchmark, []preparedScenario, error) {
+	planValues, err := configValues(plan.Config)
+	if err != nil {
+		return dnsbench.Benchmark{}, nil, fmt.Errorf("test plan config: %w", err)
+	}
+	base, err := parseBenchmark(mergeValues(configFileValues, planValues), commandLineValues)
+	if err != nil {
+		return dnsbench.Benchmark{}, nil, fmt.Errorf("test plan config: %w", err)
+	}
+
+	scenarios := make([]preparedScenario, 0, len(plan.Scenarios))
+	for i, sc := range plan.Scenarios {
+		if len(sc.Name) == 0 {
+			sc.Name = "scenario-" + strconv.Itoa(i+1)
+		}
+		scenarioValues, err := configValues(sc.Config)
+		if err != nil {
+			return dnsbench.Benchmark{}, nil, fmt.Errorf("scenario '%s': %w", sc.Name, err)
+		}
+		if sc.Duration > 0 {
+			scenarioValues["duration"] = []string{sc.Duration.String()}
+		}
+		b, err := parseBenchmark(mergeValues(configFileValues, planValues, scenarioValues), commandLineValues)
+		if err != nil {
+			return dnsbench.Benchmark{}, nil, fmt.Errorf("scenario '%s': %w", sc.Name, err)
+		}
+		if len(b.Queries) == 0 && !b.DNSSECValidation {
+			return dnsbench.Benchmark{}, nil, fmt.Errorf("scenario '%s': no queries configured", sc.Name)
+		}
+		// SLOs configured by the slo flag are evaluated together with the thresholds of the scenario
+		thresholds, err := reporter.ParseThresholds(append(sc.Thresholds, b.SLOs...))
+		if err != nil {
+			return dnsbench.Benchmark{}, nil, fmt.Errorf("scenario '%s': %w", sc.Name, err)
+		}
+		b.SLOs = nil
+		cooldown := plan.Cooldown
+		if sc.Cooldown != nil {
+			cooldown = *sc.Cooldown
+		}
+		scenarios = append(scenarios, preparedScenario{name: sc.Name, benchmark: b, thresholds: thresholds, cooldown: cooldown})
+	}
+	return base, scenarios, nil
+}
+
+// runPlan executes the scenarios of the test plan sequentially and prints combined report. Returns true, if all the scenarios
+// were executed and passed their thresholds.
+func runPlan(path string) (bool, error) {
+	plan, err := loadPlan(path)
+	if err != nil {
+		return false, err
+	}
+	base, scenarios, err := preparePlan(plan)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		if _, ok := <-sigs; ok {
+			cancel()
+		}
+	}()
+
+	format := base.Format
+	if base.JSON {
+		format = dnsbench.FormatJSON
+	}
+	printSections := format == dnsbench.FormatStandard && !base.Silent
+	results := runScenarios(ctx, scenarios, format, printSections)
+	passed := planFailed(results, len(scenarios)) == 0
+
+	switch {
+	case base.Silent:
+	case format == dnsbench.FormatJSON:
+		if err := json.NewEncoder(os.Stdout).Encode(planResult{Scenarios: results, Passed: passed}); err != nil {
+			return false, err
+		}
+	case format == dnsbench.FormatJUnit || format == dnsbench.FormatTAP:
+		suites := make([]reporter.AssertionSuite, 0, len(results))
+		for _, r := range results {
+			suites = append(suites, r.assertions)
+		}
+		write := reporter.WriteJUnit
+		if format == dnsbench.FormatTAP {
+			write = reporter.WriteTAP
+		}
+		if err := write(os.Stdout, suites); err != nil {
+			return false, err
+		}
+	default:
+		printPlanSummary(results, len(scenarios), passed)
+	}
+	return passed, nil
+}
+
+// runScenarios executes the scenarios sequentially with the cooldown between them. When the context is cancelled, the remaining
+// scenarios are not executed, so fewer results than scenarios are returned.
+func runScenarios(ctx context.Context, scenarios []preparedScenario, format string, printSections bool) []scenarioResult {
+	results := make([]scenarioResult, 0, len(scenarios))
+	for i, sc := range scenarios {
+		if ctx.Err() != nil {
+			break
+		}
+		if printSections {
+			printutils.NeutralFprintf(os.Stdout, "\n=== Scenario %d/%d: %s ===\n\n", i+1, len(scenarios), printutils.HighlightSprint(sc.name))
+		}
+		results = append(results, runScenario(ctx, sc, format))
+
+		if i < len(scenarios)-1 && sc.cooldown > 0 {
+			if printSections {
+				printutils.NeutralFprintf(os.Stdout, "\nCooling down for %s\n", printutils.HighlightSprint(sc.cooldown))
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(sc.cooldown):
+			}
+		}
+	}
+	return results
+}
+
+// planFailed returns number of the scenarios, which failed or were not executed.
+func planFailed(results []scenarioResult, total int) int {
+	failed := total - len(results)
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+func runScenario(ctx context.Context, sc preparedScenario, format string) scenarioResult {
+	b := sc.benchmark
+	res := scenarioResult{Name: sc.name, Server: b.Server}
+
+	// reports of the scenarios are combined, so only the standard report is printed directly
+	var buf bytes.Buffer
+	if format != dnsbench.FormatStandard {
+		b.Writer = &buf
+	}
+
+	start := time.Now()
+	stats, err := b.Run(ctx)
+	res.duration = time.Since(start)
+	// the server is normalized by the benchmark (e.g. default port is added)
+	res.Server = b.Server
+	res.DurationSeconds = res.duration.Seconds()
+	res.assertions = reporter.AssertionSuite{Name: sc.name, Start: start, Duration: res.duration}
+	if err != nil {
+		res.Error = err.Error()
+		res.assertions.Error = res.Error
+		printutils.ErrFprintf(os.Stderr, "Scenario '%s' failed to start: %s\n", sc.name, err.Error())
+		return res
+	}
+	assertions, err := reporter.PrintReport(&b, stats, start, res.duration, getServerGeocode(b.Server))
+	if err != nil {
+		res.Error = err.Error()
+		res.assertions.Error = res.Error
+		printutils.ErrFprintf(os.Stderr, "Scenario '%s' failed to print report: %s\n", sc.name, err.Error())
+		return res
+	}
+	if format == dnsbench.FormatJSON {
+		res.Result = bytes.TrimSpace(buf.Bytes())
+	}
+
+	res.stats = reporter.Merge(&b, stats)
+	res.Thresholds = reporter.EvaluateThresholds(res.stats, res.duration, sc.thresholds)
+	res.Passed = reporter.ThresholdsPassed(res.Thresholds)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		res.Passed = false
+		res.Error = "interrupted"
+		res.assertions.Error = res.Error
+	}
+	// fail conditions are asserted in the scenarios as well
+	res.assertions.Assertions = assertions.Assertions
+	res.Passed = res.Passed && assertions.Passed()
+	for _, t := range res.Thresholds {
+		res.assertions.Assertions = append(res.assertions.Assertions,
+			reporter.AssertionResult{Kind: reporter.AssertionSLO, Name: t.Threshold, Passed: t.Passed, Message: t.Explanation})
+	}
+	if len(res.Thresholds) > 0 && format == dnsbench.FormatStandard && !b.Silent {
+		reporter.PrintThresholds(os.Stdout, res.Thresholds)
+	}
+	return res
+}
+
+func printPlanSummary(results []scenarioResult, total int, passed bool) {
+	printutils.NeutralFprintf(os.Stdout, "\n=== Test plan summary ===\n\n")
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Scenario", "Server", "Duration", "Requests", "QPS", "p50", "p99", "Errors", "Result"})
+	table.SetBorder(false)
+	for _, r := range results {
+		if len(r.Error) != 0 && r.stats.Hist == nil {
+			table.Append([]string{r.Name, r.Server, "-", "-", "-", "-", "-", "-", "ERROR"})
+			continue
+		}
+		c := r.stats.Counters
+		result := "PASS"
+		switch {
+		case len(r.Error) != 0:
+			result = "ERROR"
+		case !r.Passed:
+			result = "FAIL"
+		case len(r.Thresholds) == 0:
+			result = "-"
+		}
+		table.Append([]string{
+			r.Name,
+			r.Server,
+			r.duration.Round(time.Millisecond).String(),
+			strconv.FormatInt(c.Total, 10),
+			strconv.FormatFloat(float64(c.Total)/r.duration.Seconds(), 'f', 1, 64),
+			time.Duration(r.stats.Hist.ValueAtQuantile(50)).Round(10 * time.Microsecond).String(),
+			time.Duration(r.stats.Hist.ValueAtQuantile(99)).Round(10 * time.Microsecond).String(),
+			strconv.FormatInt(c.IOError+c.Error, 10),
+			result,
+		})
+	}
+	table.Render()
+
+	if passed {
+		printutils.SuccessFprintf(os.Stdout, "\nTest plan PASSED\n")
+		return
+	}
+	printutils.ErrFprintf(os.Stdout, "\nTest plan FAILED (%d of %d scenarios failed or were not executed)\n", planFailed(results, total), total)
+}
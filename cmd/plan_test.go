@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+// setPlanValues sets the values of the configuration file and the command line used by preparePlan.
+func setPlanValues(t *testing.T, configured, commandLine map[string][]string) {
+	t.Helper()
+	origConfigured, origCommandLine := configFileValues, commandLineValues
+	t.Cleanup(func() {
+		configFileValues, commandLineValues = origConfigured, origCommandLine
+		restoreDefaults()
+	})
+	configFileValues, commandLineValues = configured, commandLine
+}
+
+func writePlan(t *testing.T, plan string) testPlan {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(plan), 0o600))
+	p, err := loadPlan(path)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPreparePlan_layering(t *testing.T) {
+	setPlanValues(t,
+		map[string][]string{"server": {"8.8.8.8"}, "concurrency": {"2"}, "number": {"10"}, "type": {"A"}},
+		map[string][]string{"type": {"TXT"}},
+	)
+	plan := writePlan(t, `
+config:
+  server: 1.1.1.1
+  concurrency: 4
+  queries: [example.com]
+scenarios:
+  - name: plan
+  - name: scenario
+    duration: 1m
+    config:
+      concurrency: 8
+      type: [AAAA]
+`)
+
+	base, scenarios, err := preparePlan(plan)
+
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", base.Server, "plan config should override config file")
+	require.Len(t, scenarios, 2)
+
+	assert.Equal(t, "plan", scenarios[0].name)
+	assert.Equal(t, "1.1.1.1", scenarios[0].benchmark.Server)
+	assert.Equal(t, uint32(4), scenarios[0].benchmark.Concurrency, "plan config should override config file")
+	assert.Equal(t, int64(10), scenarios[0].benchmark.Count, "config file should be used, when not overridden")
+	assert.Equal(t, []string{"example.com"}, scenarios[0].benchmark.Queries)
+
+	assert.Equal(t, "scenario", scenarios[1].name)
+	assert.Equal(t, "1.1.1.1", scenarios[1].benchmark.Server)
+	assert.Equal(t, uint32(8), scenarios[1].benchmark.Concurrency, "scenario config should override plan config")
+	assert.Equal(t, time.Minute, scenarios[1].benchmark.Duration)
+
+	for _, sc := range scenarios {
+		assert.Equal(t, []string{"TXT"}, sc.benchmark.Types, "command line should override all the configs")
+	}
+}
+
+func TestPreparePlan_cooldown(t *testing.T) {
+	setPlanValues(t, nil, nil)
+	plan := writePlan(t, `
+cooldown: 10s
+config:
+  queries: [example.com]
+scenarios:
+  - name: default
+  - name: override
+    cooldown: 1s
+  - name: disabled
+    cooldown: 0s
+`)
+
+	_, scenarios, err := preparePlan(plan)
+
+	require.NoError(t, err)
+	require.Len(t, scenarios, 3)
+	assert.Equal(t, 10*time.Second, scenarios[0].cooldown)
+	assert.Equal(t, time.Second, scenarios[1].cooldown)
+	assert.Equal(t, time.Duration(0), scenarios[2].cooldown)
+}
+
+func TestPreparePlan_thresholds(t *testing.T) {
+	setPlanValues(t, nil, map[string][]string{"slo": {"p99<1s"}})
+	plan := writePlan(t, `
+config:
+  queries: [example.com]
+scenarios:
+  - name: thresholds
+    thresholds: ["p50<50ms", "qps>=100"]
+  - name: slo-only
+`)
+
+	_, scenarios, err := preparePlan(plan)
+
+	require.NoError(t, err)
+	require.Len(t, scenarios, 2)
+	assert.Equal(t, []string{"p50<50ms", "qps>=100", "p99<1s"}, thresholdExpressions(scenarios[0]))
+	assert.Equal(t, []string{"p99<1s"}, thresholdExpressions(scenarios[1]))
+	for _, sc := range scenarios {
+		assert.Nil(t, sc.benchmark.SLOs, "SLOs should be evaluated only as the thresholds of the scenario")
+	}
+}
+
+func TestPreparePlan_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+	}{
+		{
+			name: "no queries",
+			plan: "scenarios:\n  - name: empty\n",
+		},
+		{
+			name: "invalid threshold",
+			plan: "config:\n  queries: [example.com]\nscenarios:\n  - thresholds: [p99]\n",
+		},
+		{
+			name: "invalid scenario config",
+			plan: "config:\n  queries: [example.com]\nscenarios:\n  - config:\n      concurrency: many\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setPlanValues(t, nil, nil)
+
+			_, _, err := preparePlan(writePlan(t, tt.plan))
+
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRunScenarios_interrupted(t *testing.T) {
+	server := startDNSServer(t)
+	setPlanValues(t, nil, map[string][]string{"silent": {"true"}})
+	plan := writePlan(t, `
+cooldown: 1m
+config:
+  server: `+server+`
+  number: 1
+  queries: [example.com]
+scenarios:
+  - name: first
+  - name: second
+  - name: third
+`)
+	_, scenarios, err := preparePlan(plan)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the plan is interrupted during the cooldown after the first scenario
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	results := runScenarios(ctx, scenarios, dnsbench.FormatStandard, false)
+
+	require.Len(t, results, 1, "scenarios after the interrupt should not be executed")
+	assert.Equal(t, "first", results[0].Name)
+	assert.True(t, results[0].Passed)
+	assert.Equal(t, 2, planFailed(results, len(scenarios)), "scenarios, which were not executed, should be counted as failed")
+}
+
+func TestPlanFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []scenarioResult
+		total   int
+		want    int
+	}{
+		{
+			name:    "all passed",
+			results: []scenarioResult{{Passed: true}, {Passed: true}},
+			total:   2,
+		},
+		{
+			name:    "failed",
+			results: []scenarioResult{{Passed: true}, {Passed: false}},
+			total:   2,
+			want:    1,
+		},
+		{
+			name:    "not executed",
+			results: []scenarioResult{{Passed: false}},
+			total:   3,
+			want:    3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, planFailed(tt.results, tt.total))
+		})
+	}
+}
+
+func thresholdExpressions(sc preparedScenario) []string {
+	var expressions []string
+	for _, th := range sc.thresholds {
+		expressions = append(expressions, th.Expression)
+	}
+	return expressions
+}
+
+// startDNSServer starts plain DNS server answering all the queries with NOERROR and returns its address.
+func startDNSServer(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(r)
+		_ = w.WriteMsg(resp)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() {
+		_ = server.Shutdown()
+	})
+	return pc.LocalAddr().String()
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+// runProxy starts fault injecting proxy and forwards the traffic until interrupted, the injected faults are printed on exit.
+func runProxy(listen, upstream string, faults faultproxy.Config) error {
+	p, err := faultproxy.Start(listen, upstream, faults)
+	if err != nil {
+		return err
+	}
+	printutils.NeutralFprintf(os.Stdout, "Proxying UDP %s and TCP %s to %s\n", printutils.HighlightSprint(p.UDPAddr),
+		printutils.HighlightSprint(p.TCPAddr), printutils.HighlightSprint(upstream))
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+
+	if err := p.Close(); err != nil {
+		return err
+	}
+	st := p.Stats()
+	printutils.NeutralFprintf(os.Stdout, "\nInjected network faults:\n")
+	printutils.NeutralFprintf(os.Stdout, "\tDropped queries:\t%s\n", printutils.HighlightSprint(st.QueriesDropped))
+	printutils.NeutralFprintf(os.Stdout, "\tDropped responses:\t%s\n", printutils.HighlightSprint(st.ResponsesDropped))
+	printutils.NeutralFprintf(os.Stdout, "\tDuplicated datagrams:\t%s\n", printutils.HighlightSprint(st.Duplicated))
+	printutils.NeutralFprintf(os.Stdout, "\tReordered datagrams:\t%s\n", printutils.HighlightSprint(st.Reordered))
+	printutils.NeutralFprintf(os.Stdout, "\tDelayed:\t\t%s\n", printutils.HighlightSprint(st.Delayed))
+	return nil
+}
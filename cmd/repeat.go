@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
+)
+
+// runTrial executes single run of the benchmark. The benchmark is passed by value, because Run modifies it (e.g. trims quic://
+// prefix of the server), which must not affect the following trials, the modified benchmark is returned for reporting.
+func runTrial(ctx context.Context, b dnsbench.Benchmark) (dnsbench.Benchmark, reporter.Trial, error) {
+	start := time.Now()
+	res, err := b.Run(ctx)
+	return b, reporter.Trial{Stats: res, Start: start, Duration: time.Since(start)}, err
+}
+
+// runTrials executes the benchmark the number of times configured by Benchmark.Repeat, the trials stop, when the context is canceled.
+func runTrials(ctx context.Context, b *dnsbench.Benchmark) ([]reporter.Trial, error) {
+	orig := *b
+	repeat := max(orig.Repeat, 1)
+	printTrials := repeat > 1 && !orig.Silent && !orig.JSON && (len(orig.Format) == 0 || orig.Format == dnsbench.FormatStandard)
+
+	trials := make([]reporter.Trial, 0, repeat)
+	for i := 0; i < repeat; i++ {
+		if i > 0 && ctx.Err() != nil {
+			break
+		}
+		if printTrials {
+			printutils.NeutralFprintf(os.Stdout, "\n=== Trial %d/%d ===\n\n", i+1, repeat)
+		}
+		run, trial, err := runTrial(ctx, orig)
+		if err != nil {
+			return trials, err
+		}
+		*b = run
+		trials = append(trials, trial)
+	}
+	return trials, nil
+}
+
+// printReport prints report of the trials, the report of repeated benchmark includes confidence intervals of the metrics.
+// The fail conditions and SLOs evaluated for the report are returned.
+func printReport(b *dnsbench.Benchmark, trials []reporter.Trial, geocode string) (reporter.AssertionSuite, error) {
+	if len(trials) > 1 {
+		return reporter.PrintRepeatedReport(b, trials, geocode)
+	}
+	res, duration := reporter.MergeTrials(trials)
+	return reporter.PrintReport(b, res, trials[0].Start, duration, geocode)
+}
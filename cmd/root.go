@@ -16,11 +16,13 @@ import (
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/distributed"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
 	"github.com/tantalor93/dnspyre/v3/pkg/geo"
 	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
 	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
 	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+	"github.com/tantalor93/dnspyre/v3/pkg/server"
 )
 
 var (
@@ -47,11 +49,65 @@ var (
 	frontendFile = frontendCmd.Flag("file", "Preload JSON data file").
 			Short('f').String()
 
+	// Serve command
+	serveCmd    = pApp.Command("serve", "Start local DNS server for validating benchmark setups without touching a real resolver")
+	serveConfig = server.Config{}
+	serveRcode  string
+
+	// Proxy command
+	proxyCmd      = pApp.Command("proxy", "Start UDP and TCP proxy injecting network faults configured by --fault-* flags between DNS clients and server")
+	proxyListen   = proxyCmd.Flag("listen", "Address of the proxy listeners (UDP and TCP).").Default("127.0.0.1:5300").String()
+	proxyUpstream = proxyCmd.Flag("upstream", "Address of the DNS server, the traffic is forwarded to, in format <IP>:<port>.").Required().String()
+
+	// Plan command
+	planCmd  = pApp.Command("plan", "Run test plan, ordered list of benchmark scenarios executed sequentially, and print combined report with summary of the scenarios")
+	planFile = planCmd.Arg("file", "YAML or JSON test plan file.").Required().String()
+
+	// Compare command
+	compareCmd            = pApp.Command("compare", "Compare JSON results of two benchmark runs and fail on regressions")
+	compareBaseline       = compareCmd.Arg("baseline", "JSON results of the baseline run.").Required().ExistingFile()
+	compareCurrent        = compareCmd.Arg("current", "JSON results of the current run.").Required().ExistingFile()
+	compareMaxRegressions = compareCmd.Flag("max-regression", "Maximal allowed regression of a metric in format <metric>=<limit>, "+
+		"dnspyre exits with non-zero exit code, when the regression is exceeded for any server. The limit with % suffix is relative to "+
+		"the baseline value, otherwise it is absolute in units of the metric (duration for latencies, fraction for ratios). Repeatable flag.").
+		PlaceHolder("p99=10%").Strings()
+	compareAlpha = compareCmd.Flag("alpha", "Significance level of the statistical tests comparing latency distributions, "+
+		"which are available, unless the benchmarks were run with --no-distribution flag.").
+		Default("0.05").Float64()
+	compareFailSignificant = compareCmd.Flag("fail-significant", "Exit with non-zero exit code, when the current latencies are "+
+		"significantly higher than the baseline latencies.").Bool()
+
+	// Distributed benchmark, the benchmark command runs as coordinator of the agents started by agent command
+	distributedAgents = benchmarkCmd.Flag("agents", "Runs the benchmark distributed to the number of agents started by 'dnspyre agent' command. "+
+		"This process only coordinates the agents, it waits until all the agents register, distributes the benchmark configuration to them, "+
+		"starts the benchmark on all the agents at once and merges their results.").PlaceHolder("3").Int()
+	coordinatorListen = benchmarkCmd.Flag("coordinator-listen", "Address of the HTTP listener, the agents register with, when --agents is set. "+
+		"By default the coordinator listens only on loopback, use e.g. :8090 for the agents on other hosts.").
+		Default(distributed.DefaultAddr).String()
+	coordinatorToken = benchmarkCmd.Flag("coordinator-token", "Shared secret, the agents have to present to the coordinator, when --agents is set. "+
+		"The requests of the agents are not authenticated, when not set.").
+		Envar("DNSPYRE_COORDINATOR_TOKEN").PlaceHolder("secret").String()
+	coordinatorStartDelay = benchmarkCmd.Flag("start-delay", "Delay between registration of the last agent and the start of the benchmark, when --agents is set.").
+				Default(distributed.DefaultStartDelay.String()).Duration()
+	coordinatorAgentTimeout = benchmarkCmd.Flag("agent-timeout", "Maximal time between the start of the benchmark and the results of the agents, "+
+		"when --agents is set. The agents, which do not report in time, are reported as failed and the results reported so far are merged. "+
+		"By default the --duration of the benchmark plus "+distributed.DefaultResultGracePeriod.String()+", no timeout is applied to the benchmark without --duration.").
+		PlaceHolder("5m").Duration()
+
+	// Agent command
+	agentCmd         = pApp.Command("agent", "Start agent of the distributed benchmark, which runs the benchmark distributed by the coordinator started with --agents flag")
+	agentCoordinator = agentCmd.Flag("coordinator", "URL of the coordinator, e.g. http://10.0.0.1:8090.").Required().String()
+	agentName        = agentCmd.Flag("name", "Name of the agent reported by the coordinator, by default the coordinator names the agents by the order of registration.").String()
+	agentToken       = agentCmd.Flag("token", "Shared secret configured by --coordinator-token of the coordinator.").
+				Envar("DNSPYRE_COORDINATOR_TOKEN").PlaceHolder("secret").String()
+
+	// Merge command
+	mergeCmd     = pApp.Command("merge", "Merge JSON results of benchmarks run concurrently by multiple load generators into a single JSON result")
+	mergeResults = mergeCmd.Arg("results", "JSON results to merge.").Required().ExistingFiles()
+
 	benchmark = dnsbench.Benchmark{
 		Writer: os.Stdout,
 	}
-
-	failConditions []string
 )
 
 const (
@@ -87,16 +143,89 @@ func init() {
 	pApp.Flag("query-per-conn", "Queries on a connection before creating a new one. 0: unlimited. Applicable for plain DNS and DoT, this option is not considered for DoH or DoQ.").
 		Default("0").Int64Var(&benchmark.QperConn)
 
+	pApp.Flag("source-addr", "Local IP address used as source address of plain DNS queries. Can be specified multiple times, the workers are spread over the addresses of the same IP family as the server.").
+		StringsVar(&benchmark.SourceAddrs)
+
+	pApp.Flag("source-interface", "Network interface, whose IP addresses are used as source addresses of plain DNS queries.").
+		StringVar(&benchmark.SourceInterface)
+
+	pApp.Flag("source-port", "Source port of plain DNS queries, either fixed port <port> or range <min>-<max>, from which the port is randomly chosen for each new socket. "+
+		"Each concurrent worker needs its own port, so the range has to contain at least --concurrency ports (per source address).").
+		StringVar(&benchmark.SourcePorts)
+
+	pApp.Flag("malformed", "Sends malformed queries instead of well-formed ones to test robustness of the server, reactions of the server are classified and reported. Applicable only for plain DNS and DoT.").
+		BoolVar(&benchmark.Malformed)
+
+	pApp.Flag("malformed-seed", "Seed used for generating malformed queries, so the run can be reproduced. 0 means random seed, which is printed at the start of the benchmark.").
+		Default("0").Int64Var(&benchmark.MalformedSeed)
+
+	pApp.Flag("malformed-mutation", "Mutation used for generating malformed queries. Can be specified multiple times, all mutations are used by default.").
+		EnumsVar(&benchmark.MalformedMutations, dnsbench.Mutations...)
+
+	pApp.Flag(configFlag, "YAML or JSON configuration file, the keys are names of the benchmark flags (without leading dashes) and 'queries', "+
+		"repeatable flags are configured as lists. Environment variables in the values are expanded ($VAR, ${VAR} or ${VAR:-default}). "+
+		"Flags provided on the command line take precedence over the configuration file.").
+		PlaceHolder("dnspyre.yaml").String()
+	pApp.Flag(profileFlag, "Name of the profile from 'profiles' section of the configuration file, whose values are merged over the top level values of the configuration file.").
+		String()
+
+	pApp.Flag("fault-loss", "Fraction of UDP datagrams (0-1) dropped by the fault injection proxy started between the workers and the server, applied to queries and responses independently. Applicable only for plain DNS and DoT.").
+		Default("0").Float64Var(&benchmark.Faults.Loss)
+	pApp.Flag("fault-delay", "Delay added to each datagram or TCP chunk by the fault injection proxy. Applicable only for plain DNS and DoT.").
+		Default("0s").DurationVar(&benchmark.Faults.Delay)
+	pApp.Flag("fault-jitter", "Maximum random delay added on top of --fault-delay by the fault injection proxy. Applicable only for plain DNS and DoT.").
+		Default("0s").DurationVar(&benchmark.Faults.Jitter)
+	pApp.Flag("fault-reorder", "Fraction of UDP datagrams (0-1) sent after the following datagram by the fault injection proxy. Applicable only for plain DNS and DoT.").
+		Default("0").Float64Var(&benchmark.Faults.Reorder)
+	pApp.Flag("fault-duplicate", "Fraction of UDP datagrams (0-1) sent twice by the fault injection proxy. Applicable only for plain DNS and DoT.").
+		Default("0").Float64Var(&benchmark.Faults.Duplicate)
+
+	pApp.Flag("tc-fallback", "Retries queries over TCP when the UDP response is truncated (TC flag set), like stub resolvers do. Latency of the retried queries includes both UDP and TCP exchange. Applicable only for plain DNS over UDP.").
+		BoolVar(&benchmark.TCFallback)
+
+	pApp.Flag("pipeline", "Number of queries each worker keeps in flight on a single connection without waiting for the responses (RFC 7766 pipelining). Applicable only for plain DNS over TCP and DoT.").
+		Default("0").IntVar(&benchmark.Pipeline)
+
 	pApp.Flag("recurse", "Allow DNS recursion. Enabled by default.").
 		Short('r').Default("true").BoolVar(&benchmark.Recurse)
 
 	pApp.Flag("probability", "Each provided hostname will be used with provided probability. Value 1 and above means that each hostname will be used by each concurrent benchmark goroutine. Useful for randomizing queries across benchmark goroutines.").
 		Default("1").Float64Var(&benchmark.Probability)
 
-	pApp.Flag("ednsopt", "code[:value], Specify EDNS option with code point code and optionally payload of value as a hexadecimal string. code must be an arbitrary numeric value.").
-		Default("").StringVar(&benchmark.EdnsOpt)
+	pApp.Flag("ednsopt", "code[:value], Specify EDNS option with code point code and optionally payload of value as a hexadecimal string. code must be an arbitrary numeric value. Can be specified multiple times.").
+		StringsVar(&benchmark.EdnsOpts)
+
+	pApp.Flag("cookie", "Send DNS Cookies (RFC 7873), each worker reuses the server cookie returned by the server in the following requests.").
+		BoolVar(&benchmark.Cookies)
+
+	pApp.Flag("edns-padding", "Pad DNS requests to the multiple of 128 octets using EDNS padding option (RFC 7830, RFC 8467). Applicable only for DoT, DoH and DoQ.").
+		BoolVar(&benchmark.Padding)
+
+	pApp.Flag("edns-keepalive", "Send EDNS TCP keepalive option (RFC 7828). Applicable only for plain DNS over TCP and DoT.").
+		BoolVar(&benchmark.EdnsKeepAlive)
+
+	pApp.Flag("nsid", "Request NSID (RFC 5001), the NSIDs returned by the server are reported.").
+		BoolVar(&benchmark.NSID)
+	pApp.Flag("instance-id", "Identify the server instance answering the requests (e.g. POP of anycast server) and report results per instance. "+
+		"nsid: NSID returned in the responses, id.server or hostname.bind: CHAOS TXT record queried after each response. "+
+		"The CHAOS TXT queries double the queries received by the server, they are not counted in the results, but they are subject to --rate and --rate-limit-worker.").
+		EnumVar(&benchmark.InstanceID, dnsbench.InstanceIDNSID, dnsbench.InstanceIDServer, dnsbench.InstanceIDHostnameBind)
+
+	pApp.Flag("ecs", "EDNS Client Subnet in CIDR notation (e.g. 81.0.198.0/24) added to the DNS requests. Can be specified multiple times, see --ecs-mode for how the subnets are assigned to the requests.").
+		StringsVar(&benchmark.ECS)
+
+	pApp.Flag("ecs-mode", "How the client subnets are assigned to the requests. rotate: each worker rotates the subnets for each request, random: random subnet for each request, worker: each worker uses single subnet.").
+		Default(dnsbench.ECSModeRotate).EnumVar(&benchmark.ECSMode, dnsbench.ECSModeRotate, dnsbench.ECSModeRandom, dnsbench.ECSModeWorker)
 
 	pApp.Flag("dnssec", "Allow DNSSEC (sets DO bit for all DNS requests to 1)").BoolVar(&benchmark.DNSSEC)
+	pApp.Flag("dnssec-validation", "Runs DNSSEC validation benchmark, it checks whether the server validates "+
+		"signed, unsigned and bogus domains correctly, which DNSSEC algorithms are validated and measures latency overhead of the validation. "+
+		"The queries cannot be provided, the domains are configured by --dnssec-case. The built-in domains are public internet domains, "+
+		"so the server has to be able to resolve them.").
+		BoolVar(&benchmark.DNSSECValidation)
+	pApp.Flag("dnssec-case", "Test case of the DNSSEC validation benchmark in format <expected result>:<domain>, where expected result is one of "+
+		"secure, insecure and bogus. Can be specified multiple times, the built-in test cases are used when not specified.").
+		PlaceHolder("secure:example.org").StringsVar(&benchmark.DNSSECCases)
 
 	pApp.Flag("edns0", "Configures EDNS0 usage in DNS requests send by benchmark and configures EDNS0 buffer size to the specified value. When 0 is configured, then EDNS0 is not used.").
 		Default("0").Uint16Var(&benchmark.Edns0)
@@ -117,6 +246,18 @@ func init() {
 	pApp.Flag("request", "request timeout.").Default(dnsbench.DefaultRequestTimeout.String()).
 		DurationVar(&benchmark.RequestTimeout)
 
+	pApp.Flag("retries", "Number of retries of failed queries (IO error, timeout, SERVFAIL, NOTIMP or REFUSED response), emulating stub resolver behavior. 0 disables retries. All the attempts are limited by --request timeout.").
+		Default("0").IntVar(&benchmark.Retries)
+
+	pApp.Flag("retry-timeout", "Timeout of a single attempt, when --retries is set. 0 means the attempt is limited only by --request timeout.").
+		Default("0s").DurationVar(&benchmark.RetryTimeout)
+
+	pApp.Flag("retry-backoff", "Delay before the first retry, the delay is doubled with each following retry.").
+		Default("0s").DurationVar(&benchmark.RetryBackoff)
+
+	pApp.Flag("fallback-server", "Server rotated with the benchmarked server when retrying the queries, it must use the same protocol as the benchmarked server. Can be specified multiple times.").
+		StringsVar(&benchmark.FallbackServers)
+
 	pApp.Flag("codes", "Enable counting DNS return codes. Enabled by default.").
 		Default("true").BoolVar(&benchmark.Rcodes)
 
@@ -134,11 +275,23 @@ func init() {
 	pApp.Flag("csv", "Export distribution to CSV.").
 		Default("").PlaceHolder("/path/to/file.csv").StringVar(&benchmark.Csv)
 
-	pApp.Flag("json", "Report benchmark results as JSON.").BoolVar(&benchmark.JSON)
+	pApp.Flag("json", "Report benchmark results as JSON. Shorthand for --format=json.").BoolVar(&benchmark.JSON)
+
+	pApp.Flag("format", "Format of the benchmark report. Supported formats are 'standard' (human-readable report), 'json', 'junit' (JUnit XML with test case "+
+		"for the benchmark and for each --fail condition and --slo threshold) and 'tap' (the same test cases in Test Anything Protocol).").
+		Default(dnsbench.FormatStandard).EnumVar(&benchmark.Format, dnsbench.FormatStandard, dnsbench.FormatJSON, dnsbench.FormatJUnit, dnsbench.FormatTAP)
 
 	pApp.Flag("batch-json", "Generate batch JSON output for multiple servers. Format: server1,server2,server3").
 		PlaceHolder("8.8.8.8,1.1.1.1,114.114.114.114").StringVar(&benchmark.BatchJSON)
 
+	pApp.Flag("repeat", "Number of times the benchmark is run. The trials are reported together with mean and confidence intervals "+
+		"of latency percentiles, qps and score, batch benchmarks rank the servers taking the uncertainty of the score into account.").
+		Default("1").IntVar(&benchmark.Repeat)
+
+	pApp.Flag("interleave", "Controls whether the repeated trials of the batch benchmark alternate the servers, so the servers are "+
+		"affected by the changing network conditions equally. By default all the trials of a server run before the next server.").
+		BoolVar(&benchmark.Interleave)
+
 	pApp.Flag("html", "Path to create HTML report file with embedded benchmark results.").
 		PlaceHolder("/path/to/report.html").StringVar(&benchmark.HTML)
 
@@ -159,9 +312,58 @@ func init() {
 	pApp.Flag("doh-protocol", "HTTP protocol to use for DoH requests. Supported values: 1.1, 2 and 3.").
 		Default(dnsbench.HTTP1Proto).EnumVar(&benchmark.DohProtocol, dnsbench.HTTP1Proto, dnsbench.HTTP2Proto, dnsbench.HTTP3Proto)
 
+	pApp.Flag("doh-max-conns", "Limits number of connections opened to the DoH server. For HTTP/1.1 it limits number of connections per host, "+
+		"for HTTP/2 and HTTP/3 the requests are distributed over exactly the specified number of connections. 0 means default transport behavior.").
+		Default("0").IntVar(&benchmark.DohMaxConns)
+
+	pApp.Flag("doh-max-streams", "Limits number of concurrent requests (streams) multiplexed over a single HTTP/2 or HTTP/3 DoH connection. "+
+		"0 means that the number of streams is not limited by the client.").
+		Default("0").IntVar(&benchmark.DohMaxStreams)
+
+	pApp.Flag("doh-idle-timeout", "How long an idle DoH connection is kept open. 0 means default transport behavior.").
+		Default("0s").DurationVar(&benchmark.DohIdleTimeout)
+
+	pApp.Flag("doh-keep-alive", "Keep-alive period of DoH connections, it is TCP keep-alive for HTTP/1.1, PING health check for HTTP/2 "+
+		"and QUIC keep-alive for HTTP/3. 0 means default transport behavior.").
+		Default("0s").DurationVar(&benchmark.DohKeepAlive)
+
+	pApp.Flag("doh-disable-keep-alive", "Disables reuse of HTTP/1.1 DoH connections, so each DoH request is sent using a new connection.").
+		BoolVar(&benchmark.DohDisableKeepAlive)
+
 	pApp.Flag("insecure", "Disables server TLS certificate validation. Applicable for DoT, DoH and DoQ.").
 		BoolVar(&benchmark.Insecure)
 
+	pApp.Flag("tls-ca", "Path to PEM encoded CA bundle used for validating server certificates instead of the system CA pool. Applicable for DoT, DoH and DoQ.").
+		PlaceHolder("/path/to/ca.pem").StringVar(&benchmark.CACertFile)
+
+	pApp.Flag("tls-cert", "Path to PEM encoded client certificate used for mutual TLS authentication. Must be used together with --tls-key. Applicable for DoT, DoH and DoQ.").
+		PlaceHolder("/path/to/cert.pem").StringVar(&benchmark.ClientCertFile)
+
+	pApp.Flag("tls-key", "Path to PEM encoded private key of the client certificate specified by --tls-cert.").
+		PlaceHolder("/path/to/key.pem").StringVar(&benchmark.ClientKeyFile)
+
+	pApp.Flag("tls-server-name", "Overrides server name used for TLS SNI and server certificate validation. By default, the hostname of the server is used. "+
+		"Applicable for DoT, DoH and DoQ.").
+		PlaceHolder("dns.example").StringVar(&benchmark.ServerName)
+
+	pApp.Flag("tls-min-version", "Minimum TLS version. Supported values: 1.0, 1.1, 1.2 and 1.3. Applicable for DoT, DoH and DoQ.").
+		EnumVar(&benchmark.TLSMinVersion, dnsbench.TLSVersion10, dnsbench.TLSVersion11, dnsbench.TLSVersion12, dnsbench.TLSVersion13)
+
+	pApp.Flag("tls-max-version", "Maximum TLS version. Supported values: 1.0, 1.1, 1.2 and 1.3. Applicable for DoT, DoH and DoQ.").
+		EnumVar(&benchmark.TLSMaxVersion, dnsbench.TLSVersion10, dnsbench.TLSVersion11, dnsbench.TLSVersion12, dnsbench.TLSVersion13)
+
+	pApp.Flag("tls-cipher-suite", "Enabled TLS 1.0-1.2 cipher suite, specified by its standard name (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256). "+
+		"TLS 1.3 cipher suites are not configurable. Repeatable flag. Applicable for DoT, DoH and DoQ.").
+		PlaceHolder("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256").StringsVar(&benchmark.TLSCipherSuites)
+
+	pApp.Flag("bootstrap", "Plain DNS resolver used for resolving hostname of the benchmarked server (DoH, DoT, DoQ or plain DNS) "+
+		"in format <IP>[:port], if port is not provided then port 53 is used. By default the OS resolver is used.").
+		PlaceHolder("1.1.1.1:53").StringVar(&benchmark.Bootstrap)
+
+	pApp.Flag("resolve", "Static override of the hostname resolution in format <host>:<IP>, similarly to curl --resolve option. "+
+		"Takes precedence over --bootstrap. TLS SNI and HTTP Host header still use the original hostname. Repeatable flag.").
+		PlaceHolder("dns.example:192.0.2.1").StringsVar(&benchmark.Resolve)
+
 	pApp.Flag("duration", "Specifies for how long the benchmark should be executing, the benchmark will run for the specified time "+
 		"while sending DNS requests in an infinite loop based on the data source. After running for the specified duration, the benchmark is canceled. "+
 		"This option is exclusive with --number option. The duration is specified in GO duration format e.g. 10s, 15m, 1h.").
@@ -170,11 +372,20 @@ func init() {
 	pApp.Flag("progress", "Controls whether the progress bar is shown. Enabled by default.").
 		Default("true").BoolVar(&benchmark.ProgressBar)
 
+	pApp.Flag("slo", "SLO threshold in format <metric><operator><value> evaluated against the benchmark results, dnspyre exits with non-zero exit code, "+
+		"when any of the thresholds is violated. Repeatable flag. Supported metrics are latency (min, mean, max and percentiles like p50, p99 or p99.9) "+
+		"compared to durations, counters (total, success, negative, error, ioerror, idmismatch, truncated) and their ratios to total requests "+
+		"(e.g. ioerror_ratio) compared to fractions or percents, qps and score. For example p99<50ms, ioerror_ratio<0.1%, qps>=20000 or score>=80.").
+		PlaceHolder("p99<50ms").StringsVar(&benchmark.SLOs)
+
+	pApp.Flag("junit", "Write results of the --slo thresholds and --fail conditions to the file in JUnit XML format.").
+		PlaceHolder("/path/to/junit.xml").StringVar(&benchmark.JUnit)
+
 	pApp.Flag("fail", "Controls conditions upon which the dnspyre will exit with a non-zero exit code. Repeatable flag. "+
 		"Supported options are 'ioerror' (fail if there is at least 1 IO error), 'negative' (fail if there is at least 1 negative DNS answer), "+
 		"'error' (fail if there is at least 1 error DNS response), 'idmismatch' (fail there is at least 1 ID mismatch between DNS request and response).").
 		PlaceHolder(ioerrorFailCondition).
-		EnumsVar(&failConditions, ioerrorFailCondition, negativeFailCondition, errorFailCondition, idmismatchFailCondition)
+		EnumsVar(&benchmark.FailConditions, ioerrorFailCondition, negativeFailCondition, errorFailCondition, idmismatchFailCondition)
 
 	pApp.Flag("log-requests", "Controls whether the Benchmark requests are logged. Requests are logged into the file specified by --log-requests-path flag. Disabled by default.").
 		BoolVar(&benchmark.RequestLogEnabled)
@@ -199,7 +410,25 @@ func init() {
 		"It can also be resource accessible using HTTP, like https://raw.githubusercontent.com/Tantalor93/dnspyre/master/data/1000-domains, in that "+
 		"case, the file will be downloaded and saved in-memory. "+
 		"These data sources can be combined, for example \"google.com @data/2-domains https://raw.githubusercontent.com/Tantalor93/dnspyre/master/data/2-domains\"").
-		Required().StringsVar(&benchmark.Queries)
+		StringsVar(&benchmark.Queries)
+
+	serveCmd.Flag("listen", "Address of plain DNS listeners (UDP and TCP). Empty value disables plain DNS.").
+		Default("127.0.0.1:5353").StringVar(&serveConfig.Addr)
+	serveCmd.Flag("listen-dot", "Address of DoT listener, for example 127.0.0.1:8853. DoT is disabled by default.").StringVar(&serveConfig.DoTAddr)
+	serveCmd.Flag("listen-doh", "Address of DoH listener serving /dns-query path over HTTPS, for example 127.0.0.1:8443. DoH is disabled by default.").
+		StringVar(&serveConfig.DoHAddr)
+	serveCmd.Flag("listen-doq", "Address of DoQ listener, for example 127.0.0.1:8853. DoQ is disabled by default.").StringVar(&serveConfig.DoQAddr)
+	serveCmd.Flag("server-cert", "Path to the TLS certificate used by DoT, DoH and DoQ. Self-signed certificate for localhost is generated by default.").
+		StringVar(&serveConfig.TLSCert)
+	serveCmd.Flag("server-key", "Path to the key of the TLS certificate used by DoT, DoH and DoQ.").StringVar(&serveConfig.TLSKey)
+	serveCmd.Flag("latency", "Latency added to each response.").Default("0s").DurationVar(&serveConfig.Latency)
+	serveCmd.Flag("jitter", "Maximum random latency added to each response on top of --latency.").Default("0s").DurationVar(&serveConfig.Jitter)
+	serveCmd.Flag("error-rate", "Fraction of queries (0-1) answered with --error-rcode.").Default("0").Float64Var(&serveConfig.ErrorRate)
+	serveCmd.Flag("error-rcode", "Response code of the injected errors.").Default("SERVFAIL").StringVar(&serveRcode)
+	serveCmd.Flag("drop-rate", "Fraction of queries (0-1), which are not answered at all.").Default("0").Float64Var(&serveConfig.DropRate)
+	serveCmd.Flag("truncate-rate", "Fraction of UDP queries (0-1) answered with empty truncated response.").Default("0").Float64Var(&serveConfig.TruncateRate)
+	serveCmd.Flag("zone", "Zone file in RFC 1035 format used for answering the queries. By default, A and AAAA records pointing to localhost are returned for any name.").
+		StringVar(&serveConfig.ZoneFile)
 
 	info, ok := debug.ReadBuildInfo()
 	if ok && len(Version) == 0 {
@@ -210,8 +439,37 @@ func init() {
 // Execute starts main logic of command.
 func Execute() {
 	pApp.Version(Version)
+	if err := applyConfigFile(os.Args[1:]); err != nil {
+		pApp.Fatalf("%s", err.Error())
+	}
 	parsed := kingpin.MustParse(pApp.Parse(os.Args[1:]))
 
+	if parsed == benchmarkCmd.FullCommand() && len(benchmark.Queries) == 0 && !benchmark.DNSSECValidation {
+		// queries are not needed only by the DNSSEC validation benchmark, which uses its own domains
+		pApp.Fatalf("required argument 'queries' not provided, try --help")
+	}
+
+	if benchmark.Repeat < 1 {
+		pApp.Fatalf("--repeat must be at least 1")
+	}
+	if benchmark.Interleave && len(benchmark.BatchJSON) == 0 {
+		pApp.Fatalf("--interleave can be used only with --batch-json")
+	}
+	if *distributedAgents > 0 {
+		switch {
+		case len(benchmark.BatchJSON) > 0:
+			pApp.Fatalf("--agents cannot be used with --batch-json")
+		case benchmark.Repeat > 1:
+			pApp.Fatalf("--agents cannot be used with --repeat")
+		case benchmark.Format == dnsbench.FormatJUnit || benchmark.Format == dnsbench.FormatTAP:
+			pApp.Fatalf("--agents supports only standard and json formats")
+		}
+	}
+
+	if _, err := reporter.ParseThresholds(benchmark.SLOs); err != nil {
+		pApp.Fatalf("invalid --slo: %s", err.Error())
+	}
+
 	// Handle frontend command
 	if parsed == frontendCmd.FullCommand() {
 		config := FrontendConfig{
@@ -228,8 +486,76 @@ func Execute() {
 		return
 	}
 
+	if parsed == proxyCmd.FullCommand() {
+		if err := runProxy(*proxyListen, *proxyUpstream, benchmark.Faults); err != nil {
+			printutils.ErrFprintf(os.Stderr, "Proxy error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parsed == planCmd.FullCommand() {
+		passed, err := runPlan(*planFile)
+		if err != nil {
+			printutils.ErrFprintf(os.Stderr, "Test plan error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parsed == compareCmd.FullCommand() {
+		passed, err := runCompare(*compareBaseline, *compareCurrent)
+		if err != nil {
+			printutils.ErrFprintf(os.Stderr, "Compare error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parsed == agentCmd.FullCommand() {
+		if err := runAgent(*agentCoordinator, *agentName, *agentToken); err != nil {
+			printutils.ErrFprintf(os.Stderr, "Agent error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parsed == mergeCmd.FullCommand() {
+		if err := runMerge(*mergeResults); err != nil {
+			printutils.ErrFprintf(os.Stderr, "Merge error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if parsed == serveCmd.FullCommand() {
+		if err := runServer(serveConfig, serveRcode); err != nil {
+			printutils.ErrFprintf(os.Stderr, "Server error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Handle benchmark command (default behavior)
 
+	if *distributedAgents > 0 {
+		passed, err := runCoordinator(*distributedAgents, *coordinatorListen, *coordinatorToken, *coordinatorStartDelay, *coordinatorAgentTimeout)
+		if err != nil {
+			printutils.ErrFprintf(os.Stderr, "Distributed benchmark error: %s\n", err.Error())
+			os.Exit(1)
+		}
+		if !passed {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check if batch JSON is requested
 	if len(benchmark.BatchJSON) > 0 {
 		if err := runBatchBenchmark(benchmark.BatchJSON); err != nil {
@@ -258,17 +584,16 @@ func Execute() {
 		os.Exit(1)
 	}()
 
-	start := time.Now()
-	res, err := benchmark.Run(ctx)
-	end := time.Now()
-
+	trials, err := runTrials(ctx, &benchmark)
 	if err != nil {
 		printutils.ErrFprintf(os.Stderr, "There was an error while starting benchmark: %s\n", err.Error())
 		close(sigsInt)
 		os.Exit(1)
 	}
+	res, duration := reporter.MergeTrials(trials)
 
-	if err := reporter.PrintReport(&benchmark, res, start, end.Sub(start), getServerGeocode(benchmark.Server)); err != nil {
+	assertions, err := printReport(&benchmark, trials, getServerGeocode(benchmark.Server))
+	if err != nil {
 		printutils.ErrFprintf(os.Stderr, "There was an error while printing report: %s\n", err.Error())
 		close(sigsInt)
 		os.Exit(1)
@@ -277,7 +602,7 @@ func Execute() {
 	// Handle HTML output if specified
 	if benchmark.HTML != "" {
 		stats := reporter.Merge(&benchmark, res)
-		jsonData, err := generateJSONForHTML(&stats, &benchmark, end.Sub(start))
+		jsonData, err := generateJSONForHTML(&stats, &benchmark, duration)
 		if err != nil {
 			printutils.ErrFprintf(os.Stderr, "Failed to generate JSON for HTML output: %s\n", err.Error())
 		} else if err := OutputHTML(benchmark.HTML, jsonData); err != nil {
@@ -287,28 +612,16 @@ func Execute() {
 
 	close(sigsInt)
 
-	if len(failConditions) > 0 {
-		stats := reporter.Merge(&benchmark, res)
-		for _, f := range failConditions {
-			switch f {
-			case ioerrorFailCondition:
-				if stats.Counters.IOError > 0 {
-					os.Exit(1)
-				}
-			case negativeFailCondition:
-				if stats.Counters.Negative > 0 {
-					os.Exit(1)
-				}
-			case errorFailCondition:
-				if stats.Counters.Error > 0 {
-					os.Exit(1)
-				}
-			case idmismatchFailCondition:
-				if stats.Counters.IDmismatch > 0 {
-					os.Exit(1)
+	if !assertions.Passed() {
+		if benchmark.Format != dnsbench.FormatStandard || benchmark.Silent {
+			// the standard report already contains the explanation
+			for _, r := range assertions.SLO {
+				if !r.Passed {
+					printutils.ErrFprintf(os.Stderr, "SLO violated: %s\n", r.Explanation)
 				}
 			}
 		}
+		os.Exit(1)
 	}
 }
 
@@ -338,6 +651,7 @@ func generateJSONForHTML(stats *reporter.BenchmarkResultStats, b *dnsbench.Bench
 			if bar.Count > 0 {
 				res = append(res, map[string]interface{}{
 					"latencyMs": time.Duration(bar.To).Milliseconds(),
+					"latencyUs": float64(bar.To) / float64(time.Microsecond),
 					"count":     bar.Count,
 				})
 			}
@@ -353,15 +667,16 @@ func generateJSONForHTML(stats *reporter.BenchmarkResultStats, b *dnsbench.Bench
 		TotalIOErrors:         stats.Counters.IOError,
 		QueriesPerSecond:      math.Round(float64(stats.Counters.Total)/benchDuration.Seconds()*100) / 100,
 		LatencyStats: scoring.LatencyMetrics{
-			MeanMs: time.Duration(stats.Hist.Mean()).Milliseconds(),
-			StdMs:  time.Duration(stats.Hist.StdDev()).Milliseconds(),
-			P50Ms:  time.Duration(stats.Hist.ValueAtQuantile(50)).Milliseconds(),
-			P95Ms:  time.Duration(stats.Hist.ValueAtQuantile(95)).Milliseconds(),
+			MeanMs: stats.Hist.Mean() / float64(time.Millisecond),
+			StdMs:  stats.Hist.StdDev() / float64(time.Millisecond),
+			P50Ms:  float64(stats.Hist.ValueAtQuantile(50)) / float64(time.Millisecond),
+			P95Ms:  float64(stats.Hist.ValueAtQuantile(95)) / float64(time.Millisecond),
 		},
 	}
 	scoreResult := scoring.CalculateScore(metrics)
 
 	serverResult := map[string]interface{}{
+		"schemaVersion":            reporter.JSONSchemaVersion,
 		"totalRequests":            stats.Counters.Total,
 		"totalSuccessResponses":    stats.Counters.Success,
 		"totalNegativeResponses":   stats.Counters.Negative,
@@ -386,6 +701,15 @@ func generateJSONForHTML(stats *reporter.BenchmarkResultStats, b *dnsbench.Bench
 			"p90Ms":  time.Duration(stats.Hist.ValueAtQuantile(90)).Milliseconds(),
 			"p75Ms":  time.Duration(stats.Hist.ValueAtQuantile(75)).Milliseconds(),
 			"p50Ms":  time.Duration(stats.Hist.ValueAtQuantile(50)).Milliseconds(),
+			"minUs":  float64(stats.Hist.Min()) / float64(time.Microsecond),
+			"meanUs": stats.Hist.Mean() / float64(time.Microsecond),
+			"stdUs":  stats.Hist.StdDev() / float64(time.Microsecond),
+			"maxUs":  float64(stats.Hist.Max()) / float64(time.Microsecond),
+			"p99Us":  float64(stats.Hist.ValueAtQuantile(99)) / float64(time.Microsecond),
+			"p95Us":  float64(stats.Hist.ValueAtQuantile(95)) / float64(time.Microsecond),
+			"p90Us":  float64(stats.Hist.ValueAtQuantile(90)) / float64(time.Microsecond),
+			"p75Us":  float64(stats.Hist.ValueAtQuantile(75)) / float64(time.Microsecond),
+			"p50Us":  float64(stats.Hist.ValueAtQuantile(50)) / float64(time.Microsecond),
 		},
 		"latencyDistribution":        histogramPoints,
 		"dohHTTPResponseStatusCodes": stats.DoHStatusCodes,
@@ -589,10 +913,14 @@ func OutputHTML(outputPath string, resultString string) error {
 
     <script>
         const data = __JSON_DATA_PLACEHOLDER__;
+
+        // latencies in microseconds are available since schema version 2, older results contain only latencies rounded to milliseconds
+        const latencyMs = (stats, stat) => stats[stat + 'Us'] !== undefined ? stats[stat + 'Us'] / 1000 : stats[stat + 'Ms'];
+        const formatLatency = (ms) => Number(ms.toFixed(3)) + 'ms';
         
         // Update metric cards
         document.getElementById('totalScore').textContent = data.score.total.toFixed(1);
-        document.getElementById('avgLatency').textContent = data.latencyStats.meanMs;
+        document.getElementById('avgLatency').textContent = Number(latencyMs(data.latencyStats, 'mean').toFixed(3));
         document.getElementById('successRate').textContent = ((data.totalSuccessResponses / data.totalRequests) * 100).toFixed(2);
         document.getElementById('qpsValue').textContent = data.queriesPerSecond.toFixed(1);
 
@@ -631,7 +959,7 @@ func OutputHTML(outputPath string, resultString string) error {
         // Create latency distribution chart if data is available
         if (data.latencyDistribution && data.latencyDistribution.length > 0) {
             const latencyCtx = document.getElementById('latencyChart').getContext('2d');
-            const latencyLabels = data.latencyDistribution.map(item => item.latencyMs + 'ms');
+            const latencyLabels = data.latencyDistribution.map(item => formatLatency(latencyMs(item, 'latency')));
             const latencyCounts = data.latencyDistribution.map(item => item.count);
             
             new Chart(latencyCtx, {
@@ -681,10 +1009,10 @@ func OutputHTML(outputPath string, resultString string) error {
             ['错误响应', data.totalErrorResponses, 'DNS服务器返回错误的查询数'],
             ['IO错误', data.totalIOErrors, '网络IO错误的查询数'],
             ['测试时长', data.benchmarkDurationSeconds.toFixed(2) + '秒', '基准测试持续时间'],
-            ['平均延迟', data.latencyStats.meanMs + 'ms', '所有查询的平均响应时间'],
-            ['P50延迟', data.latencyStats.p50Ms + 'ms', '50%查询的响应时间在此值以下'],
-            ['P95延迟', data.latencyStats.p95Ms + 'ms', '95%查询的响应时间在此值以下'],
-            ['P99延迟', data.latencyStats.p99Ms + 'ms', '99%查询的响应时间在此值以下']
+            ['平均延迟', formatLatency(latencyMs(data.latencyStats, 'mean')), '所有查询的平均响应时间'],
+            ['P50延迟', formatLatency(latencyMs(data.latencyStats, 'p50')), '50%查询的响应时间在此值以下'],
+            ['P95延迟', formatLatency(latencyMs(data.latencyStats, 'p95')), '95%查询的响应时间在此值以下'],
+            ['P99延迟', formatLatency(latencyMs(data.latencyStats, 'p99')), '99%查询的响应时间在此值以下']
         ];
 
         details.forEach(([metric, value, description]) => {
@@ -714,6 +1042,8 @@ func getServerGeocode(server string) string {
 	geoService, err := geo.NewGeoService()
 	if err == nil && geoService != nil {
 		defer geoService.Close()
+		// resolve the server hostname the same way as the benchmark does
+		geoService.LookupIP = benchmark.LookupIP
 		_, geoCode, err := geoService.CheckGeo(server, true)
 		if err == nil {
 			return geoCode
@@ -735,34 +1065,99 @@ func runBatchBenchmark(serverList string) error {
 	fmt.Fprintf(os.Stderr, "Starting batch benchmark for %d servers...\n", len(servers))
 
 	batchResults := make(map[string]interface{})
-
+	// JUnit and TAP formats report assertions of all the servers instead of the JSON results
+	assertionsFormat := benchmark.Format == dnsbench.FormatJUnit || benchmark.Format == dnsbench.FormatTAP
+	var suites []reporter.AssertionSuite
+
+	// batchRun holds the trials of a server, the benchmark is kept unmodified for the following trials and the result is
+	// the benchmark modified by the last trial used for reporting
+	type batchRun struct {
+		server    string
+		benchmark dnsbench.Benchmark
+		result    dnsbench.Benchmark
+		trials    []reporter.Trial
+		failed    reporter.Trial
+		err       error
+	}
+	var runs []*batchRun
 	for _, server := range servers {
 		server = strings.TrimSpace(server)
 		if server == "" {
 			continue
 		}
 
-		fmt.Fprintf(os.Stderr, "Testing server: %s\n", server)
-
 		// Create a copy of the global benchmark config for this server
 		serverBenchmark := benchmark
 		serverBenchmark.Server = server
 		serverBenchmark.JSON = true   // Force JSON output
 		serverBenchmark.Silent = true // Suppress normal output
+		serverBenchmark.JUnit = ""    // JUnit report of all the servers is written at the end
+		runs = append(runs, &batchRun{server: server, benchmark: serverBenchmark})
+	}
 
-		// Run benchmark for this server
-		ctx := context.Background()
-		start := time.Now()
-		res, err := serverBenchmark.Run(ctx)
-		end := time.Now()
-
+	repeat := max(benchmark.Repeat, 1)
+	runServerTrial := func(r *batchRun, trial int) {
+		if r.err != nil {
+			return
+		}
+		if repeat > 1 {
+			fmt.Fprintf(os.Stderr, "Testing server: %s (trial %d/%d)\n", r.server, trial, repeat)
+		} else {
+			fmt.Fprintf(os.Stderr, "Testing server: %s\n", r.server)
+		}
+		res, t, err := runTrial(context.Background(), r.benchmark)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error testing server %s: %v\n", server, err)
+			fmt.Fprintf(os.Stderr, "Error testing server %s: %v\n", r.server, err)
+			r.err, r.failed = err, t
+			return
+		}
+		r.result = res
+		r.trials = append(r.trials, t)
+		if len(r.trials) == repeat {
+			fmt.Fprintf(os.Stderr, "Completed testing server: %s\n", r.server)
+		}
+	}
+	if benchmark.Interleave {
+		for i := 1; i <= repeat; i++ {
+			for _, r := range runs {
+				runServerTrial(r, i)
+			}
+		}
+	} else {
+		for _, r := range runs {
+			for i := 1; i <= repeat; i++ {
+				runServerTrial(r, i)
+			}
+		}
+	}
+
+	scores := make(map[string]scoring.ScoreResult)
+	for _, r := range runs {
+		server := r.server
+		if r.err != nil {
+			suites = append(suites, reporter.AssertionSuite{Name: server, Start: r.failed.Start, Duration: r.failed.Duration, Error: r.err.Error()})
 			continue
 		}
+		res, duration := reporter.MergeTrials(r.trials)
+
+		if assertionsFormat || len(benchmark.JUnit) != 0 {
+			suite, err := reporter.EvaluateAssertions(&r.result, reporter.Merge(&r.result, res), r.trials[0].Start, duration)
+			if err != nil {
+				return err
+			}
+			suite.Name = server
+			suites = append(suites, suite)
+		}
+		if assertionsFormat {
+			continue
+		}
+
+		if repeat > 1 {
+			scores[server] = reporter.SummarizeTrials(&r.result, r.trials).Score()
+		}
 
 		// Generate JSON result for this server
-		jsonData, err := generateJSONForServer(&serverBenchmark, res, start, end.Sub(start), server)
+		jsonData, err := generateJSONForServer(&r.result, r.trials, server)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating JSON for server %s: %v\n", server, err)
 			continue
@@ -785,7 +1180,57 @@ func runBatchBenchmark(serverList string) error {
 				break
 			}
 		}
-		fmt.Fprintf(os.Stderr, "Completed testing server: %s\n", server)
+	}
+
+	if len(scores) > 0 {
+		// the ranking takes the confidence intervals of the scores into account, servers with overlapping intervals are tied
+		fmt.Fprintf(os.Stderr, "Ranking by mean score of %d trials with %.0f%% confidence intervals:\n", repeat, reporter.ConfidenceLevel*100)
+		for _, rank := range scoring.RankServers(scores) {
+			tied := ""
+			if rank.Tied {
+				tied = " (tied)"
+			}
+			ci := rank.Score.ConfidenceInterval
+			fmt.Fprintf(os.Stderr, "%3d. %s\t%.2f [%.2f, %.2f]%s\n", rank.Rank, rank.Server, rank.Score.Total, ci.Low, ci.High, tied)
+			if result, ok := batchResults[rank.Server].(map[string]interface{}); ok {
+				result["rank"] = rank.Rank
+				result["tied"] = rank.Tied
+			}
+		}
+	}
+
+	if len(benchmark.JUnit) != 0 {
+		f, err := os.Create(benchmark.JUnit)
+		if err != nil {
+			return fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+		err = reporter.WriteJUnit(f, suites)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+	}
+
+	if assertionsFormat {
+		write := reporter.WriteJUnit
+		if benchmark.Format == dnsbench.FormatTAP {
+			write = reporter.WriteTAP
+		}
+		if err := write(os.Stdout, suites); err != nil {
+			return err
+		}
+		failed := 0
+		for _, s := range suites {
+			if s.Failures() > 0 {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d servers failed", failed, len(suites))
+		}
+		return nil
 	}
 
 	// Output batch results as JSON to stdout
@@ -799,7 +1244,7 @@ func runBatchBenchmark(serverList string) error {
 }
 
 // generateJSONForServer generates JSON output for a single server benchmark result
-func generateJSONForServer(bench *dnsbench.Benchmark, res []*dnsbench.ResultStats, start time.Time, duration time.Duration, server string) ([]byte, error) {
+func generateJSONForServer(bench *dnsbench.Benchmark, trials []reporter.Trial, server string) ([]byte, error) {
 	// Use a buffer to capture the JSON output
 	var buf bytes.Buffer
 	originalWriter := bench.Writer
@@ -810,7 +1255,7 @@ func generateJSONForServer(bench *dnsbench.Benchmark, res []*dnsbench.ResultStat
 
 	// Generate the report which will write JSON to our buffer
 	geocode := getServerGeocode(server)
-	if err := reporter.PrintReport(bench, res, start, duration, geocode); err != nil {
+	if _, err := printReport(bench, trials, geocode); err != nil {
 		bench.Writer = originalWriter // Restore original writer
 		bench.Silent = originalSilent // Restore original silent flag
 		return nil, err
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/server"
+)
+
+// runServer starts local DNS server and serves the queries until interrupted.
+func runServer(config server.Config, rcode string) error {
+	code, ok := dns.StringToRcode[strings.ToUpper(rcode)]
+	if !ok {
+		return fmt.Errorf("unknown response code '%s'", rcode)
+	}
+	config.ErrorRcode = code
+
+	s, err := server.New(config)
+	if err != nil {
+		return err
+	}
+	if err := s.Start(); err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, l := range []struct{ name, addr string }{
+		{"UDP", s.Addr}, {"TCP", s.TCPAddr}, {"DoT", s.DoTAddr}, {"DoH", s.DoHAddr}, {"DoQ", s.DoQAddr},
+	} {
+		if len(l.addr) == 0 {
+			continue
+		}
+		addr := l.addr
+		if l.name == "DoH" {
+			addr = "https://" + addr + "/dns-query"
+		}
+		printutils.NeutralFprintf(os.Stdout, "Serving %s on %s\n", l.name, printutils.HighlightSprint(addr))
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	return nil
+}
@@ -65,6 +65,9 @@ const SERVER_TYPES = {
   DoQ: "doq"
 };
 
+// 结果格式版本2起提供微秒精度的延迟（如 meanUs），旧版本结果只有取整到毫秒的延迟（如 meanMs）
+const latencyMs = (stats, stat) => (stats?.[`${stat}Us`] !== undefined ? stats[`${stat}Us`] / 1000 : stats?.[`${stat}Ms`]);
+
 // 1. 添加防抖函数
 const useDebounce = (value, delay) => {
   const [debouncedValue, setDebouncedValue] = useState(value);
@@ -197,7 +200,7 @@ export default function Analyze() {
 
       const labels = Object.keys(filteredData);
       const scores = labels.map((server) => filteredData[server]?.score?.total ?? 0);
-      const latencies = labels.map((server) => filteredData[server]?.latencyStats?.meanMs ?? 0);
+      const latencies = labels.map((server) => latencyMs(filteredData[server]?.latencyStats, "mean") ?? 0);
       const successRates = labels.map((server) => filteredData[server]?.score?.successRate ?? 0);
       const qpsValues = labels.map((server) => filteredData[server]?.queriesPerSecond ?? 0);
 
@@ -0,0 +1,169 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// registerRetryInterval is the interval between registration attempts, when the coordinator is not reachable yet.
+const registerRetryInterval = time.Second
+
+// RunFunc runs the benchmark configured by the job and returns its JSON output.
+type RunFunc func(ctx context.Context, job Job) (json.RawMessage, error)
+
+// Agent runs the benchmark distributed by the coordinator.
+type Agent struct {
+	// Coordinator is the URL of the coordinator, e.g. http://10.0.0.1:8090.
+	Coordinator string
+	// Name identifies the agent in the reports of the coordinator, the coordinator generates one, when not set.
+	Name string
+	// Token is the shared secret of the coordinator configured by CoordinatorConfig.Token.
+	Token string
+	// Client is used for the requests to the coordinator, http.DefaultClient is used, when not set.
+	Client *http.Client
+}
+
+// Run registers the agent with the coordinator, waits for the job, runs it at the scheduled time using the run function
+// and reports the result to the coordinator. The registration is retried, until the coordinator is reachable.
+func (a *Agent) Run(ctx context.Context, run RunFunc) error {
+	id, err := a.register(ctx)
+	if err != nil {
+		return err
+	}
+	job, err := a.job(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(job.StartIn):
+	}
+
+	// the failure of the benchmark is reported to the coordinator as well, so it does not wait for the agent
+	var res Result
+	output, runErr := run(ctx, job)
+	if runErr != nil {
+		res.Error = runErr.Error()
+	} else {
+		res.Output = output
+	}
+	if err := a.post(ctx, strings.Replace(resultPath, "{id}", url.PathEscape(id), 1), res, nil); err != nil {
+		return fmt.Errorf("failed to report result: %w", err)
+	}
+	return runErr
+}
+
+func (a *Agent) register(ctx context.Context) (string, error) {
+	for {
+		var resp RegistrationResponse
+		err := a.post(ctx, registerPath, Registration{Name: a.Name}, &resp)
+		var statusErr *statusError
+		if err == nil || errors.As(err, &statusErr) {
+			// the coordinator is reachable, but it may refuse the agent
+			if err != nil {
+				return "", fmt.Errorf("failed to register: %w", err)
+			}
+			return resp.ID, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("failed to register: %w", err)
+		case <-time.After(registerRetryInterval):
+		}
+	}
+}
+
+// job waits for the job of the agent, the coordinator holds the request, until all the agents are registered.
+func (a *Agent) job(ctx context.Context, id string) (Job, error) {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url(strings.Replace(jobPath, "{id}", url.PathEscape(id), 1)), nil)
+		if err != nil {
+			return Job{}, err
+		}
+		resp, err := a.do(req)
+		if err != nil {
+			return Job{}, fmt.Errorf("failed to get job: %w", err)
+		}
+		if resp.StatusCode == http.StatusNoContent {
+			resp.Body.Close()
+			continue
+		}
+		var job Job
+		err = decodeResponse(resp, http.StatusOK, &job)
+		resp.Body.Close()
+		if err != nil {
+			return Job{}, fmt.Errorf("failed to get job: %w", err)
+		}
+		return job, nil
+	}
+}
+
+func (a *Agent) post(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url(path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return decodeResponse(resp, http.StatusNoContent, nil)
+	}
+	return decodeResponse(resp, http.StatusCreated, out)
+}
+
+func (a *Agent) do(req *http.Request) (*http.Response, error) {
+	if len(a.Token) != 0 {
+		req.Header.Set("Authorization", authScheme+a.Token)
+	}
+	return a.client().Do(req)
+}
+
+func (a *Agent) url(path string) string {
+	return strings.TrimSuffix(a.Coordinator, "/") + path
+}
+
+func (a *Agent) client() *http.Client {
+	if a.Client != nil {
+		return a.Client
+	}
+	return http.DefaultClient
+}
+
+// statusError is returned, when the coordinator responds with unexpected status code.
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("coordinator responded with status %d: %s", e.code, e.message)
+}
+
+func decodeResponse(resp *http.Response, status int, out interface{}) error {
+	if resp.StatusCode != status {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{code: resp.StatusCode, message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,321 @@
+package distributed
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	registerPath = "/v1/agents"
+	jobPath      = "/v1/agents/{id}/job"
+	resultPath   = "/v1/agents/{id}/result"
+
+	// DefaultAddr is the default address of the coordinator, it listens only on loopback, the address reachable by the agents
+	// on other hosts has to be configured explicitly.
+	DefaultAddr = "127.0.0.1:8090"
+	// DefaultStartDelay is the default delay between registration of the last agent and the start of the benchmark.
+	DefaultStartDelay = 2 * time.Second
+	// DefaultResultGracePeriod is the time the coordinator waits for the results of the agents after the configured duration
+	// of the benchmark elapses.
+	DefaultResultGracePeriod = time.Minute
+	// authScheme is the prefix of the Authorization header carrying the token.
+	authScheme = "Bearer "
+	// jobPollTimeout is the maximal time the job request is held by the coordinator, before the agent has to ask again.
+	jobPollTimeout = 30 * time.Second
+)
+
+// Registration is the request of the agent registering with the coordinator.
+type Registration struct {
+	Name string `json:"name"`
+}
+
+// RegistrationResponse is the response of the coordinator to the agent registration.
+type RegistrationResponse struct {
+	ID string `json:"id"`
+}
+
+// Job is the benchmark the agent is supposed to run.
+type Job struct {
+	// Config is the benchmark configuration in the format of the configuration file.
+	Config map[string]interface{} `json:"config"`
+	// StartIn is the time remaining until all the agents start the benchmark (in nanoseconds), it is relative, so the clocks
+	// of the agents do not need to be synchronized.
+	StartIn time.Duration `json:"startIn"`
+	// Agents is the number of agents running the benchmark.
+	Agents int `json:"agents"`
+}
+
+// Result is the result of the benchmark reported by the agent.
+type Result struct {
+	// Output is the JSON output of the benchmark.
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// AgentResult is the result reported by the registered agent.
+type AgentResult struct {
+	ID   string
+	Name string
+	Result
+}
+
+// CoordinatorConfig configures the coordinator.
+type CoordinatorConfig struct {
+	// Addr is the address of the HTTP listener of the coordinator, DefaultAddr is used, when not set.
+	Addr string
+	// Token is the shared secret, the agents have to present as bearer token in the Authorization header. The requests
+	// are not authenticated, when not set.
+	Token string
+	// Agents is the number of agents, the coordinator waits for, before the benchmark is started.
+	Agents int
+	// Config is the benchmark configuration distributed to the agents.
+	Config map[string]interface{}
+	// StartDelay is the delay between registration of the last agent and the start of the benchmark, so all the agents
+	// receive the job in time. DefaultStartDelay is used, when not set.
+	StartDelay time.Duration
+	// ResultTimeout is the maximal time between the start of the benchmark and the results of all the agents, the agents, which
+	// do not report their results in time (e.g. they crashed or lost connectivity), are reported as failed. No timeout is applied, when not set.
+	ResultTimeout time.Duration
+}
+
+type agent struct {
+	name   string
+	result *Result
+}
+
+// Coordinator distributes the benchmark to the registered agents and collects their results.
+type Coordinator struct {
+	// Addr is the actual address of the HTTP listener, it is set by Start.
+	Addr string
+
+	config     CoordinatorConfig
+	httpServer *http.Server
+
+	mu      sync.Mutex
+	agents  map[string]*agent
+	ids     []string
+	job     *Job
+	startAt time.Time
+	// ready is closed, when all the agents are registered, done is closed, when all the agents reported results
+	ready chan struct{}
+	done  chan struct{}
+}
+
+// NewCoordinator creates new Coordinator, the coordinator has to be started using Start.
+func NewCoordinator(config CoordinatorConfig) (*Coordinator, error) {
+	if config.Agents < 1 {
+		return nil, errors.New("number of agents must be at least 1")
+	}
+	if len(config.Addr) == 0 {
+		config.Addr = DefaultAddr
+	}
+	if config.StartDelay <= 0 {
+		config.StartDelay = DefaultStartDelay
+	}
+	c := &Coordinator{
+		config: config,
+		agents: make(map[string]*agent),
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+registerPath, c.handleRegister)
+	mux.HandleFunc("GET "+jobPath, c.handleJob)
+	mux.HandleFunc("POST "+resultPath, c.handleResult)
+	c.httpServer = &http.Server{Handler: c.authenticate(mux), ReadHeaderTimeout: 10 * time.Second}
+	return c, nil
+}
+
+// Start starts the HTTP listener of the coordinator.
+func (c *Coordinator) Start() error {
+	l, err := net.Listen("tcp", c.config.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", c.config.Addr, err)
+	}
+	c.Addr = l.Addr().String()
+	go func() {
+		_ = c.httpServer.Serve(l)
+	}()
+	return nil
+}
+
+// Close stops the HTTP listener of the coordinator.
+func (c *Coordinator) Close() error {
+	return c.httpServer.Close()
+}
+
+// Ready returns channel, which is closed, when all the agents are registered and the job is scheduled.
+func (c *Coordinator) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// StartAt returns the scheduled start of the benchmark, zero time is returned, when not all the agents are registered yet.
+func (c *Coordinator) StartAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startAt
+}
+
+// Wait waits until all the agents report their results and returns them in the order of registration. When the result timeout
+// elapses, the results reported so far are returned and the agents, which did not report, are returned with an error.
+func (c *Coordinator) Wait(ctx context.Context) ([]AgentResult, error) {
+	var timeout <-chan time.Time
+	if c.config.ResultTimeout > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.ready:
+		}
+		timer := time.NewTimer(time.Until(c.StartAt()) + c.config.ResultTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+	case <-timeout:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := make([]AgentResult, 0, len(c.ids))
+	for _, id := range c.ids {
+		a := c.agents[id]
+		res := AgentResult{ID: id, Name: a.name}
+		if a.result != nil {
+			res.Result = *a.result
+		} else {
+			res.Error = fmt.Sprintf("no result reported within %s after the start of the benchmark", c.config.ResultTimeout)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var reg Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		http.Error(w, "invalid registration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	if len(c.ids) >= c.config.Agents {
+		c.mu.Unlock()
+		http.Error(w, "all "+strconv.Itoa(c.config.Agents)+" agents are already registered", http.StatusConflict)
+		return
+	}
+	id := strconv.Itoa(len(c.ids) + 1)
+	if len(reg.Name) == 0 {
+		reg.Name = "agent-" + id
+	}
+	c.agents[id] = &agent{name: reg.Name}
+	c.ids = append(c.ids, id)
+	if len(c.ids) == c.config.Agents {
+		c.startAt = time.Now().Add(c.config.StartDelay)
+		c.job = &Job{Config: c.config.Config, Agents: c.config.Agents}
+		close(c.ready)
+	}
+	c.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, RegistrationResponse{ID: id})
+}
+
+// handleJob returns the job, when all the agents are registered, otherwise the request is held until they are, or until
+// the poll timeout, when no content is returned and the agent has to ask again.
+func (c *Coordinator) handleJob(w http.ResponseWriter, r *http.Request) {
+	if _, ok := c.agent(r.PathValue("id")); !ok {
+		http.Error(w, "unknown agent", http.StatusNotFound)
+		return
+	}
+	select {
+	case <-c.ready:
+	case <-r.Context().Done():
+		return
+	case <-time.After(jobPollTimeout):
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	c.mu.Lock()
+	job := *c.job
+	job.StartIn = max(time.Until(c.startAt), 0)
+	c.mu.Unlock()
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (c *Coordinator) handleResult(w http.ResponseWriter, r *http.Request) {
+	var res Result
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		http.Error(w, "invalid result: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(res.Output) == 0 && len(res.Error) == 0 {
+		http.Error(w, "result contains neither output nor error", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.agents[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "unknown agent", http.StatusNotFound)
+		return
+	}
+	if a.result != nil {
+		http.Error(w, "result already reported", http.StatusConflict)
+		return
+	}
+	a.result = &res
+	if c.reported() == c.config.Agents {
+		close(c.done)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate rejects the requests without the configured token.
+func (c *Coordinator) authenticate(next http.Handler) http.Handler {
+	if len(c.config.Token) == 0 {
+		return next
+	}
+	want := []byte(authScheme + c.config.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Coordinator) agent(id string) (*agent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.agents[id]
+	return a, ok
+}
+
+// reported returns number of agents, which reported their results, the caller must hold the lock.
+func (c *Coordinator) reported() int {
+	var n int
+	for _, a := range c.agents {
+		if a.result != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,203 @@
+package distributed_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/distributed"
+)
+
+func startCoordinator(t *testing.T, config distributed.CoordinatorConfig) *distributed.Coordinator {
+	t.Helper()
+	config.Addr = "127.0.0.1:0"
+	c, err := distributed.NewCoordinator(config)
+	require.NoError(t, err)
+	require.NoError(t, c.Start())
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func TestCoordinator(t *testing.T) {
+	config := map[string]interface{}{"server": "127.0.0.1", "queries": []interface{}{"example.com"}}
+	c := startCoordinator(t, distributed.CoordinatorConfig{Agents: 2, Config: config, StartDelay: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var started []time.Time
+	run := func(name string, err error) distributed.RunFunc {
+		return func(_ context.Context, job distributed.Job) (json.RawMessage, error) {
+			assert.Equal(t, config, job.Config)
+			assert.Equal(t, 2, job.Agents)
+			mu.Lock()
+			started = append(started, time.Now())
+			mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return json.RawMessage(`{"agent":"` + name + `"}`), nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, name := range []string{"first", "second"} {
+		var runErr error
+		if name == "second" {
+			runErr = errors.New("benchmark failed")
+		}
+		a := distributed.Agent{Coordinator: "http://" + c.Addr, Name: name}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = a.Run(ctx, run(name, runErr))
+		}()
+	}
+
+	results, err := c.Wait(ctx)
+	require.NoError(t, err)
+	wg.Wait()
+	require.NoError(t, errs[0])
+	require.EqualError(t, errs[1], "benchmark failed")
+
+	require.Len(t, results, 2)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	assert.Equal(t, "first", results[0].Name)
+	assert.JSONEq(t, `{"agent":"first"}`, string(results[0].Output))
+	assert.Empty(t, results[0].Error)
+	assert.Equal(t, "second", results[1].Name)
+	assert.Empty(t, results[1].Output)
+	assert.Equal(t, "benchmark failed", results[1].Error)
+
+	// the agents start at the same time, when the start delay elapses
+	require.Len(t, started, 2)
+	startAt := c.StartAt()
+	for _, s := range started {
+		assert.WithinDuration(t, startAt, s, 50*time.Millisecond)
+	}
+}
+
+func TestCoordinator_tooManyAgents(t *testing.T) {
+	c := startCoordinator(t, distributed.CoordinatorConfig{Agents: 1, StartDelay: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	release := make(chan struct{})
+	first := distributed.Agent{Coordinator: "http://" + c.Addr}
+	done := make(chan error, 1)
+	go func() {
+		done <- first.Run(ctx, func(context.Context, distributed.Job) (json.RawMessage, error) {
+			<-release
+			return json.RawMessage(`{}`), nil
+		})
+	}()
+	select {
+	case <-c.Ready():
+	case <-ctx.Done():
+		t.Fatal("agent did not register")
+	}
+
+	second := distributed.Agent{Coordinator: "http://" + c.Addr}
+	err := second.Run(ctx, func(context.Context, distributed.Job) (json.RawMessage, error) {
+		t.Error("rejected agent must not run the benchmark")
+		return nil, nil
+	})
+	require.ErrorContains(t, err, "409")
+
+	close(release)
+	require.NoError(t, <-done)
+	results, err := c.Wait(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "agent-1", results[0].Name)
+}
+
+func TestCoordinator_resultTimeout(t *testing.T) {
+	c := startCoordinator(t, distributed.CoordinatorConfig{Agents: 2, StartDelay: time.Millisecond, ResultTimeout: 200 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the second agent never reports its result, as if it crashed during the benchmark
+	hang, stop := context.WithCancel(ctx)
+	defer stop()
+	var wg sync.WaitGroup
+	for _, name := range []string{"reporting", "crashed"} {
+		a := distributed.Agent{Coordinator: "http://" + c.Addr, Name: name}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = a.Run(ctx, func(context.Context, distributed.Job) (json.RawMessage, error) {
+				if name == "crashed" {
+					<-hang.Done()
+					return nil, hang.Err()
+				}
+				return json.RawMessage(`{}`), nil
+			})
+		}()
+	}
+
+	results, err := c.Wait(ctx)
+	require.NoError(t, err)
+	stop()
+	wg.Wait()
+
+	require.Len(t, results, 2)
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	assert.Equal(t, "crashed", results[0].Name)
+	assert.Empty(t, results[0].Output)
+	assert.Equal(t, "no result reported within 200ms after the start of the benchmark", results[0].Error)
+	assert.Equal(t, "reporting", results[1].Name)
+	assert.JSONEq(t, `{}`, string(results[1].Output))
+	assert.Empty(t, results[1].Error)
+}
+
+func TestCoordinator_token(t *testing.T) {
+	c := startCoordinator(t, distributed.CoordinatorConfig{Agents: 1, Token: "secret", StartDelay: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, token := range []string{"", "invalid"} {
+		a := distributed.Agent{Coordinator: "http://" + c.Addr, Token: token}
+		err := a.Run(ctx, func(context.Context, distributed.Job) (json.RawMessage, error) {
+			t.Error("unauthenticated agent must not run the benchmark")
+			return nil, nil
+		})
+		require.ErrorContains(t, err, "401")
+	}
+
+	a := distributed.Agent{Coordinator: "http://" + c.Addr, Token: "secret"}
+	require.NoError(t, a.Run(ctx, func(context.Context, distributed.Job) (json.RawMessage, error) {
+		return json.RawMessage(`{}`), nil
+	}))
+	results, err := c.Wait(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.JSONEq(t, `{}`, string(results[0].Output))
+}
+
+func TestCoordinator_waitCanceled(t *testing.T) {
+	c := startCoordinator(t, distributed.CoordinatorConfig{Agents: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNewCoordinator_invalid(t *testing.T) {
+	_, err := distributed.NewCoordinator(distributed.CoordinatorConfig{})
+	require.Error(t, err)
+}
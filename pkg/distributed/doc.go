@@ -0,0 +1,4 @@
+// Package distributed contains coordinator and agent of the distributed benchmark, which generates load from multiple hosts.
+// The agents register with the coordinator over HTTP, receive the benchmark configuration and synchronized start time, run
+// the benchmark and report its JSON results including HDR histograms back, so the coordinator can merge them into a single result.
+package distributed
@@ -3,7 +3,7 @@ package dnsbench
 import (
 	"bufio"
 	"context"
-	"encoding/hex"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -14,7 +14,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +23,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/schollz/progressbar/v3"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
 	"go.uber.org/ratelimit"
 )
@@ -53,6 +54,15 @@ const (
 	// HTTP3Proto represents HTTP/3 protocol for DoH.
 	HTTP3Proto = "3"
 
+	// TLSVersion10 represents TLS 1.0.
+	TLSVersion10 = "1.0"
+	// TLSVersion11 represents TLS 1.1.
+	TLSVersion11 = "1.1"
+	// TLSVersion12 represents TLS 1.2.
+	TLSVersion12 = "1.2"
+	// TLSVersion13 represents TLS 1.3.
+	TLSVersion13 = "1.3"
+
 	// DefaultEdns0BufferSize default EDNS0 buffer size according to the http://www.dnsflagday.net/2020/
 	DefaultEdns0BufferSize = 1232
 
@@ -62,6 +72,15 @@ const (
 	// DefaultPlotFormat is a default format for plots.
 	DefaultPlotFormat = "svg"
 
+	// FormatStandard represents human-readable report format.
+	FormatStandard = "standard"
+	// FormatJSON represents JSON report format.
+	FormatJSON = "json"
+	// FormatJUnit represents report format, where the assertions are reported as JUnit XML test cases.
+	FormatJUnit = "junit"
+	// FormatTAP represents report format, where the assertions are reported in Test Anything Protocol.
+	FormatTAP = "tap"
+
 	// DefaultRequestTimeout is a default request timeout.
 	DefaultRequestTimeout = 5 * time.Second
 
@@ -119,6 +138,11 @@ type Benchmark struct {
 	// This is considered only for plain DNS over UDP or TCP and DoT.
 	QperConn int64
 
+	// Pipeline configures how many queries each worker keeps in flight on a single connection without waiting for the responses
+	// (RFC 7766 pipelining), the responses are matched to the queries by their IDs. Values greater than 1 enable pipelining.
+	// This is considered only for plain DNS over TCP and DoT.
+	Pipeline int
+
 	// Recurse configures whether the DNS queries generated by this Benchmark have Recursion Desired (RD) flag set.
 	Recurse bool
 
@@ -130,9 +154,39 @@ type Benchmark struct {
 	// EdnsOpt specifies EDNS option with code point code and optionally payload of value as a hexadecimal string in format code[:value].
 	// code must be an arbitrary numeric value.
 	EdnsOpt string
+	// EdnsOpts specifies additional EDNS options in the same format as EdnsOpt.
+	EdnsOpts []string
+	// Cookies controls whether DNS Cookies (RFC 7873) are sent. Each worker uses its own client cookie and reuses the server
+	// cookie returned by the server in the following queries.
+	Cookies bool
+	// Padding controls whether the queries are padded using EDNS padding option (RFC 7830) to the multiple of 128 octets
+	// as recommended by RFC 8467. This is considered only for encrypted transports, i.e. DoT, DoH and DoQ.
+	Padding bool
+	// EdnsKeepAlive controls whether EDNS TCP keepalive option (RFC 7828) is sent. This is considered only for plain DNS over TCP and DoT.
+	EdnsKeepAlive bool
+	// NSID controls whether NSID (RFC 5001) is requested, the returned NSIDs are recorded in ResultStats.NSIDs.
+	NSID bool
+	// InstanceID configures how the server instance answering the queries is identified, one of InstanceIDNSID, InstanceIDServer
+	// and InstanceIDHostnameBind. This is useful for anycast servers, the results by the instance are recorded in ResultStats.Instances.
+	// When CHAOS TXT record is used, additional query is sent after each answered query, the additional queries are not counted
+	// in the results, but they are subject to Rate and RateLimitWorker. When empty, the instances are not identified.
+	InstanceID string
+
+	// ECS configures EDNS Client Subnets (in CIDR notation) added to the queries, see ECSMode for how the subnets are used.
+	ECS []string
+	// ECSMode configures how the ECS subnets are assigned to the queries, one of ECSModeRotate, ECSModeRandom and ECSModeWorker.
+	// When empty, ECSModeRotate is used.
+	ECSMode string
 
 	// DNSSEC Allow DNSSEC (sets DO bit for all DNS requests to 1)
 	DNSSEC bool
+	// DNSSECValidation enables DNSSEC validation benchmark, which queries the domains of DNSSECCases instead of Queries (Queries
+	// must be empty) and checks whether the server validates the responses correctly. Half of the queries are sent with checking disabled (CD) flag
+	// set at random, so the latency overhead of the validation can be measured. Results are recorded in ResultStats.DNSSECValidation.
+	DNSSECValidation bool
+	// DNSSECCases configures test cases of the DNSSEC validation benchmark in format <expected result>:<domain>, where expected
+	// result is one of DNSSECSecure, DNSSECInsecure and DNSSECBogus. When empty, DefaultDNSSECCases are used.
+	DNSSECCases []string
 
 	// Edns0 configures EDNS0 usage in DNS requests send by benchmark and configures EDNS0 buffer size to the specified value. When 0 is configured, then EDNS0 is not used.
 	Edns0 uint16
@@ -143,6 +197,36 @@ type Benchmark struct {
 	// DOT controls whether DoT is used for the benchmark.
 	DOT bool
 
+	// SourceAddrs configures local IP addresses used as source addresses of plain DNS queries, the workers are spread
+	// over the addresses in round-robin fashion. Only addresses of the same IP family as the benchmarked server are used.
+	SourceAddrs []string
+	// SourceInterface configures network interface, whose IP addresses are used as source addresses of plain DNS queries
+	// in the same way as SourceAddrs.
+	SourceInterface string
+	// SourcePorts configures source port of plain DNS queries, either fixed port in format <port> or range in format <min>-<max>,
+	// from which the port is chosen randomly for each new socket. When empty, the port is chosen by the OS.
+	SourcePorts string
+
+	// Malformed controls whether the Benchmark sends malformed queries (see Mutations) instead of well-formed ones,
+	// in order to test robustness of the server. Reactions of the server are classified and reported in ResultStats.MalformedReactions.
+	// This is considered only for plain DNS over UDP or TCP and DoT.
+	Malformed bool
+	// MalformedSeed configures seed of the random generator choosing and creating the mutations, so the malformed queries
+	// can be reproduced. When 0, random seed is used.
+	MalformedSeed int64
+	// MalformedMutations configures mutations used for the malformed queries, all Mutations are used when empty.
+	MalformedMutations []string
+
+	// Faults configures network faults (packet loss, delay, jitter, reordering and duplication) injected by the proxy
+	// started between the workers and the Server. The injected faults are recorded in ResultStats.InjectedFaults.
+	// This is considered only for plain DNS and DoT, fallback servers are dialed directly.
+	Faults faultproxy.Config
+
+	// TCFallback controls whether the query is retried over TCP when UDP response has the truncated (TC) flag set, the same
+	// way as stub resolvers do. The latency of such query includes both UDP and TCP exchange.
+	// This is considered only for plain DNS over UDP.
+	TCFallback bool
+
 	// WriteTimeout configures write timeout for DNS requests generated by Benchmark.
 	WriteTimeout time.Duration
 	// ReadTimeout configures read timeout for DNS responses.
@@ -152,6 +236,19 @@ type Benchmark struct {
 	// RequestTimeout configures overall timeout for a single DNS request.
 	RequestTimeout time.Duration
 
+	// Retries configures how many times the query is retried when the attempt fails (IO error, timeout, SERVFAIL, NOTIMP
+	// or REFUSED response), emulating stub resolver behaviour. When 0, the queries are not retried.
+	// The end-to-end latency of the query including all attempts is limited by RequestTimeout.
+	Retries int
+	// RetryTimeout configures timeout of a single attempt, when retries are enabled. When 0, the attempt is limited only
+	// by RequestTimeout.
+	RetryTimeout time.Duration
+	// RetryBackoff configures delay before the first retry, the delay is doubled with each following retry.
+	RetryBackoff time.Duration
+	// FallbackServers configures servers, which are rotated with the benchmarked Server when retrying the queries.
+	// The fallback servers must be in the same format and use the same protocol as Server.
+	FallbackServers []string
+
 	// Rcodes controls whether ResultStats.Codes is filled in Benchmark results.
 	Rcodes bool
 
@@ -168,10 +265,29 @@ type Benchmark struct {
 	Csv string
 	// JSON controls whether the Benchmark.PrintReport prints the Benchmark results in JSON format (option is true).
 	JSON bool
+	// Format controls the format of the report printed by Benchmark.PrintReport. Supported values are FormatStandard, FormatJSON,
+	// FormatJUnit and FormatTAP, empty value means FormatStandard. JSON option takes precedence.
+	Format string
 	// BatchJSON specifies comma-separated list of DNS servers for batch testing and JSON generation.
 	BatchJSON string
+	// Repeat is the number of times the benchmark is run by the caller, the trials are reported together by reporter.PrintRepeatedReport.
+	Repeat int
+	// Interleave controls whether the trials of the batch benchmark alternate the servers (one trial of each server at a time) instead
+	// of running all the trials of a server before the next one.
+	Interleave bool
 	// HTML path to file, where the benchmark results are written as HTML report with embedded visualization.
 	HTML string
+	// SLOs are thresholds (e.g. p99<50ms, ioerror_ratio<0.1%, qps>=20000 or score>=80) evaluated against the results by reporter.PrintReport,
+	// see reporter.ParseThreshold for the supported format.
+	SLOs []string
+	// JUnit path to file, where the results of the SLOs and fail conditions evaluation are written in JUnit XML format.
+	JUnit string
+	// FailConditions are counters (ioerror, negative, error, idmismatch), which are asserted to be zero. The assertions are reported
+	// by JUnit and TAP reports, the exit code of the benchmark is up to the caller.
+	FailConditions []string
+	// Config is the effective configuration in the format of the configuration file (flag names and their values), which the benchmark
+	// was started with. It does not affect the benchmark, it is only embedded in the JSON output for reproducibility.
+	Config map[string]interface{}
 
 	// Silent controls whether the Benchmark.Run and Benchmark.PrintReport writes anything to stdout.
 	Silent bool
@@ -188,9 +304,49 @@ type Benchmark struct {
 	// DohProtocol controls HTTP protocol version used fo sending DoH requests. Supported values are "1.1", "2" and "3". Default is "1.1".
 	DohProtocol string
 
+	// DohMaxConns limits number of connections opened to the DoH server. For HTTP/1.1 it limits number of connections per host,
+	// for HTTP/2 and HTTP/3 the requests are distributed over exactly DohMaxConns connections. 0 means default transport behavior.
+	DohMaxConns int
+	// DohMaxStreams limits number of concurrent requests (streams) multiplexed over a single HTTP/2 or HTTP/3 connection.
+	// 0 means that the number of streams is not limited by the client.
+	DohMaxStreams int
+	// DohIdleTimeout configures how long an idle DoH connection is kept open. 0 means default transport behavior.
+	DohIdleTimeout time.Duration
+	// DohKeepAlive configures keep-alive period of DoH connections, it is TCP keep-alive for HTTP/1.1, PING health check for HTTP/2
+	// and QUIC keep-alive for HTTP/3. 0 means default transport behavior.
+	DohKeepAlive time.Duration
+	// DohDisableKeepAlive disables reuse of HTTP/1.1 connections, so each DoH request is sent using a new connection.
+	DohDisableKeepAlive bool
+
 	// Insecure disables server TLS certificate validation. Applicable for DoT, DoH and DoQ.
 	Insecure bool
 
+	// CACertFile is a path to PEM encoded CA bundle used for validating server certificates instead of the system CA pool.
+	// Applicable for DoT, DoH and DoQ.
+	CACertFile string
+	// ClientCertFile is a path to PEM encoded client certificate used for mutual TLS authentication. Must be used together with
+	// Benchmark.ClientKeyFile. Applicable for DoT, DoH and DoQ.
+	ClientCertFile string
+	// ClientKeyFile is a path to PEM encoded private key of the client certificate configured by Benchmark.ClientCertFile.
+	ClientKeyFile string
+	// ServerName overrides server name used for TLS SNI and server certificate validation. By default, the hostname of Benchmark.Server is used.
+	// Applicable for DoT, DoH and DoQ.
+	ServerName string
+	// TLSMinVersion configures minimum TLS version. Supported values are "1.0", "1.1", "1.2" and "1.3". By default, Go defaults are used.
+	TLSMinVersion string
+	// TLSMaxVersion configures maximum TLS version. Supported values are "1.0", "1.1", "1.2" and "1.3". By default, Go defaults are used.
+	TLSMaxVersion string
+	// TLSCipherSuites configures enabled TLS 1.0-1.2 cipher suites using their standard names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+	// TLS 1.3 cipher suites are not configurable. By default, Go defaults are used.
+	TLSCipherSuites []string
+
+	// Bootstrap configures plain DNS resolver used for resolving hostname of the benchmarked Server in format <IP>[:port],
+	// if port is not provided then port 53 is used. When empty, the OS resolver is used.
+	Bootstrap string
+	// Resolve configures static overrides of the hostname resolution in format <host>:<IP>, similarly to curl --resolve option.
+	// The overrides take precedence over Benchmark.Bootstrap. TLS SNI and HTTP Host header still use the original hostname.
+	Resolve []string
+
 	// ProgressBar controls whether the progress bar is printed.
 	ProgressBar bool
 
@@ -222,12 +378,23 @@ type Benchmark struct {
 	// internal variable so we do not have to parse the address with each request.
 	useDoH            bool
 	useQuic           bool
+	resolver          *hostResolver
+	tlsConfig         *tls.Config
 	requestDelayStart time.Duration
 	requestDelayEnd   time.Duration
+	fallbacks         []*Benchmark
+	source            *sourceBinder
+	ecsSubnets        []*dns.EDNS0_SUBNET
+	dnssecCases       map[string]string
+	faultProxy        *faultproxy.Proxy
 }
 
 type queryFunc func(context.Context, *dns.Msg) (*dns.Msg, error)
 
+// queryFuncFactory creates query function for the worker identified by workerID, details of connections owned by
+// the worker are recorded using the worker recorder.
+type queryFuncFactory func(workerID uint32, worker *connRecorder) queryFunc
+
 // init validates and normalizes Benchmark settings.
 func (b *Benchmark) init() error {
 	if b.Writer == nil {
@@ -268,23 +435,126 @@ func (b *Benchmark) init() error {
 		b.HistMax = b.RequestTimeout
 	}
 
+	switch {
+	case b.JSON:
+		b.Format = FormatJSON
+	case len(b.Format) == 0:
+		b.Format = FormatStandard
+	case b.Format == FormatJSON:
+		b.JSON = true
+	case b.Format != FormatStandard && b.Format != FormatJUnit && b.Format != FormatTAP:
+		return fmt.Errorf("unsupported report format '%s'", b.Format)
+	}
+
 	if b.Edns0 != 0 && (b.Edns0 < 512 || b.Edns0 > 4096) {
 		return errors.New("--edns0 must have value between 512 and 4096")
 	}
 
-	if len(b.EdnsOpt) != 0 {
-		split := strings.Split(b.EdnsOpt, ":")
-		if len(split) != 2 {
-			return errors.New("--ednsopt is not in correct format")
+	for _, opt := range b.ednsOpts() {
+		if _, err := parseEdnsOpt(opt); err != nil {
+			return err
 		}
-		_, err := hex.DecodeString(split[1])
+	}
+
+	if b.Padding && !b.useDoH && !b.useQuic && !b.DOT {
+		return errors.New("--edns-padding is supported only for encrypted transports (DoT, DoH and DoQ)")
+	}
+
+	if b.EdnsKeepAlive && (b.useDoH || b.useQuic || (!b.TCP && !b.DOT)) {
+		return errors.New("--edns-keepalive is supported only for plain DNS over TCP and DoT")
+	}
+
+	if b.Pipeline > 1 && (b.useDoH || b.useQuic || (!b.TCP && !b.DOT)) {
+		return errors.New("--pipeline is supported only for plain DNS over TCP and DoT")
+	}
+
+	if b.TCFallback && (b.useDoH || b.useQuic || b.TCP || b.DOT) {
+		return errors.New("--tc-fallback is supported only for plain DNS over UDP")
+	}
+
+	if (len(b.SourceAddrs) != 0 || len(b.SourceInterface) != 0 || len(b.SourcePorts) != 0) && (b.useDoH || b.useQuic || b.DOT) {
+		return errors.New("--source-addr, --source-interface and --source-port are supported only for plain DNS")
+	}
+
+	b.ecsSubnets = nil
+	for _, subnet := range b.ECS {
+		opt, err := parseECS(subnet)
 		if err != nil {
-			return errors.New("--ednsopt is not in correct format, data is not hexadecimal string")
+			return err
+		}
+		b.ecsSubnets = append(b.ecsSubnets, opt)
+	}
+	switch b.ECSMode {
+	case "":
+		b.ECSMode = ECSModeRotate
+	case ECSModeRotate, ECSModeRandom, ECSModeWorker:
+	default:
+		return fmt.Errorf("--ecs-mode '%s' is not supported, supported modes are %s, %s and %s", b.ECSMode, ECSModeRotate, ECSModeRandom, ECSModeWorker)
+	}
+
+	if b.Malformed {
+		if b.useDoH || b.useQuic || b.Pipeline > 1 {
+			return errors.New("--malformed is supported only for plain DNS and DoT without --pipeline")
+		}
+		for _, m := range b.MalformedMutations {
+			if _, ok := mutations[m]; !ok {
+				return fmt.Errorf("--malformed-mutation '%s' is not supported, supported mutations are %s", m, strings.Join(Mutations, ", "))
+			}
+		}
+		if b.MalformedSeed == 0 {
+			b.MalformedSeed = time.Now().UnixNano()
+		}
+	}
+
+	if b.DNSSECValidation {
+		if b.Malformed {
+			return errors.New("--dnssec-validation is not supported with --malformed")
 		}
-		_, err = strconv.ParseUint(split[0], 10, 16)
+		if len(b.Queries) > 0 {
+			return errors.New("--dnssec-validation does not send the provided queries, use --dnssec-case to specify the domains of the DNSSEC validation benchmark")
+		}
+		cases := b.DNSSECCases
+		if len(cases) == 0 {
+			cases = DefaultDNSSECCases
+		}
+		parsed, err := parseDNSSECCases(cases)
 		if err != nil {
-			return errors.New("--ednsopt is not in correct format, code is not a decimal number")
+			return err
 		}
+		b.dnssecCases = parsed
+		// validating resolvers return authenticated data only to the clients signalling DNSSEC support
+		b.DNSSEC = true
+	}
+
+	b.faultProxy = nil
+	if b.Faults.Enabled() {
+		if b.useDoH || b.useQuic {
+			return errors.New("fault injection is supported only for plain DNS and DoT")
+		}
+		if len(b.SourceAddrs) > 0 || len(b.SourceInterface) > 0 || len(b.SourcePorts) > 0 {
+			return errors.New("fault injection is not supported with --source-addr, --source-interface and --source-port")
+		}
+		if err := b.Faults.Validate(); err != nil {
+			return fmt.Errorf("invalid fault injection: %w", err)
+		}
+	}
+
+	switch b.InstanceID {
+	case "", InstanceIDNSID, InstanceIDServer, InstanceIDHostnameBind:
+	default:
+		return fmt.Errorf("--instance-id '%s' is not supported, supported values are %s, %s and %s", b.InstanceID,
+			InstanceIDNSID, InstanceIDServer, InstanceIDHostnameBind)
+	}
+	if len(b.InstanceID) > 0 && b.Malformed {
+		return errors.New("--instance-id is not supported with --malformed")
+	}
+
+	if b.Retries < 0 || b.RetryTimeout < 0 || b.RetryBackoff < 0 {
+		return errors.New("retry settings must not be negative")
+	}
+
+	if b.DohMaxConns < 0 || b.DohMaxStreams < 0 || b.DohIdleTimeout < 0 || b.DohKeepAlive < 0 {
+		return errors.New("DoH connection pool settings must not be negative")
 	}
 
 	if b.RequestLogEnabled && len(b.RequestLogPath) == 0 {
@@ -295,7 +565,29 @@ func (b *Benchmark) init() error {
 		return err
 	}
 
-	return nil
+	resolver, err := newHostResolver(b.Bootstrap, b.Resolve)
+	if err != nil {
+		return err
+	}
+	b.resolver = resolver
+
+	source, err := newSourceBinder(b.SourceAddrs, b.SourceInterface, b.SourcePorts)
+	if err != nil {
+		return err
+	}
+	if source != nil && source.maxPort != 0 && source.sockets() < int(b.Concurrency) {
+		// each worker holds its own socket, the workers without a free source port would fail all their queries
+		return fmt.Errorf("--source-port '%s' allows only %d sockets at once, which is less than %d concurrent workers, use port range with at least --concurrency ports",
+			b.SourcePorts, source.sockets(), b.Concurrency)
+	}
+	b.source = source
+
+	if err := b.initTLSConfig(); err != nil {
+		return err
+	}
+
+	// fallback servers share the already initialized settings
+	return b.initFallbacks()
 }
 
 // Run executes benchmark, if benchmark is unable to start the error is returned, otherwise array of results from parallel benchmark goroutines is returned.
@@ -340,7 +632,8 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 		defer cancel()
 	}
 
-	if !b.Silent && !b.JSON {
+	// only the standard report is human-readable, the other formats must not be mixed with the progress output
+	if !b.Silent && b.Format == FormatStandard {
 		printutils.NeutralFprintf(b.Writer, "Using %s hostnames\n", printutils.HighlightSprint(len(questions)))
 	}
 
@@ -349,7 +642,20 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 		qTypes = append(qTypes, dns.StringToType[v])
 	}
 
-	queryFactory := workerQueryFactory(b)
+	if b.Faults.Enabled() {
+		proxy, err := b.startFaultProxy(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start fault injection proxy: %w", err)
+		}
+		defer proxy.Close()
+	}
+
+	sharedConns := &connRecorder{}
+	queryFactory := workerQueryFactory(b, sharedConns)
+	fallbackFactories := make([]queryFuncFactory, 0, len(b.fallbacks))
+	for _, f := range b.fallbacks {
+		fallbackFactories = append(fallbackFactories, workerQueryFactory(f, sharedConns))
+	}
 
 	limits := ""
 	var limit ratelimit.Limiter
@@ -366,10 +672,13 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 		limits = fmt.Sprintf("(limited to %s QPS per concurrent worker)", printutils.HighlightSprint(b.RateLimitWorker))
 	}
 
-	if !b.Silent && !b.JSON {
+	if !b.Silent && b.Format == FormatStandard {
 		network := b.network()
 		printutils.NeutralFprintf(b.Writer, "Benchmarking %s via %s with %s concurrent requests %s\n",
 			printutils.HighlightSprint(b.Server), printutils.HighlightSprint(network), printutils.HighlightSprint(b.Concurrency), limits)
+		if b.Malformed {
+			printutils.NeutralFprintf(b.Writer, "Sending malformed queries using seed %s\n", printutils.HighlightSprint(b.MalformedSeed))
+		}
 	}
 
 	var bar *progressbar.ProgressBar
@@ -423,7 +732,102 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 				workerLimit = ratelimit.New(b.RateLimitWorker)
 			}
 
-			query := queryFactory()
+			workerConns := &connRecorder{}
+			defer workerConns.flush(st)
+			query := newRetryingQuery(b, queryFactory(workerID, workerConns), fallbackFactories, workerID, workerConns)
+			ecs := newECSSelector(b, workerID)
+			var cookies *cookieJar
+			if b.Cookies {
+				cookies = newCookieJar()
+			}
+			var malformed *malformedQuery
+			if b.Malformed {
+				malformed = newMalformedQuery(b, workerID)
+			}
+
+			// when pipelining, queries are sent concurrently by the worker and the number of queries in flight is limited
+			var inflight chan struct{}
+			var pending sync.WaitGroup
+			var stMu sync.Mutex
+			if b.Pipeline > 1 {
+				inflight = make(chan struct{}, b.Pipeline)
+			}
+			defer pending.Wait()
+
+			// identify returns the server instance, which returned the response, the CHAOS TXT queries are subject to the rate limits
+			// as the benchmark queries, so the server does not receive more queries than configured
+			identify := func(resp *dns.Msg) string {
+				if b.InstanceID != InstanceIDNSID {
+					if limit != nil {
+						if err := checkLimit(ctx, limit); err != nil {
+							return ""
+						}
+					}
+					if workerLimit != nil {
+						if err := checkLimit(ctx, workerLimit); err != nil {
+							return ""
+						}
+					}
+				}
+				return b.identifyInstance(ctx, query.queries[0], resp)
+			}
+
+			// exchange sends the query and records its results, false is returned if the benchmark was cancelled before sending the query
+			exchange := func(req *dns.Msg) bool {
+				start := time.Now()
+
+				reqTimeoutCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+				var out queryOutcome
+				if malformed != nil {
+					out = malformed.exchange(reqTimeoutCtx, req)
+				} else {
+					out = query.exchange(reqTimeoutCtx, req)
+				}
+				cancel()
+				resp, err := out.resp, out.err
+				if deadline, deadlineSet := reqTimeoutCtx.Deadline(); err != nil && deadlineSet && start.After(deadline) {
+					// Benchmark was cancelled before sending request, do not count this query results and end the worker
+					return false
+				}
+				dur := time.Since(start)
+				if b.RequestLogEnabled {
+					logRequest(workerID, *req, resp, err, dur)
+				}
+				var instance string
+				if err == nil && len(b.InstanceID) > 0 {
+					instance = identify(resp)
+				}
+				stMu.Lock()
+				if out.tcFallback {
+					st.recordTCFallback(out.tcFallbackErr, dur)
+				}
+				if out.mutation != "" {
+					st.recordMalformed(out.mutation, out.reaction)
+				}
+				if b.Retries > 0 && malformed == nil {
+					st.recordAttempts(out.attempts, out.firstAttempt)
+				}
+				st.record(req, resp, err, start, dur)
+				if err == nil && len(b.InstanceID) > 0 {
+					st.recordInstance(instance, dur)
+				}
+				if err == nil && b.DNSSECValidation {
+					st.recordDNSSEC(b.dnssecCases[req.Question[0].Name], req, resp, dur)
+				}
+				if err == nil {
+					st.recordECS(req, resp)
+					if cookies != nil {
+						cookies.update(resp)
+					}
+				}
+				stMu.Unlock()
+				b.measureProm(*req, resp, dur, err)
+
+				if incrementBar {
+					bar.Add(1)
+				}
+				return true
+			}
 
 			for i := int64(0); i < b.Count || b.Duration != 0; i++ {
 				for _, q := range questions {
@@ -462,8 +866,12 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 						if b.Edns0 > 0 {
 							req.SetEdns0(b.Edns0, false)
 						}
-						if ednsOpt := b.EdnsOpt; len(ednsOpt) > 0 {
-							addEdnsOpt(&req, ednsOpt)
+						b.addEdnsOptions(&req)
+						if cookies != nil {
+							cookies.apply(&req)
+						}
+						if ecs != nil {
+							ecs.apply(rando, &req)
 						}
 						if b.DNSSEC {
 							edns0 := req.IsEdns0()
@@ -473,25 +881,31 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 							}
 							edns0.SetDo(true)
 						}
-
-						start := time.Now()
-
-						reqTimeoutCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
-						resp, err := query(reqTimeoutCtx, &req)
-						cancel()
-						if deadline, deadlineSet := reqTimeoutCtx.Deadline(); err != nil && deadlineSet && start.After(deadline) {
-							// Benchmark was cancelled before sending request, do not count this query results and end the worker
-							return
+						if b.DNSSECValidation {
+							req.CheckingDisabled = rando.Intn(2) == 1
 						}
-						dur := time.Since(start)
-						if b.RequestLogEnabled {
-							logRequest(workerID, req, resp, err, dur)
+						if b.Padding {
+							addPadding(&req)
 						}
-						st.record(&req, resp, err, start, dur)
-						b.measureProm(req, resp, dur, err)
 
-						if incrementBar {
-							bar.Add(1)
+						if inflight == nil {
+							if !exchange(&req) {
+								return
+							}
+						} else {
+							select {
+							case inflight <- struct{}{}:
+							case <-ctx.Done():
+								return
+							}
+							pending.Add(1)
+							go func(req dns.Msg) {
+								defer func() {
+									<-inflight
+									pending.Done()
+								}()
+								exchange(&req)
+							}(req)
 						}
 
 						b.delay(ctx, rando)
@@ -506,6 +920,15 @@ func (b *Benchmark) Run(ctx context.Context) ([]*ResultStats, error) {
 		_ = bar.Exit()
 	}
 
+	if len(stats) > 0 {
+		// connections shared between workers are attributed to the first worker
+		sharedConns.flush(stats[0])
+		if b.faultProxy != nil {
+			faults := b.faultProxy.Stats()
+			stats[0].InjectedFaults = &faults
+		}
+	}
+
 	return stats, nil
 }
 
@@ -590,18 +1013,6 @@ func (b *Benchmark) network() string {
 	return network
 }
 
-func addEdnsOpt(m *dns.Msg, ednsOpt string) {
-	o := m.IsEdns0()
-	if o == nil {
-		m.SetEdns0(DefaultEdns0BufferSize, false)
-		o = m.IsEdns0()
-	}
-	s := strings.Split(ednsOpt, ":")
-	data, _ := hex.DecodeString(s[1])
-	code, _ := strconv.ParseUint(s[0], 10, 16)
-	o.Option = append(o.Option, &dns.EDNS0_LOCAL{Code: uint16(code), Data: data})
-}
-
 func (b *Benchmark) addPortIfMissing() {
 	if b.useDoH {
 		// both HTTPS and HTTP are using default ports 443 and 80 if no other port is specified
@@ -635,6 +1046,13 @@ func isHTTPUrl(s string) (ok bool, network string) {
 
 func (b *Benchmark) prepareQuestions() ([]string, error) {
 	var questions []string
+	if b.DNSSECValidation {
+		for k := range b.dnssecCases {
+			questions = append(questions, k)
+		}
+		sort.Strings(questions)
+		return questions, nil
+	}
 	for _, q := range b.Queries {
 		if ok, _ := isHTTPUrl(q); ok {
 			resp, err := client.Get(q)
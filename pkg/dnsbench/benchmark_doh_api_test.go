@@ -490,3 +490,143 @@ func (suite *DoHTestSuite) TestBenchmark_Run_separate_connections() {
 		})
 	}
 }
+
+func (suite *DoHTestSuite) TestBenchmark_Run_connection_pool() {
+	tests := []struct {
+		name                    string
+		protocol                string
+		maxConns                int
+		maxStreams              int
+		disableKeepAlive        bool
+		wantNumberOfConnections int
+		wantMaxStreamsInFlight  int
+	}{
+		{
+			name:                    "HTTP/2 limited connections and streams",
+			protocol:                dnsbench.HTTP2Proto,
+			maxConns:                2,
+			maxStreams:              1,
+			wantNumberOfConnections: 2,
+			wantMaxStreamsInFlight:  1,
+		},
+		{
+			name:                    "HTTP/2 single connection with limited streams",
+			protocol:                dnsbench.HTTP2Proto,
+			maxStreams:              2,
+			wantNumberOfConnections: 1,
+			wantMaxStreamsInFlight:  2,
+		},
+		{
+			name:                    "HTTP/1.1 without keep-alive",
+			protocol:                dnsbench.HTTP1Proto,
+			disableKeepAlive:        true,
+			wantNumberOfConnections: 8,
+			wantMaxStreamsInFlight:  1,
+		},
+	}
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+			suite.Require().NoError(err)
+
+			config := tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS12,
+			}
+
+			mutex := sync.Mutex{}
+			inFlight := make(map[string]int)
+			maxInFlight := 0
+			remoteAddrs := make(map[string]int)
+
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				remoteAddrs[r.RemoteAddr]++
+				inFlight[r.RemoteAddr]++
+				maxInFlight = max(maxInFlight, inFlight[r.RemoteAddr])
+				mutex.Unlock()
+
+				defer func() {
+					mutex.Lock()
+					inFlight[r.RemoteAddr]--
+					mutex.Unlock()
+				}()
+
+				bd, err := io.ReadAll(r.Body)
+				if err != nil {
+					panic(err)
+				}
+
+				msg := dns.Msg{}
+				err = msg.Unpack(bd)
+				if err != nil {
+					panic(err)
+				}
+
+				msg.Answer = append(msg.Answer, A("example.org. IN A 127.0.0.1"))
+
+				pack, err := msg.Pack()
+				if err != nil {
+					panic(err)
+				}
+
+				// wait some time, so the requests are overlapping
+				time.Sleep(50 * time.Millisecond)
+
+				_, err = w.Write(pack)
+				if err != nil {
+					panic(err)
+				}
+			}))
+			ts.EnableHTTP2 = true
+			ts.TLS = &config
+			ts.StartTLS()
+			defer ts.Close()
+
+			bench := dnsbench.Benchmark{
+				Queries:             []string{"example.org"},
+				Types:               []string{"A"},
+				Server:              ts.URL,
+				DohProtocol:         tt.protocol,
+				DohMaxConns:         tt.maxConns,
+				DohMaxStreams:       tt.maxStreams,
+				DohDisableKeepAlive: tt.disableKeepAlive,
+				Concurrency:         4,
+				Count:               2,
+				Probability:         1,
+				WriteTimeout:        1 * time.Second,
+				ReadTimeout:         3 * time.Second,
+				ConnectTimeout:      1 * time.Second,
+				RequestTimeout:      5 * time.Second,
+				Rcodes:              true,
+				Recurse:             true,
+				DohMethod:           dnsbench.PostHTTPMethod,
+				Silent:              true,
+				Insecure:            true,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+
+			// close right away to mitigate race detector failures
+			ts.Close()
+
+			suite.Require().NoError(err, "expected no error from benchmark run")
+			suite.Require().Len(rs, 4)
+			var opened, streams int64
+			for _, v := range rs {
+				suite.Empty(v.Errors)
+				if v.DoHConnections != nil {
+					opened += v.DoHConnections.Opened
+					streams += v.DoHConnections.Streams
+				}
+			}
+			suite.Len(remoteAddrs, tt.wantNumberOfConnections)
+			suite.Equal(tt.wantMaxStreamsInFlight, maxInFlight)
+
+			suite.EqualValues(tt.wantNumberOfConnections, opened, "each opened connection should be reported")
+			suite.EqualValues(8, streams, "each request should be attributed to a connection")
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -196,3 +198,173 @@ func (suite *DoTTestSuite) TestBenchmark_Run_error() {
 	suite.EqualValues(2, rs[1].Counters.Total, "there should be executions")
 	suite.EqualValues(2, rs[1].Counters.IOError, "there should be errors")
 }
+
+func (suite *DoTTestSuite) TestBenchmark_Run_resolve() {
+	cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+	suite.Require().NoError(err)
+
+	var mu sync.Mutex
+	var sni []string
+	config := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			sni = append(sni, hello.ServerName)
+			return nil, nil
+		},
+	}
+
+	server := NewServer(dnsbench.TLSTransport, &config, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Addr)
+	suite.Require().NoError(err)
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         net.JoinHostPort("dns.test", port),
+		Resolve:        []string{"dns.test:127.0.0.1"},
+		Concurrency:    1,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Insecure:       true,
+		DOT:            true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(1, rs[0].Counters.Success, "query should be answered by the server resolved using override")
+	suite.Equal([]string{"dns.test"}, sni, "SNI should contain the original hostname")
+}
+
+func (suite *DoTTestSuite) TestBenchmark_Run_mutualTLS() {
+	cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+	suite.Require().NoError(err)
+
+	certs, err := os.ReadFile("testdata/test.crt")
+	suite.Require().NoError(err)
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certs)
+	config := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	server := NewServer(dnsbench.TLSTransport, &config, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:         []string{"example.org"},
+		Types:           []string{"A"},
+		Server:          server.Addr,
+		Concurrency:     1,
+		Count:           1,
+		Probability:     1,
+		WriteTimeout:    1 * time.Second,
+		ReadTimeout:     3 * time.Second,
+		ConnectTimeout:  1 * time.Second,
+		RequestTimeout:  5 * time.Second,
+		Rcodes:          true,
+		Recurse:         true,
+		DOT:             true,
+		CACertFile:      "testdata/test.crt",
+		ClientCertFile:  "testdata/test.crt",
+		ClientKeyFile:   "testdata/test.key",
+		ServerName:      "localhost",
+		TLSMinVersion:   dnsbench.TLSVersion12,
+		TLSMaxVersion:   dnsbench.TLSVersion12,
+		TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		Silent:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(1, rs[0].Counters.Success, "query should be answered over mutual TLS")
+	suite.EqualValues(0, rs[0].Counters.IOError, "there should be no errors")
+}
+
+func (suite *DoTTestSuite) TestBenchmark_Run_tlsHandshakes() {
+	cert, err := tls.LoadX509KeyPair("testdata/test.crt", "testdata/test.key")
+	suite.Require().NoError(err)
+
+	config := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	server := NewServer(dnsbench.TLSTransport, &config, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer server.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A", "AAAA"},
+		Server:         server.Addr,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		QperConn:       1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Insecure:       true,
+		DOT:            true,
+		TLSMaxVersion:  dnsbench.TLSVersion12,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+
+	for _, r := range rs {
+		suite.Require().NotNil(r.TLS)
+		suite.EqualValues(2, r.TLS.Handshakes, "each query should be sent using a new connection")
+		suite.Equal(map[string]int64{"TLS 1.2": 2}, r.TLS.Versions)
+		suite.NotEmpty(r.TLS.CipherSuites)
+		suite.Zero(r.TLS.OCSPStapled)
+		suite.Require().Len(r.TLS.Certificates, 1, "the same certificate should be kept once")
+		suite.Contains(r.TLS.Certificates[0].Subject, "CN=localhost")
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/suite"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 )
 
 type PlainDNSTestSuite struct {
@@ -697,6 +700,755 @@ func (suite *PlainDNSTestSuite) TestBenchmark_Run_truncated() {
 	suite.EqualValues(2, rs[1].Counters.Truncated, "there should be truncated messages")
 }
 
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_truncated_tcFallback() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Truncated = true
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	// TCP server listening on the same port as the UDP server returns full answer
+	started := make(chan struct{})
+	tcpServer := &dns.Server{Net: dnsbench.TCPTransport, Addr: s.Addr, NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+			w.WriteMsg(ret)
+		})}
+	go tcpServer.ListenAndServe()
+	<-started
+	defer tcpServer.Shutdown()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A", "AAAA"},
+		Server:         s.Addr,
+		TCFallback:     true,
+		Concurrency:    2,
+		Count:          1,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+
+	for _, r := range rs {
+		suite.EqualValues(2, r.Counters.Total, "there should be executions")
+		suite.EqualValues(2, r.Counters.Truncated, "there should be truncated messages")
+		suite.EqualValues(2, r.Counters.TCFallback, "truncated queries should be retried over TCP")
+		suite.EqualValues(0, r.Counters.TCFallbackError, "there should be no failed retries")
+		suite.EqualValues(2, r.Counters.Success, "retried queries should be answered over TCP")
+		suite.EqualValues(2, r.TCFallbackHist.TotalCount(), "latencies of retried queries should be recorded")
+		suite.EqualValues(2, r.Hist.TotalCount(), "latencies of all queries should be recorded")
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_retries() {
+	// the benchmarked server does not respond, the queries are retried using the fallback server
+	s := NewServer(dnsbench.UDPTransport, nil, func(_ dns.ResponseWriter, _ *dns.Msg) {})
+	defer s.Close()
+
+	fallback := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer fallback.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:         []string{"example.org"},
+		Types:           []string{"A", "AAAA"},
+		Server:          s.Addr,
+		FallbackServers: []string{fallback.Addr},
+		Retries:         2,
+		RetryTimeout:    100 * time.Millisecond,
+		RetryBackoff:    10 * time.Millisecond,
+		Concurrency:     2,
+		Count:           1,
+		Probability:     1,
+		WriteTimeout:    1 * time.Second,
+		ReadTimeout:     3 * time.Second,
+		ConnectTimeout:  1 * time.Second,
+		RequestTimeout:  5 * time.Second,
+		Rcodes:          true,
+		Recurse:         true,
+		Silent:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+
+	for _, r := range rs {
+		suite.EqualValues(2, r.Counters.Total, "there should be executions")
+		suite.EqualValues(2, r.Counters.Success, "queries should be answered by the fallback server")
+		suite.EqualValues(0, r.Counters.IOError, "failed attempts should not be counted as IO errors")
+		suite.EqualValues(2, r.Counters.Retried, "all queries should be retried")
+		suite.Equal(map[int]int64{2: 2}, r.Attempts, "all queries should be answered in the second attempt")
+		suite.EqualValues(0, r.FirstAttemptHist.TotalCount(), "first attempts were not answered")
+		suite.Require().Len(r.Timings, 2, "end-to-end latencies should be recorded")
+		for _, t := range r.Timings {
+			suite.GreaterOrEqual(t.Duration, 100*time.Millisecond, "end-to-end latency should include the timed out attempt")
+		}
+	}
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_retries_servfail() {
+	var mu sync.Mutex
+	var calls int
+	// the server answers SERVFAIL to every other query
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		calls++
+		servfail := calls%2 == 1
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if servfail {
+			ret.Rcode = dns.RcodeServerFailure
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		Retries:        3,
+		Concurrency:    1,
+		Count:          3,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.EqualValues(3, rs[0].Counters.Total, "there should be executions")
+	suite.EqualValues(3, rs[0].Counters.Success, "SERVFAIL responses should be retried")
+	suite.EqualValues(3, rs[0].Counters.Retried, "all queries should be retried")
+	suite.Equal(map[int]int64{2: 3}, rs[0].Attempts, "all queries should be answered in the second attempt")
+	suite.EqualValues(3, rs[0].FirstAttemptHist.TotalCount(), "first attempts were answered by SERVFAIL")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_sourceAddress() {
+	var mu sync.Mutex
+	sources := make(map[string]struct{})
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		remote := w.RemoteAddr().(*net.UDPAddr)
+		mu.Lock()
+		sources[remote.IP.String()] = struct{}{}
+		mu.Unlock()
+		suite.GreaterOrEqual(remote.Port, 40000, "source port should be from the configured range")
+		suite.LessOrEqual(remote.Port, 40100, "source port should be from the configured range")
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		SourceAddrs:    []string{"127.0.0.1", "127.0.0.2", "::1"},
+		SourcePorts:    "40000-40100",
+		QperConn:       1,
+		Concurrency:    2,
+		Count:          3,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+	for _, r := range rs {
+		suite.EqualValues(3, r.Counters.Success, "all queries should be answered")
+	}
+	suite.Equal(map[string]struct{}{"127.0.0.1": {}, "127.0.0.2": {}}, sources,
+		"workers should be spread over the IPv4 source addresses")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_malformed() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qclass != dns.ClassINET {
+			// drop queries with unknown class
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:            []string{"example.org"},
+		Types:              []string{"A"},
+		Server:             s.Addr,
+		Malformed:          true,
+		MalformedSeed:      42,
+		MalformedMutations: []string{dnsbench.MutationQDCount, dnsbench.MutationOpcode, dnsbench.MutationClass},
+		Concurrency:        2,
+		Count:              10,
+		Probability:        1,
+		WriteTimeout:       100 * time.Millisecond,
+		ReadTimeout:        100 * time.Millisecond,
+		ConnectTimeout:     1 * time.Second,
+		RequestTimeout:     1 * time.Second,
+		Rcodes:             true,
+		Recurse:            true,
+		Silent:             true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+
+	for _, r := range rs {
+		suite.EqualValues(10, r.Counters.Total, "there should be executions")
+		var total int64
+		for mutation, reactions := range r.MalformedReactions {
+			switch mutation {
+			case dnsbench.MutationQDCount:
+				suite.Equal([]string{"FORMERR"}, keys(reactions), "wrong QDCOUNT should be answered by FORMERR")
+			case dnsbench.MutationOpcode:
+				suite.Equal([]string{"NOTIMP"}, keys(reactions), "unknown opcode should be answered by NOTIMP")
+			case dnsbench.MutationClass:
+				suite.Equal([]string{dnsbench.ReactionDrop}, keys(reactions), "unknown class should be dropped")
+			default:
+				suite.Failf("unexpected mutation", "mutation %s was not configured", mutation)
+			}
+			for _, v := range reactions {
+				total += v
+			}
+		}
+		suite.EqualValues(10, total, "reactions to all queries should be recorded")
+	}
+}
+
+func keys(m map[string]int64) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_ecs() {
+	// the server answers based on the client subnet and returns the client subnet with scope equal to the source prefix length
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		for _, o := range r.IsEdns0().Option {
+			if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+				ret.Answer = append(ret.Answer, A("example.org. IN A "+subnet.Address.String()))
+				ret.SetEdns0(dns.DefaultMsgSize, false)
+				subnet.SourceScope = subnet.SourceNetmask
+				ret.IsEdns0().Option = append(ret.IsEdns0().Option, subnet)
+			}
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		ECS:            []string{"10.0.0.0/24", "192.168.0.0/16"},
+		ECSMode:        dnsbench.ECSModeRotate,
+		Concurrency:    1,
+		Count:          4,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.EqualValues(4, rs[0].Counters.Success, "all queries should be answered")
+	suite.Equal(map[string]*dnsbench.ECSStats{
+		"10.0.0.0/24":    {Queries: 2, ScopePrefixes: map[int]int64{24: 2}},
+		"192.168.0.0/16": {Queries: 2, ScopePrefixes: map[int]int64{16: 2}},
+	}, rs[0].ECS, "subnets should be rotated and scope prefixes recorded")
+	suite.Require().Len(rs[0].ECSAnswers["example.org. A"], 2, "answers should be recorded for both subnets")
+	suite.NotEqual(rs[0].ECSAnswers["example.org. A"]["10.0.0.0/24"], rs[0].ECSAnswers["example.org. A"]["192.168.0.0/16"],
+		"answers should differ per subnet")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_ednsOptions() {
+	const serverCookie = "0102030405060708"
+	var mu sync.Mutex
+	var cookies []string
+	s := NewServer(dnsbench.TCPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		opts := make(map[uint16]dns.EDNS0)
+		for _, o := range r.IsEdns0().Option {
+			opts[o.Option()] = o
+		}
+		suite.Contains(opts, uint16(dns.EDNS0NSID), "NSID should be requested")
+		suite.Contains(opts, uint16(dns.EDNS0TCPKEEPALIVE), "TCP keepalive should be sent")
+		suite.Contains(opts, uint16(65518), "first raw EDNS option should be sent")
+		suite.Contains(opts, uint16(65519), "second raw EDNS option should be sent")
+		suite.Require().Contains(opts, uint16(dns.EDNS0COOKIE), "cookie should be sent")
+		cookie := opts[dns.EDNS0COOKIE].(*dns.EDNS0_COOKIE).Cookie
+		mu.Lock()
+		cookies = append(cookies, cookie)
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		ret.SetEdns0(dns.DefaultMsgSize, false)
+		ret.IsEdns0().Option = append(ret.IsEdns0().Option,
+			&dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie[:16] + serverCookie},
+			&dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte("ns1"))},
+			&dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream timeout"},
+		)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		TCP:            true,
+		EdnsOpt:        "65518:74657374",
+		EdnsOpts:       []string{"65519:74657374"},
+		Cookies:        true,
+		NSID:           true,
+		EdnsKeepAlive:  true,
+		Concurrency:    1,
+		Count:          3,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.EqualValues(3, rs[0].Counters.Success, "all queries should be answered")
+	suite.Equal(map[string]int64{"ns1": 3}, rs[0].NSIDs, "NSIDs should be recorded")
+	suite.Equal(map[dnsbench.ExtendedError]int64{{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream timeout"}: 3}, rs[0].ExtendedErrors, "extended DNS errors should be recorded")
+
+	suite.Require().Len(cookies, 3)
+	suite.Len(cookies[0], 16, "first query should contain only client cookie")
+	suite.Equal(cookies[0]+serverCookie, cookies[1], "server cookie should be reused")
+	suite.Equal(cookies[0]+serverCookie, cookies[2], "server cookie should be reused")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_instanceNSID() {
+	var mu sync.Mutex
+	var queries int
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		suite.NotNil(r.IsEdns0(), "NSID should be requested")
+
+		mu.Lock()
+		// anycast instances flapping for each query
+		nsid := fmt.Sprintf("pop%d", queries%2+1)
+		queries++
+		mu.Unlock()
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		ret.SetEdns0(dns.DefaultMsgSize, false)
+		ret.IsEdns0().Option = append(ret.IsEdns0().Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: hex.EncodeToString([]byte(nsid))})
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		InstanceID:     dnsbench.InstanceIDNSID,
+		Concurrency:    1,
+		Count:          4,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.Require().Len(rs[0].Instances, 2, "both instances should be recorded")
+	suite.EqualValues(2, rs[0].Instances["pop1"].Responses)
+	suite.EqualValues(2, rs[0].Instances["pop1"].Hist.TotalCount())
+	suite.EqualValues(2, rs[0].Instances["pop2"].Responses)
+	suite.EqualValues(2, rs[0].Instances["pop2"].Hist.TotalCount())
+	suite.EqualValues(3, rs[0].Counters.InstanceChanges, "each response should change the instance")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_instanceChaos() {
+	var mu sync.Mutex
+	var chaosQueries int
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qclass == dns.ClassCHAOS {
+			mu.Lock()
+			chaosQueries++
+			mu.Unlock()
+			ret.Answer = append(ret.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+				Txt: []string{"pop1"},
+			})
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		InstanceID:     dnsbench.InstanceIDServer,
+		Concurrency:    1,
+		Count:          3,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.EqualValues(3, rs[0].Counters.Total, "CHAOS queries should not be counted")
+	mu.Lock()
+	suite.Equal(3, chaosQueries, "CHAOS query should be sent after each response")
+	mu.Unlock()
+	suite.Require().Len(rs[0].Instances, 1)
+	suite.EqualValues(3, rs[0].Instances["pop1"].Responses)
+	suite.EqualValues(0, rs[0].Counters.InstanceChanges)
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_instanceChaos_notRetried() {
+	// the benchmarked server does not answer CHAOS queries, they must not be answered by the fallback server
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qclass == dns.ClassCHAOS {
+			return
+		}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	var mu sync.Mutex
+	var fallbackQueries int
+	fallback := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		fallbackQueries++
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{"fallback"},
+		})
+		w.WriteMsg(ret)
+	})
+	defer fallback.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:         []string{"example.org"},
+		Types:           []string{"A"},
+		Server:          s.Addr,
+		FallbackServers: []string{fallback.Addr},
+		Retries:         2,
+		RetryTimeout:    100 * time.Millisecond,
+		InstanceID:      dnsbench.InstanceIDServer,
+		Concurrency:     1,
+		Count:           2,
+		Probability:     1,
+		WriteTimeout:    1 * time.Second,
+		ReadTimeout:     200 * time.Millisecond,
+		ConnectTimeout:  1 * time.Second,
+		RequestTimeout:  300 * time.Millisecond,
+		Rcodes:          true,
+		Recurse:         true,
+		Silent:          true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	suite.EqualValues(2, rs[0].Counters.Success)
+	mu.Lock()
+	suite.Zero(fallbackQueries, "CHAOS queries should not be sent to the fallback server")
+	mu.Unlock()
+	suite.Require().Len(rs[0].Instances, 1)
+	suite.EqualValues(2, rs[0].Instances[""].Responses)
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_instanceChaos_rateLimited() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Qclass == dns.ClassCHAOS {
+			ret.Answer = append(ret.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+				Txt: []string{"pop1"},
+			})
+		} else {
+			ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		InstanceID:     dnsbench.InstanceIDServer,
+		Rate:           20,
+		Concurrency:    1,
+		Count:          5,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(5, rs[0].Counters.Total)
+	// 10 queries (5 benchmark and 5 CHAOS queries) at 20 QPS take at least 450ms
+	suite.GreaterOrEqual(time.Since(start), 400*time.Millisecond, "CHAOS queries should be rate limited")
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_dnssecValidation() {
+	rrsig := func(name string, alg uint8) *dns.RRSIG {
+		return &dns.RRSIG{
+			Hdr:         dns.RR_Header{Name: name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 60},
+			TypeCovered: dns.TypeA,
+			Algorithm:   alg,
+			SignerName:  name,
+			Signature:   "AAAA",
+		}
+	}
+	// the server emulates validating resolver, which does not support ED25519 algorithm
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		suite.True(r.IsEdns0().Do(), "DO bit should be set")
+
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		name := r.Question[0].Name
+		switch name {
+		case "secure.test.":
+			ret.Answer = append(ret.Answer, A(name+" IN A 127.0.0.1"), rrsig(name, dns.ECDSAP256SHA256))
+			ret.AuthenticatedData = !r.CheckingDisabled
+		case "ed25519.test.":
+			ret.Answer = append(ret.Answer, A(name+" IN A 127.0.0.1"), rrsig(name, dns.ED25519))
+		case "bogus.test.":
+			if r.CheckingDisabled {
+				ret.Answer = append(ret.Answer, A(name+" IN A 127.0.0.1"), rrsig(name, dns.ECDSAP256SHA256))
+			} else {
+				ret.Rcode = dns.RcodeServerFailure
+			}
+		default:
+			ret.Answer = append(ret.Answer, A(name+" IN A 127.0.0.1"))
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Types:            []string{"A"},
+		Server:           s.Addr,
+		DNSSECValidation: true,
+		DNSSECCases: []string{
+			"secure:secure.test", "secure:ed25519.test", "insecure:insecure.test", "bogus:bogus.test",
+		},
+		Concurrency:    1,
+		Count:          20,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+
+	st := rs[0].DNSSECValidation
+	suite.Require().NotNil(st)
+	suite.EqualValues(80, st.ValidatedHist.TotalCount()+st.UncheckedHist.TotalCount(), "all queries should be recorded")
+	suite.NotZero(st.ValidatedHist.TotalCount(), "some queries should be validated")
+	suite.NotZero(st.UncheckedHist.TotalCount(), "some queries should be sent with checking disabled")
+
+	for name, want := range map[string]string{
+		"secure.test.":   dnsbench.DNSSECSecure,
+		"ed25519.test.":  dnsbench.DNSSECInsecure,
+		"insecure.test.": dnsbench.DNSSECInsecure,
+		"bogus.test.":    dnsbench.DNSSECBogus,
+	} {
+		suite.Require().Contains(st.Cases, name)
+		suite.Equal([]string{want}, keys(st.Cases[name].Results), "unexpected result of %s", name)
+	}
+	suite.Equal(dnsbench.DNSSECSecure, st.Cases["ed25519.test."].Expected)
+
+	suite.Require().Contains(st.Algorithms, dns.ECDSAP256SHA256)
+	suite.Zero(st.Algorithms[dns.ECDSAP256SHA256].NotValidated)
+	suite.NotZero(st.Algorithms[dns.ECDSAP256SHA256].Validated)
+	suite.Require().Contains(st.Algorithms, dns.ED25519)
+	suite.Zero(st.Algorithms[dns.ED25519].Validated)
+	suite.NotZero(st.Algorithms[dns.ED25519].NotValidated)
+}
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_faults() {
+	s := NewServer(dnsbench.UDPTransport, nil, func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+		suite.Require().NoError(w.WriteMsg(ret))
+	})
+	defer s.Close()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         s.Addr,
+		Concurrency:    2,
+		Count:          2,
+		Probability:    1,
+		WriteTimeout:   100 * time.Millisecond,
+		ReadTimeout:    300 * time.Millisecond,
+		ConnectTimeout: 100 * time.Millisecond,
+		RequestTimeout: 500 * time.Millisecond,
+		Rcodes:         true,
+		Recurse:        true,
+		Faults:         faultproxy.Config{Loss: 1},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 2, "expected results from two workers")
+
+	suite.EqualValues(2, rs[0].Counters.IOError, "all the queries should be lost")
+	suite.EqualValues(2, rs[1].Counters.IOError, "all the queries should be lost")
+	suite.Require().NotNil(rs[0].InjectedFaults, "injected faults should be recorded by the first worker")
+	suite.Nil(rs[1].InjectedFaults)
+	suite.EqualValues(4, rs[0].InjectedFaults.QueriesDropped)
+	suite.Zero(rs[0].InjectedFaults.ResponsesDropped)
+}
+
 func (suite *PlainDNSTestSuite) TestBenchmark_Requestlog() {
 	requestLogPath := suite.T().TempDir() + "/requests.log"
 
@@ -824,3 +1576,66 @@ func (suite *PlainDNSTestSuite) TestBenchmark_RandomRequestDelay() {
 	assertResult(suite.T(), rs)
 	suite.InDelta(4*time.Second, benchDuration, float64(2*time.Second))
 }
+
+func (suite *PlainDNSTestSuite) TestBenchmark_Run_pipelining() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	suite.Require().NoError(err)
+	defer listener.Close()
+
+	// the server reads queries in pairs and answers each pair in reverse order
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		co := dns.Conn{Conn: c}
+		defer co.Close()
+		for {
+			first, err := co.ReadMsg()
+			if err != nil {
+				return
+			}
+			second, err := co.ReadMsg()
+			if err != nil {
+				return
+			}
+			for _, q := range []*dns.Msg{second, first} {
+				ret := new(dns.Msg)
+				ret.SetReply(q)
+				ret.Answer = append(ret.Answer, A("example.org. IN A 127.0.0.1"))
+				if err := co.WriteMsg(ret); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	bench := dnsbench.Benchmark{
+		Queries:        []string{"example.org"},
+		Types:          []string{"A"},
+		Server:         listener.Addr().String(),
+		TCP:            true,
+		Pipeline:       2,
+		Concurrency:    1,
+		Count:          4,
+		Probability:    1,
+		WriteTimeout:   1 * time.Second,
+		ReadTimeout:    3 * time.Second,
+		ConnectTimeout: 1 * time.Second,
+		RequestTimeout: 5 * time.Second,
+		Rcodes:         true,
+		Recurse:        true,
+		Silent:         true,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	rs, err := bench.Run(ctx)
+
+	suite.Require().NoError(err, "expected no error from benchmark run")
+	suite.Require().Len(rs, 1, "expected results from one worker")
+	suite.EqualValues(4, rs[0].Counters.Total, "there should be executions")
+	suite.EqualValues(4, rs[0].Counters.Success, "all responses should be matched to the queries")
+	suite.EqualValues(0, rs[0].Counters.IDmismatch, "there should be no ID mismatches")
+	suite.EqualValues(2, rs[0].Counters.OutOfOrder, "second query of each pair should be answered out of order")
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 )
 
 func TestBenchmark_init(t *testing.T) {
@@ -122,11 +123,211 @@ func TestBenchmark_init(t *testing.T) {
 			wantRequestDelayStart: 2 * time.Second,
 			wantRequestDelayEnd:   3 * time.Second,
 		},
+		{
+			name:       "resolve override and bootstrap",
+			benchmark:  Benchmark{Server: "dns.example", Resolve: []string{"dns.example:192.0.2.1", "dns.example:2001:db8::1"}, Bootstrap: "1.1.1.1"},
+			wantServer: "dns.example:53",
+		},
+		{
+			name:      "invalid resolve override",
+			benchmark: Benchmark{Server: "8.8.8.8", Resolve: []string{"dns.example"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid resolve override IP",
+			benchmark: Benchmark{Server: "8.8.8.8", Resolve: []string{"dns.example:invalid"}},
+			wantErr:   true,
+		},
+		{
+			name:      "bootstrap is not an IP address",
+			benchmark: Benchmark{Server: "8.8.8.8", Bootstrap: "dns.example"},
+			wantErr:   true,
+		},
+		{
+			name:      "client certificate without key",
+			benchmark: Benchmark{Server: "8.8.8.8", ClientCertFile: "testdata/test.crt"},
+			wantErr:   true,
+		},
+		{
+			name:      "missing CA bundle",
+			benchmark: Benchmark{Server: "8.8.8.8", CACertFile: "testdata/missing.crt"},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported TLS version",
+			benchmark: Benchmark{Server: "8.8.8.8", TLSMinVersion: "2.0"},
+			wantErr:   true,
+		},
+		{
+			name:      "TLS min version higher than max version",
+			benchmark: Benchmark{Server: "8.8.8.8", TLSMinVersion: TLSVersion13, TLSMaxVersion: TLSVersion12},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported cipher suite",
+			benchmark: Benchmark{Server: "8.8.8.8", TLSCipherSuites: []string{"TLS_UNKNOWN"}},
+			wantErr:   true,
+		},
+		{
+			name:      "pipelining over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", Pipeline: 2},
+			wantErr:   true,
+		},
+		{
+			name:       "pipelining over TCP",
+			benchmark:  Benchmark{Server: "8.8.8.8", Pipeline: 2, TCP: true},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "tc fallback over TCP",
+			benchmark: Benchmark{Server: "8.8.8.8", TCFallback: true, TCP: true},
+			wantErr:   true,
+		},
+		{
+			name:      "negative retries",
+			benchmark: Benchmark{Server: "8.8.8.8", Retries: -1},
+			wantErr:   true,
+		},
+		{
+			name:      "fallback server with different protocol",
+			benchmark: Benchmark{Server: "8.8.8.8", Retries: 1, FallbackServers: []string{"https://1.1.1.1"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid source address",
+			benchmark: Benchmark{Server: "8.8.8.8", SourceAddrs: []string{"localhost"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid source port range",
+			benchmark: Benchmark{Server: "8.8.8.8", SourcePorts: "2000-1000"},
+			wantErr:   true,
+		},
+		{
+			name:      "source address with DoH",
+			benchmark: Benchmark{Server: "https://1.1.1.1", SourceAddrs: []string{"127.0.0.1"}},
+			wantErr:   true,
+		},
+		{
+			name:       "source port",
+			benchmark:  Benchmark{Server: "8.8.8.8", SourcePorts: "5353"},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "fixed source port with concurrency",
+			benchmark: Benchmark{Server: "8.8.8.8", SourcePorts: "5353", Concurrency: 2},
+			wantErr:   true,
+		},
+		{
+			name:      "source port range smaller than concurrency",
+			benchmark: Benchmark{Server: "8.8.8.8", SourcePorts: "5353-5355", Concurrency: 4},
+			wantErr:   true,
+		},
+		{
+			name:       "source port range with concurrency",
+			benchmark:  Benchmark{Server: "8.8.8.8", SourcePorts: "5353-5356", Concurrency: 4},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:       "fixed source port with source address for each worker",
+			benchmark:  Benchmark{Server: "8.8.8.8", SourceAddrs: []string{"127.0.0.1", "127.0.0.2"}, SourcePorts: "5353", Concurrency: 2},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "unknown malformed mutation",
+			benchmark: Benchmark{Server: "8.8.8.8", Malformed: true, MalformedMutations: []string{"unknown"}},
+			wantErr:   true,
+		},
+		{
+			name:      "malformed queries with DoH",
+			benchmark: Benchmark{Server: "https://1.1.1.1", Malformed: true},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid ECS subnet",
+			benchmark: Benchmark{Server: "8.8.8.8", ECS: []string{"10.0.0.1"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid ECS mode",
+			benchmark: Benchmark{Server: "8.8.8.8", ECS: []string{"10.0.0.0/24"}, ECSMode: "unknown"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid DNSSEC validation case",
+			benchmark: Benchmark{Server: "8.8.8.8", DNSSECValidation: true, DNSSECCases: []string{"example.org"}},
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported DNSSEC validation result",
+			benchmark: Benchmark{Server: "8.8.8.8", DNSSECValidation: true, DNSSECCases: []string{"valid:example.org"}},
+			wantErr:   true,
+		},
+		{
+			name:      "DNSSEC validation with queries",
+			benchmark: Benchmark{Server: "8.8.8.8", DNSSECValidation: true, Queries: []string{"example.org"}},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid instance ID",
+			benchmark: Benchmark{Server: "8.8.8.8", InstanceID: "unknown"},
+			wantErr:   true,
+		},
+		{
+			name:      "instance ID with malformed queries",
+			benchmark: Benchmark{Server: "8.8.8.8", InstanceID: InstanceIDNSID, Malformed: true},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid format of additional ednsopt",
+			benchmark: Benchmark{Server: "8.8.8.8", EdnsOpts: []string{"65518:74657374", "test"}},
+			wantErr:   true,
+		},
+		{
+			name:      "padding over plain DNS",
+			benchmark: Benchmark{Server: "8.8.8.8", Padding: true},
+			wantErr:   true,
+		},
+		{
+			name:      "TCP keepalive over UDP",
+			benchmark: Benchmark{Server: "8.8.8.8", EdnsKeepAlive: true},
+			wantErr:   true,
+		},
 		{
 			name:      "invalid delay",
 			benchmark: Benchmark{Server: "8.8.8.8", RequestDelay: "invalid"},
 			wantErr:   true,
 		},
+		{
+			name:       "fault injection",
+			benchmark:  Benchmark{Server: "8.8.8.8", Faults: faultproxy.Config{Loss: 0.1, Delay: time.Millisecond}},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "fault injection with invalid rate",
+			benchmark: Benchmark{Server: "8.8.8.8", Faults: faultproxy.Config{Duplicate: 2}},
+			wantErr:   true,
+		},
+		{
+			name:      "fault injection over DoH",
+			benchmark: Benchmark{Server: "https://1.1.1.1", Faults: faultproxy.Config{Loss: 0.1}},
+			wantErr:   true,
+		},
+		{
+			name:      "fault injection with source address",
+			benchmark: Benchmark{Server: "8.8.8.8", SourceAddrs: []string{"127.0.0.1"}, Faults: faultproxy.Config{Loss: 0.1}},
+			wantErr:   true,
+		},
+		{
+			name:       "junit format",
+			benchmark:  Benchmark{Server: "8.8.8.8", Format: FormatJUnit},
+			wantServer: "8.8.8.8:53",
+		},
+		{
+			name:      "unsupported format",
+			benchmark: Benchmark{Server: "8.8.8.8", Format: "xml"},
+			wantErr:   true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
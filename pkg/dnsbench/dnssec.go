@@ -0,0 +1,135 @@
+package dnsbench
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+const (
+	// DNSSECSecure is result of the validation, when the response is authenticated (AD flag set).
+	DNSSECSecure = "secure"
+	// DNSSECInsecure is result of the validation, when the response is not authenticated (AD flag unset).
+	DNSSECInsecure = "insecure"
+	// DNSSECBogus is result of the validation, when the validation failed (SERVFAIL response).
+	DNSSECBogus = "bogus"
+)
+
+// DefaultDNSSECCases is the built-in suite of the DNSSEC validation benchmark in format <expected result>:<domain>. The domains
+// are public internet domains, so the benchmarked resolver has to be able to resolve them.
+var DefaultDNSSECCases = []string{
+	DNSSECSecure + ":cloudflare.com",
+	DNSSECSecure + ":isc.org",
+	DNSSECSecure + ":ietf.org",
+	DNSSECInsecure + ":google.com",
+	DNSSECInsecure + ":amazon.com",
+	DNSSECBogus + ":dnssec-failed.org",
+}
+
+// DNSSECValidationStats represents results of the DNSSEC validation benchmark (see Benchmark.DNSSECValidation).
+type DNSSECValidationStats struct {
+	// Cases contains results of the validating queries by the domain of the test case.
+	Cases map[string]*DNSSECCaseStats
+	// Algorithms contains results of the validating queries by the algorithm of the RRSIG records in the responses.
+	Algorithms map[uint8]*DNSSECAlgorithmStats
+	// ValidatedHist contains latencies of the validating queries, i.e. queries with checking disabled (CD) flag unset.
+	ValidatedHist *hdrhistogram.Histogram
+	// UncheckedHist contains latencies of the queries with checking disabled (CD) flag set.
+	UncheckedHist *hdrhistogram.Histogram
+}
+
+// DNSSECCaseStats represents results of the validating queries of a single test case.
+type DNSSECCaseStats struct {
+	// Expected is the expected result of the validation, one of DNSSECSecure, DNSSECInsecure and DNSSECBogus.
+	Expected string
+	// Results contains number of responses by the result of the validation.
+	Results map[string]int64
+}
+
+// DNSSECAlgorithmStats represents results of the validating queries answered with RRSIG records of a single algorithm.
+type DNSSECAlgorithmStats struct {
+	// Validated is counter of all signed responses authenticated by the server.
+	Validated int64
+	// NotValidated is counter of all signed responses not authenticated by the server, which means the algorithm is likely
+	// not supported by the server.
+	NotValidated int64
+}
+
+// parseDNSSECCases parses test cases of the DNSSEC validation benchmark in format <expected result>:<domain> to map of
+// the expected results by the domain.
+func parseDNSSECCases(cases []string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, c := range cases {
+		expected, domain, ok := strings.Cut(c, ":")
+		if !ok || len(domain) == 0 {
+			return nil, fmt.Errorf("--dnssec-case '%s' is not in format <expected result>:<domain>", c)
+		}
+		switch expected {
+		case DNSSECSecure, DNSSECInsecure, DNSSECBogus:
+		default:
+			return nil, fmt.Errorf("--dnssec-case '%s' has unsupported expected result, supported results are %s, %s and %s",
+				c, DNSSECSecure, DNSSECInsecure, DNSSECBogus)
+		}
+		parsed[dns.Fqdn(domain)] = expected
+	}
+	return parsed, nil
+}
+
+// dnssecResult returns result of the validation done by the server.
+func dnssecResult(resp *dns.Msg) string {
+	switch {
+	case resp.Rcode == dns.RcodeServerFailure:
+		return DNSSECBogus
+	case resp.AuthenticatedData:
+		return DNSSECSecure
+	default:
+		return DNSSECInsecure
+	}
+}
+
+// recordDNSSEC records response of the DNSSEC validation benchmark, expected is the expected result of the test case.
+func (rs *ResultStats) recordDNSSEC(expected string, req, resp *dns.Msg, duration time.Duration) {
+	st := rs.DNSSECValidation
+	if st == nil {
+		return
+	}
+	if req.CheckingDisabled {
+		st.UncheckedHist.RecordValue(duration.Nanoseconds())
+		return
+	}
+	st.ValidatedHist.RecordValue(duration.Nanoseconds())
+
+	result := dnssecResult(resp)
+	name := req.Question[0].Name
+	c, ok := st.Cases[name]
+	if !ok {
+		c = &DNSSECCaseStats{Expected: expected, Results: make(map[string]int64)}
+		st.Cases[name] = c
+	}
+	c.Results[result]++
+
+	if result == DNSSECBogus {
+		return
+	}
+	for _, rr := range resp.Answer {
+		sig, ok := rr.(*dns.RRSIG)
+		if !ok {
+			continue
+		}
+		a, ok := st.Algorithms[sig.Algorithm]
+		if !ok {
+			a = &DNSSECAlgorithmStats{}
+			st.Algorithms[sig.Algorithm] = a
+		}
+		if result == DNSSECSecure {
+			a.Validated++
+		} else {
+			a.NotValidated++
+		}
+		// single record per response is enough, all signatures of the RRset usually use the same algorithm
+		break
+	}
+}
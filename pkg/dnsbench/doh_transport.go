@@ -0,0 +1,257 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+// dohConnTracker records DoH connections opened by the transports and attributes each DoH request (stream) to the connection,
+// which carried it.
+type dohConnTracker struct {
+	rec *connRecorder
+	// conns contains only the open connections, so the closed connections can be garbage collected
+	conns sync.Map // *trackedConn -> connection identifier
+}
+
+func newDoHConnTracker(rec *connRecorder) *dohConnTracker {
+	if rec == nil {
+		rec = &connRecorder{}
+	}
+	return &dohConnTracker{rec: rec}
+}
+
+func (t *dohConnTracker) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.track(conn), nil
+	}
+}
+
+func (t *dohConnTracker) dialTLSContext(dial func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error)) func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr, cfg)
+		if err != nil {
+			return nil, err
+		}
+		tracked := t.track(conn)
+		if cs, ok := conn.(connectionStater); ok {
+			// HTTP/2 transport reads the TLS state of the dialed connection
+			return &trackedTLSConn{trackedConn: tracked, cs: cs}, nil
+		}
+		return tracked, nil
+	}
+}
+
+// track registers new connection, the connection is recorded as closed and forgotten, when it is closed.
+func (t *dohConnTracker) track(conn net.Conn) *trackedConn {
+	tracked := &trackedConn{Conn: conn}
+	id := t.rec.newDoHConn()
+	tracked.untrack = func() {
+		t.conns.Delete(tracked)
+		t.rec.closeDoHConn(id)
+	}
+	t.conns.Store(tracked, id)
+	return tracked
+}
+
+func (t *dohConnTracker) dialQUIC(dial func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error)) func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	return func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+		conn, err := dial(ctx, addr, tlsCfg, cfg)
+		if err != nil {
+			return nil, err
+		}
+		id := t.rec.newDoHConn()
+		go func() {
+			// the context is cancelled, when the connection is closed by either side or times out
+			<-conn.Context().Done()
+			t.rec.closeDoHConn(id)
+		}()
+		return &countingQUICConn{EarlyConnection: conn, rec: t.rec, id: id}, nil
+	}
+}
+
+// withTrace returns context attributing HTTP/1.1 and HTTP/2 requests to the connections. HTTP/3 requests are attributed
+// directly by countingQUICConn, because HTTP/3 transport does not support tracing.
+func (t *dohConnTracker) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn := info.Conn
+			if tc, isTLS := conn.(*trackedTLSConn); isTLS {
+				conn = tc.trackedConn
+			}
+			id, ok := t.conns.Load(conn)
+			if nc, isTLS := conn.(interface{ NetConn() net.Conn }); !ok && isTLS {
+				// HTTP/1.1 transport does the TLS handshake on top of the dialed connection
+				id, ok = t.conns.Load(nc.NetConn())
+			}
+			if ok {
+				t.rec.addDoHStream(id.(int))
+			}
+		},
+	})
+}
+
+type connectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// trackedConn is connection tracked by dohConnTracker.
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	untrack func()
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.untrack)
+	return c.Conn.Close()
+}
+
+// trackedTLSConn is TLS connection tracked by dohConnTracker, which exposes the TLS state of the connection.
+type trackedTLSConn struct {
+	*trackedConn
+	cs connectionStater
+}
+
+func (c *trackedTLSConn) ConnectionState() tls.ConnectionState {
+	return c.cs.ConnectionState()
+}
+
+// countingQUICConn counts bidirectional streams opened on the QUIC connection, HTTP/3 uses one bidirectional stream per request.
+type countingQUICConn struct {
+	quic.EarlyConnection
+	rec *connRecorder
+	id  int
+}
+
+func (c *countingQUICConn) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	str, err := c.EarlyConnection.OpenStreamSync(ctx)
+	if err == nil {
+		c.rec.addDoHStream(c.id)
+	}
+	return str, err
+}
+
+func (c *countingQUICConn) OpenStream() (quic.Stream, error) {
+	str, err := c.EarlyConnection.OpenStream()
+	if err == nil {
+		c.rec.addDoHStream(c.id)
+	}
+	return str, err
+}
+
+// newDoHTransport creates HTTP round tripper used for sending DoH requests based on Benchmark.DohProtocol and DoH connection
+// pooling settings.
+func newDoHTransport(b *Benchmark, rec *connRecorder, tracker *dohConnTracker) http.RoundTripper {
+	switch b.DohProtocol {
+	case HTTP3Proto, HTTP2Proto:
+		if b.DohMaxConns == 0 && b.DohMaxStreams == 0 {
+			return newMultiplexedDoHTransport(b, rec, tracker)
+		}
+		conns := b.DohMaxConns
+		if conns == 0 {
+			conns = 1
+		}
+		pool := dohPool{}
+		for i := 0; i < conns; i++ {
+			pool.transports = append(pool.transports, newMultiplexedDoHTransport(b, rec, tracker))
+		}
+		if b.DohMaxStreams > 0 {
+			pool.tokens = make(chan int, conns*b.DohMaxStreams)
+			// interleave the tokens, so the requests are spread over all connections
+			for s := 0; s < b.DohMaxStreams; s++ {
+				for i := 0; i < conns; i++ {
+					pool.tokens <- i
+				}
+			}
+		}
+		return &pool
+	case HTTP1Proto:
+		fallthrough
+	default:
+		dialer := &net.Dialer{Timeout: b.ConnectTimeout, KeepAlive: b.DohKeepAlive}
+		// server name is derived by the HTTP transport from the URL, when not overridden by Benchmark.ServerName
+		return &http.Transport{
+			TLSClientConfig:   b.newTLSConfig("", rec),
+			DialContext:       tracker.dialContext(b.resolver.dialContext(dialer)),
+			MaxConnsPerHost:   b.DohMaxConns,
+			IdleConnTimeout:   b.DohIdleTimeout,
+			DisableKeepAlives: b.DohDisableKeepAlive,
+		}
+	}
+}
+
+// newMultiplexedDoHTransport creates HTTP/2 or HTTP/3 transport. When DoH pooling is configured, the transport is limited
+// to a single connection.
+func newMultiplexedDoHTransport(b *Benchmark, rec *connRecorder, tracker *dohConnTracker) http.RoundTripper {
+	if b.DohProtocol == HTTP3Proto {
+		return &http3.RoundTripper{
+			TLSClientConfig: b.newTLSConfig("", rec),
+			QUICConfig:      &quic.Config{MaxIdleTimeout: b.DohIdleTimeout, KeepAlivePeriod: b.DohKeepAlive},
+			Dial:            tracker.dialQUIC(b.resolver.dialQUIC),
+		}
+	}
+	dialer := &net.Dialer{Timeout: b.ConnectTimeout}
+	return &http2.Transport{
+		TLSClientConfig:            b.newTLSConfig("", rec),
+		DialTLSContext:             tracker.dialTLSContext(b.resolver.dialTLSContext(dialer)),
+		IdleConnTimeout:            b.DohIdleTimeout,
+		ReadIdleTimeout:            b.DohKeepAlive,
+		StrictMaxConcurrentStreams: b.DohMaxConns > 0 || b.DohMaxStreams > 0,
+	}
+}
+
+// dohPool distributes DoH requests over multiple transports, where each transport uses a single connection. When tokens
+// are set, the number of concurrent requests (streams) per transport is limited by the number of tokens of the transport.
+type dohPool struct {
+	transports []http.RoundTripper
+	tokens     chan int
+	next       atomic.Uint64
+}
+
+func (p *dohPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	if p.tokens == nil {
+		i := p.next.Add(1) % uint64(len(p.transports))
+		return p.transports[i].RoundTrip(req)
+	}
+
+	var i int
+	select {
+	case i = <-p.tokens:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	resp, err := p.transports[i].RoundTrip(req)
+	if err != nil {
+		p.tokens <- i
+		return nil, err
+	}
+	// the stream is active until the response body is closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { p.tokens <- i }}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releasingBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,40 @@
+package dnsbench
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_dohConnTracker_closedConnsAreForgotten(t *testing.T) {
+	rec := &connRecorder{}
+	tracker := newDoHConnTracker(rec)
+	dial := tracker.dialContext(func(context.Context, string, string) (net.Conn, error) {
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		conn, err := dial(context.Background(), "tcp", "127.0.0.1:443")
+		require.NoError(t, err)
+		trace := httptrace.ContextClientTrace(tracker.withTrace(context.Background()))
+		trace.GotConn(httptrace.GotConnInfo{Conn: conn})
+		require.NoError(t, conn.Close())
+		// closing the connection again does not fail on untracking
+		conn.Close()
+	}
+
+	tracked := 0
+	tracker.conns.Range(func(_, _ interface{}) bool {
+		tracked++
+		return true
+	})
+	assert.Zero(t, tracked)
+	assert.Empty(t, rec.dohOpen, "closed connections should not be kept open")
+	assert.Equal(t, &DoHConnectionStats{Opened: 3, Streams: 3, MinStreams: 1, MaxStreams: 1}, rec.doh)
+}
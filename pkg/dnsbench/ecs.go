@@ -0,0 +1,150 @@
+package dnsbench
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ECSModeRotate rotates the client subnets for each query of the worker.
+	ECSModeRotate = "rotate"
+	// ECSModeRandom uses randomly chosen client subnet for each query.
+	ECSModeRandom = "random"
+	// ECSModeWorker uses single client subnet for all queries of the worker, the workers are spread over the client subnets.
+	ECSModeWorker = "worker"
+)
+
+// ECSStats represents results of queries sent with a single EDNS Client Subnet.
+type ECSStats struct {
+	// Queries is counter of all responses to the queries sent with the client subnet.
+	Queries int64
+	// NoECS is counter of all responses without EDNS Client Subnet option.
+	NoECS int64
+	// ScopePrefixes contains number of responses by the scope prefix length returned by the server.
+	ScopePrefixes map[int]int64
+}
+
+// parseECS parses client subnet in CIDR notation to EDNS Client Subnet option.
+func parseECS(subnet string) (*dns.EDNS0_SUBNET, error) {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("--ecs '%s' is not a subnet in CIDR notation: %w", subnet, err)
+	}
+	ones, _ := ipNet.Mask.Size()
+	opt := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, SourceNetmask: uint8(ones), Address: ipNet.IP}
+	if ip4 := ipNet.IP.To4(); ip4 != nil {
+		opt.Family = 1
+		opt.Address = ip4
+	} else {
+		opt.Family = 2
+	}
+	return opt, nil
+}
+
+// ecsSelector chooses EDNS Client Subnet option for the queries of a single worker based on Benchmark.ECSMode.
+type ecsSelector struct {
+	subnets []*dns.EDNS0_SUBNET
+	mode    string
+	next    int
+}
+
+func newECSSelector(b *Benchmark, workerID uint32) *ecsSelector {
+	if len(b.ecsSubnets) == 0 {
+		return nil
+	}
+	return &ecsSelector{subnets: b.ecsSubnets, mode: b.ECSMode, next: int(workerID) % len(b.ecsSubnets)}
+}
+
+// apply adds EDNS Client Subnet option to the query.
+func (s *ecsSelector) apply(rnd *rand.Rand, m *dns.Msg) {
+	var subnet *dns.EDNS0_SUBNET
+	switch s.mode {
+	case ECSModeWorker:
+		subnet = s.subnets[s.next]
+	case ECSModeRandom:
+		subnet = s.subnets[rnd.Intn(len(s.subnets))]
+	default:
+		subnet = s.subnets[s.next]
+		s.next = (s.next + 1) % len(s.subnets)
+	}
+
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(DefaultEdns0BufferSize, false)
+		o = m.IsEdns0()
+	}
+	o.Option = append(o.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        subnet.Family,
+		SourceNetmask: subnet.SourceNetmask,
+		Address:       subnet.Address,
+	})
+}
+
+// findECS returns EDNS Client Subnet option of the message, nil is returned if the message does not contain it.
+func findECS(m *dns.Msg) *dns.EDNS0_SUBNET {
+	if m == nil {
+		return nil
+	}
+	o := m.IsEdns0()
+	if o == nil {
+		return nil
+	}
+	for _, opt := range o.Option {
+		if subnet, ok := opt.(*dns.EDNS0_SUBNET); ok {
+			return subnet
+		}
+	}
+	return nil
+}
+
+func ecsString(subnet *dns.EDNS0_SUBNET) string {
+	return fmt.Sprintf("%s/%d", subnet.Address, subnet.SourceNetmask)
+}
+
+// answerFingerprint returns representation of the answer section independent of the order of the records and their TTLs.
+func answerFingerprint(m *dns.Msg) string {
+	rrs := make([]string, 0, len(m.Answer))
+	for _, rr := range m.Answer {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		rrs = append(rrs, rr.String())
+	}
+	sort.Strings(rrs)
+	return strings.Join(rrs, "\n")
+}
+
+// recordECS records the response to the query sent with EDNS Client Subnet option.
+func (rs *ResultStats) recordECS(req *dns.Msg, resp *dns.Msg) {
+	subnet := findECS(req)
+	if rs.ECS == nil || subnet == nil || resp == nil {
+		return
+	}
+	key := ecsString(subnet)
+	st, ok := rs.ECS[key]
+	if !ok {
+		st = &ECSStats{ScopePrefixes: make(map[int]int64)}
+		rs.ECS[key] = st
+	}
+	st.Queries++
+	if respSubnet := findECS(resp); respSubnet != nil {
+		st.ScopePrefixes[int(respSubnet.SourceScope)]++
+	} else {
+		st.NoECS++
+	}
+
+	if resp.Rcode != dns.RcodeSuccess {
+		return
+	}
+	q := req.Question[0]
+	question := q.Name + " " + dns.TypeToString[q.Qtype]
+	if rs.ECSAnswers[question] == nil {
+		rs.ECSAnswers[question] = make(map[string]string)
+	}
+	rs.ECSAnswers[question][key] = answerFingerprint(resp)
+}
@@ -0,0 +1,154 @@
+package dnsbench
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/miekg/dns"
+)
+
+// queryPaddingBlockSize is the block size used for padding of the queries recommended by RFC 8467.
+const queryPaddingBlockSize = 128
+
+// parseEdnsOpt parses EDNS option in format code:value, where value is a hexadecimal string.
+func parseEdnsOpt(ednsOpt string) (*dns.EDNS0_LOCAL, error) {
+	split := strings.Split(ednsOpt, ":")
+	if len(split) != 2 {
+		return nil, errors.New("--ednsopt is not in correct format")
+	}
+	data, err := hex.DecodeString(split[1])
+	if err != nil {
+		return nil, errors.New("--ednsopt is not in correct format, data is not hexadecimal string")
+	}
+	code, err := strconv.ParseUint(split[0], 10, 16)
+	if err != nil {
+		return nil, errors.New("--ednsopt is not in correct format, code is not a decimal number")
+	}
+	return &dns.EDNS0_LOCAL{Code: uint16(code), Data: data}, nil
+}
+
+// ednsOpts returns all raw EDNS options configured using Benchmark.EdnsOpt and Benchmark.EdnsOpts.
+func (b *Benchmark) ednsOpts() []string {
+	if len(b.EdnsOpt) == 0 {
+		return b.EdnsOpts
+	}
+	return append([]string{b.EdnsOpt}, b.EdnsOpts...)
+}
+
+func edns0(m *dns.Msg) *dns.OPT {
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(DefaultEdns0BufferSize, false)
+		o = m.IsEdns0()
+	}
+	return o
+}
+
+// addEdnsOptions adds EDNS options configured by the Benchmark to the query, except for the cookies (see cookieJar)
+// and padding (see addPadding).
+func (b *Benchmark) addEdnsOptions(m *dns.Msg) {
+	for _, opt := range b.ednsOpts() {
+		// validated by Benchmark.init
+		parsed, _ := parseEdnsOpt(opt)
+		o := edns0(m)
+		o.Option = append(o.Option, parsed)
+	}
+	if b.NSID || b.InstanceID == InstanceIDNSID {
+		o := edns0(m)
+		o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
+	if b.EdnsKeepAlive {
+		// clients send the option without timeout, https://datatracker.ietf.org/doc/html/rfc7828#section-3.2.1
+		o := edns0(m)
+		o.Option = append(o.Option, &dns.EDNS0_TCP_KEEPALIVE{Code: dns.EDNS0TCPKEEPALIVE})
+	}
+}
+
+// addPadding pads the query to the multiple of the block size recommended by RFC 8467, it has to be called after
+// all the other options are added to the query.
+func addPadding(m *dns.Msg) {
+	o := edns0(m)
+	// the padding option itself takes 4 octets (option code and length)
+	l := m.Len() + 4
+	o.Option = append(o.Option, &dns.EDNS0_PADDING{Padding: make([]byte, (queryPaddingBlockSize-l%queryPaddingBlockSize)%queryPaddingBlockSize)})
+}
+
+// cookieJar holds DNS Cookies (RFC 7873) of a single worker, the client cookie is generated once per worker
+// and the server cookie returned by the server is reused in the following queries.
+type cookieJar struct {
+	mu     sync.Mutex
+	client string
+	server string
+}
+
+func newCookieJar() *cookieJar {
+	client := make([]byte, 8)
+	_, _ = rand.Read(client)
+	return &cookieJar{client: hex.EncodeToString(client)}
+}
+
+func (c *cookieJar) apply(m *dns.Msg) {
+	c.mu.Lock()
+	cookie := c.client + c.server
+	c.mu.Unlock()
+	o := edns0(m)
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+}
+
+// update stores the server cookie from the response, if the response contains the client cookie of the jar.
+func (c *cookieJar) update(resp *dns.Msg) {
+	o := resp.IsEdns0()
+	if o == nil {
+		return
+	}
+	for _, opt := range o.Option {
+		cookie, ok := opt.(*dns.EDNS0_COOKIE)
+		if !ok || len(cookie.Cookie) <= len(c.client) || !strings.EqualFold(cookie.Cookie[:len(c.client)], c.client) {
+			continue
+		}
+		c.mu.Lock()
+		c.server = cookie.Cookie[len(c.client):]
+		c.mu.Unlock()
+	}
+}
+
+// nsidString returns NSID as a string, if it is printable, otherwise hexadecimal representation is returned.
+func nsidString(nsid *dns.EDNS0_NSID) string {
+	data, err := hex.DecodeString(nsid.Nsid)
+	if err != nil {
+		return nsid.Nsid
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) {
+			return nsid.Nsid
+		}
+	}
+	return string(data)
+}
+
+// recordEDNS records NSID and Extended DNS Errors returned in the response.
+func (rs *ResultStats) recordEDNS(resp *dns.Msg) {
+	o := resp.IsEdns0()
+	if o == nil {
+		return
+	}
+	for _, opt := range o.Option {
+		switch v := opt.(type) {
+		case *dns.EDNS0_NSID:
+			if rs.NSIDs == nil {
+				rs.NSIDs = make(map[string]int64)
+			}
+			rs.NSIDs[nsidString(v)]++
+		case *dns.EDNS0_EDE:
+			if rs.ExtendedErrors == nil {
+				rs.ExtendedErrors = make(map[ExtendedError]int64)
+			}
+			rs.ExtendedErrors[ExtendedError{InfoCode: v.InfoCode, ExtraText: v.ExtraText}]++
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package dnsbench
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_addPadding(t *testing.T) {
+	for _, name := range []string{"a.", "example.org.", "very-long-subdomain-of-some-domain.example.org."} {
+		t.Run(name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion(name, dns.TypeA)
+			addPadding(msg)
+
+			packed, err := msg.Pack()
+
+			require.NoError(t, err)
+			assert.Zero(t, len(packed)%queryPaddingBlockSize, "query should be padded to the multiple of block size")
+		})
+	}
+}
+
+func Test_nsidString(t *testing.T) {
+	assert.Equal(t, "ns1.example", nsidString(&dns.EDNS0_NSID{Nsid: hex.EncodeToString([]byte("ns1.example"))}))
+	assert.Equal(t, "00ff", nsidString(&dns.EDNS0_NSID{Nsid: "00ff"}))
+}
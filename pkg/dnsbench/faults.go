@@ -0,0 +1,34 @@
+package dnsbench
+
+import (
+	"context"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
+)
+
+// startFaultProxy starts proxy injecting Benchmark.Faults between the workers and the benchmarked server, the workers
+// dial the proxy instead of the server (see dialDNS).
+func (b *Benchmark) startFaultProxy(ctx context.Context) (*faultproxy.Proxy, error) {
+	upstream, err := b.resolver.resolveAddr(ctx, b.Server)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := faultproxy.Start("127.0.0.1:0", upstream, b.Faults)
+	if err != nil {
+		return nil, err
+	}
+	b.faultProxy = proxy
+	return proxy, nil
+}
+
+// dialAddr returns address dialed by the workers for the network, which is either the address of the fault injecting
+// proxy or the resolved address of the server.
+func (b *Benchmark) dialAddr(ctx context.Context, network string) (string, error) {
+	if b.faultProxy != nil {
+		if network == UDPTransport {
+			return b.faultProxy.UDPAddr, nil
+		}
+		return b.faultProxy.TCPAddr, nil
+	}
+	return b.resolver.resolveAddr(ctx, b.Server)
+}
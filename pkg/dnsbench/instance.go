@@ -0,0 +1,93 @@
+package dnsbench
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+)
+
+const (
+	// InstanceIDNSID identifies the answering server instance by the NSID (RFC 5001) returned in the response.
+	InstanceIDNSID = "nsid"
+	// InstanceIDServer identifies the answering server instance by the CHAOS TXT record id.server (RFC 4892).
+	InstanceIDServer = "id.server"
+	// InstanceIDHostnameBind identifies the answering server instance by the CHAOS TXT record hostname.bind.
+	InstanceIDHostnameBind = "hostname.bind"
+)
+
+// InstanceStats represents results of queries answered by a single instance of the server.
+type InstanceStats struct {
+	// Responses is counter of all responses returned by the instance.
+	Responses int64
+	// Hist contains latencies of the responses returned by the instance.
+	Hist *hdrhistogram.Histogram
+}
+
+// identifyInstance returns identifier of the server instance, which returned the response, based on Benchmark.InstanceID.
+// When CHAOS TXT record is used, the record is queried using the query function of the benchmarked server right after the response
+// is received, so it is likely answered by the same instance. The query is not retried nor sent to the fallback servers, since
+// it would identify different instance. Empty string is returned if the instance could not be identified.
+func (b *Benchmark) identifyInstance(ctx context.Context, query queryFunc, resp *dns.Msg) string {
+	if b.InstanceID == InstanceIDNSID {
+		return findNSID(resp)
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion(b.InstanceID+".", dns.TypeTXT)
+	req.Question[0].Qclass = dns.ClassCHAOS
+
+	reqCtx, cancel := context.WithTimeout(ctx, b.RequestTimeout)
+	defer cancel()
+	out, err := query(reqCtx, req)
+	if err != nil || out.Rcode != dns.RcodeSuccess {
+		return ""
+	}
+	for _, rr := range out.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			return strings.Join(txt.Txt, "")
+		}
+	}
+	return ""
+}
+
+// findNSID returns NSID of the response, empty string is returned if the response does not contain NSID.
+func findNSID(resp *dns.Msg) string {
+	o := resp.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, opt := range o.Option {
+		if nsid, ok := opt.(*dns.EDNS0_NSID); ok {
+			return nsidString(nsid)
+		}
+	}
+	return ""
+}
+
+// recordInstance records response returned by the server instance, responses of unidentified instances are recorded under
+// empty string. The change of the instance is counted, when the instance differs from the instance which answered the previous query of the worker.
+func (rs *ResultStats) recordInstance(instance string, duration time.Duration) {
+	if rs.Instances == nil {
+		return
+	}
+	st, ok := rs.Instances[instance]
+	if !ok {
+		st = &InstanceStats{
+			Hist: hdrhistogram.New(rs.Hist.LowestTrackableValue(), rs.Hist.HighestTrackableValue(), int(rs.Hist.SignificantFigures())),
+		}
+		rs.Instances[instance] = st
+	}
+	st.Responses++
+	st.Hist.RecordValue(duration.Nanoseconds())
+
+	if len(instance) == 0 {
+		return
+	}
+	if len(rs.lastInstance) != 0 && rs.lastInstance != instance {
+		rs.Counters.InstanceChanges++
+	}
+	rs.lastInstance = instance
+}
@@ -0,0 +1,277 @@
+package dnsbench
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// MutationQDCount sets wrong number of questions in the header.
+	MutationQDCount = "qdcount"
+	// MutationCompressionLoop replaces the question name by compression pointer pointing to itself.
+	MutationCompressionLoop = "compression-loop"
+	// MutationOversizeLabel uses question name with label longer than 63 octets or name longer than 255 octets.
+	MutationOversizeLabel = "oversize-label"
+	// MutationOpcode uses unassigned opcode.
+	MutationOpcode = "opcode"
+	// MutationEdnsVersion uses unsupported EDNS version in OPT record.
+	MutationEdnsVersion = "edns-version"
+	// MutationClass uses unknown question class.
+	MutationClass = "class"
+)
+
+const (
+	// ReactionDrop means that the server did not respond to the malformed query, but responded to the following well-formed query.
+	ReactionDrop = "drop"
+	// ReactionUnresponsive means that the server responded neither to the malformed query nor to the following well-formed query,
+	// which might indicate that the server crashed.
+	ReactionUnresponsive = "unresponsive"
+	// ReactionConnectionClosed means that the server closed the connection without response.
+	ReactionConnectionClosed = "connection closed"
+	// ReactionConnectionError means that the connection to the server failed (e.g. connection reset).
+	ReactionConnectionError = "connection error"
+	// ReactionMalformedResponse means that the server response could not be parsed.
+	ReactionMalformedResponse = "malformed response"
+)
+
+// Mutations lists all the supported mutations of malformed queries.
+var Mutations = []string{
+	MutationQDCount, MutationCompressionLoop, MutationOversizeLabel, MutationOpcode, MutationEdnsVersion, MutationClass,
+}
+
+// unassignedOpcodes are opcodes not assigned by IANA.
+var unassignedOpcodes = []int{3, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+type mutation func(rnd *rand.Rand, msg *dns.Msg) ([]byte, error)
+
+var mutations = map[string]mutation{
+	MutationQDCount:         mutateQDCount,
+	MutationCompressionLoop: mutateCompressionLoop,
+	MutationOversizeLabel:   mutateOversizeLabel,
+	MutationOpcode:          mutateOpcode,
+	MutationEdnsVersion:     mutateEdnsVersion,
+	MutationClass:           mutateClass,
+}
+
+func mutateQDCount(rnd *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	// either no question or more questions than present in the packet
+	counts := []uint16{0, 2, 0xffff}
+	binary.BigEndian.PutUint16(packed[4:], counts[rnd.Intn(len(counts))])
+	return packed, nil
+}
+
+func mutateCompressionLoop(_ *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	// the question name starts right after the header, the pointer points to the start of the question name, i.e. to itself
+	nameEnd := questionNameEnd(packed)
+	if nameEnd < 0 {
+		return nil, errors.New("failed to find question name in the packed query")
+	}
+	mutated := append([]byte{}, packed[:dnsHeaderSize]...)
+	mutated = append(mutated, 0xc0, dnsHeaderSize)
+	return append(mutated, packed[nameEnd:]...), nil
+}
+
+func mutateOversizeLabel(rnd *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	nameEnd := questionNameEnd(packed)
+	if nameEnd < 0 {
+		return nil, errors.New("failed to find question name in the packed query")
+	}
+	var name []byte
+	if rnd.Intn(2) == 0 {
+		// label of 64 octets, the length octet has reserved extended label type bits set
+		name = append([]byte{64}, randomLabel(rnd, 64)...)
+	} else {
+		// 5 labels of 63 octets exceed the maximum name length of 255 octets
+		for i := 0; i < 5; i++ {
+			name = append(name, 63)
+			name = append(name, randomLabel(rnd, 63)...)
+		}
+	}
+	name = append(name, 0)
+
+	mutated := append([]byte{}, packed[:dnsHeaderSize]...)
+	mutated = append(mutated, name...)
+	return append(mutated, packed[nameEnd:]...), nil
+}
+
+func mutateOpcode(rnd *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	msg.Opcode = unassignedOpcodes[rnd.Intn(len(unassignedOpcodes))]
+	return msg.Pack()
+}
+
+func mutateEdnsVersion(rnd *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(DefaultEdns0BufferSize, false)
+		opt = msg.IsEdns0()
+	}
+	// only EDNS version 0 is defined
+	opt.SetVersion(uint8(1 + rnd.Intn(255)))
+	return msg.Pack()
+}
+
+func mutateClass(rnd *rand.Rand, msg *dns.Msg) ([]byte, error) {
+	// classes 0x0100-0xfeff are unassigned
+	msg.Question[0].Qclass = uint16(0x0100 + rnd.Intn(0xfe00))
+	return msg.Pack()
+}
+
+const dnsHeaderSize = 12
+
+// questionNameEnd returns offset right after the uncompressed question name of the packed query, -1 is returned if the name is not valid.
+func questionNameEnd(packed []byte) int {
+	off := dnsHeaderSize
+	for off < len(packed) {
+		l := int(packed[off])
+		if l == 0 {
+			return off + 1
+		}
+		if l > 63 {
+			return -1
+		}
+		off += l + 1
+	}
+	return -1
+}
+
+func randomLabel(rnd *rand.Rand, length int) []byte {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	label := make([]byte, length)
+	for i := range label {
+		label[i] = letters[rnd.Intn(len(letters))]
+	}
+	return label
+}
+
+// malformedQuery sends mutated queries to the benchmarked server and classifies reactions of the server. Each query uses
+// a new connection, since the server is expected to close the connection on malformed input.
+type malformedQuery struct {
+	b         *Benchmark
+	workerID  uint32
+	dnsClient *dns.Client
+	rnd       *rand.Rand
+	mutations []string
+}
+
+func newMalformedQuery(b *Benchmark, workerID uint32) *malformedQuery {
+	names := b.MalformedMutations
+	if len(names) == 0 {
+		names = Mutations
+	}
+	return &malformedQuery{
+		b:         b,
+		workerID:  workerID,
+		dnsClient: getDNSClient(b, nil),
+		// each worker uses its own deterministic sequence of mutations derived from the seed
+		// nolint:gosec
+		rnd:       rand.New(rand.NewSource(b.MalformedSeed + int64(workerID))),
+		mutations: names,
+	}
+}
+
+func (m *malformedQuery) exchange(ctx context.Context, req *dns.Msg) queryOutcome {
+	name := m.mutations[m.rnd.Intn(len(m.mutations))]
+	out := queryOutcome{attempts: 1, mutation: name}
+
+	raw, err := mutations[name](m.rnd, req.Copy())
+	if err != nil {
+		out.err = fmt.Errorf("failed to create malformed query: %w", err)
+		out.reaction = ReactionConnectionError
+		return out
+	}
+
+	out.resp, out.err = m.send(ctx, raw)
+	var netErr net.Error
+	switch {
+	case out.err == nil:
+		out.reaction = dns.RcodeToString[out.resp.Rcode]
+		if len(out.reaction) == 0 {
+			out.reaction = fmt.Sprintf("RCODE%d", out.resp.Rcode)
+		}
+	case errors.Is(out.err, errConnectionClosed):
+		out.reaction = ReactionConnectionClosed
+	case errors.Is(out.err, errMalformedResponse):
+		out.reaction = ReactionMalformedResponse
+	case errors.Is(out.err, context.DeadlineExceeded) || (errors.As(out.err, &netErr) && netErr.Timeout()):
+		out.reaction = ReactionDrop
+		if !m.alive(ctx, req) {
+			out.reaction = ReactionUnresponsive
+		}
+	default:
+		out.reaction = ReactionConnectionError
+	}
+	return out
+}
+
+var (
+	errMalformedResponse = errors.New("malformed response")
+	errConnectionClosed  = errors.New("connection closed by server")
+)
+
+func (m *malformedQuery) send(ctx context.Context, raw []byte) (*dns.Msg, error) {
+	co, err := dialDNS(ctx, m.b, m.dnsClient, m.workerID)
+	if err != nil {
+		return nil, err
+	}
+	defer co.Close()
+	co.UDPSize = dns.MaxMsgSize
+
+	deadline := time.Now().Add(m.b.WriteTimeout + m.b.ReadTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	co.SetDeadline(deadline)
+
+	if _, err := co.Write(raw); err != nil {
+		return nil, err
+	}
+	p, err := co.ReadMsgHeader(nil)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// the server closed the connection without response
+			return nil, errConnectionClosed
+		}
+		if errors.Is(err, dns.ErrShortRead) {
+			return nil, fmt.Errorf("%w: %v", errMalformedResponse, err)
+		}
+		return nil, err
+	}
+	resp := new(dns.Msg)
+	if err := resp.Unpack(p); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedResponse, err)
+	}
+	return resp, nil
+}
+
+// alive checks whether the server still responds to the well-formed query.
+func (m *malformedQuery) alive(ctx context.Context, req *dns.Msg) bool {
+	// the request timeout might be already exceeded by the malformed query, the probe gets its own timeout
+	probeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.b.RequestTimeout)
+	defer cancel()
+	raw, err := req.Pack()
+	if err != nil {
+		return false
+	}
+	_, err = m.send(probeCtx, raw)
+	return err == nil
+}
@@ -0,0 +1,41 @@
+package dnsbench
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_mutations(t *testing.T) {
+	for _, name := range Mutations {
+		t.Run(name, func(t *testing.T) {
+			msg := new(dns.Msg)
+			msg.SetQuestion("example.org.", dns.TypeA)
+
+			// nolint:gosec
+			packed, err := mutations[name](rand.New(rand.NewSource(1)), msg)
+
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, len(packed), dnsHeaderSize)
+			valid := new(dns.Msg)
+			valid.SetQuestion("example.org.", dns.TypeA)
+			validPacked, err := valid.Pack()
+			require.NoError(t, err)
+			assert.NotEqual(t, validPacked, packed, "the query should be mutated")
+		})
+	}
+}
+
+func Test_mutateCompressionLoop(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("example.org.", dns.TypeA)
+
+	packed, err := mutateCompressionLoop(nil, msg)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xc0, dnsHeaderSize}, packed[dnsHeaderSize:dnsHeaderSize+2], "question name should point to itself")
+	assert.Error(t, new(dns.Msg).Unpack(packed), "compression loop should not be unpackable")
+}
@@ -0,0 +1,223 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var errPipelineClosed = errors.New("pipelined connection closed")
+
+// pipelinedConn sends multiple queries over a single TCP or DoT connection without waiting for the responses
+// (RFC 7766 section 6.2.1.1), responses are matched to the queries using their IDs.
+type pipelinedConn struct {
+	co           *dns.Conn
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	rec          *connRecorder
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint16]*pipelinedQuery
+	// expired are IDs of the queries, which timed out before their responses arrived, late responses to these queries
+	// are dropped, any other unexpected response is counted as ID mismatch
+	expired  map[uint16]struct{}
+	sent     []*pipelinedQuery // queries in the order they were sent, used for detecting out-of-order responses
+	sequence uint64
+	err      error
+	draining bool
+}
+
+type pipelinedQuery struct {
+	seq  uint64
+	done bool
+	resp chan *dns.Msg
+}
+
+func newPipelinedConn(co *dns.Conn, writeTimeout, idleTimeout time.Duration, rec *connRecorder) *pipelinedConn {
+	p := &pipelinedConn{
+		co:           co,
+		writeTimeout: writeTimeout,
+		idleTimeout:  idleTimeout,
+		rec:          rec,
+		pending:      make(map[uint16]*pipelinedQuery),
+		expired:      make(map[uint16]struct{}),
+	}
+	go p.readLoop()
+	return p
+}
+
+// exchange sends the query and waits for the matching response. If there is already pending query with the same ID,
+// the ID of the msg is changed, so the responses can be matched unambiguously.
+func (p *pipelinedConn) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return nil, p.err
+	}
+	for {
+		if _, ok := p.pending[msg.Id]; !ok {
+			break
+		}
+		// nolint:gosec
+		msg.Id = uint16(rand.Intn(1 << 16))
+	}
+	id := msg.Id
+	// the new query takes over the ID, so the late response to the expired query cannot be distinguished anymore
+	delete(p.expired, id)
+	p.sequence++
+	q := &pipelinedQuery{seq: p.sequence, resp: make(chan *dns.Msg, 1)}
+	p.pending[id] = q
+	p.sent = append(p.sent, q)
+	p.mu.Unlock()
+
+	p.writeMu.Lock()
+	if p.writeTimeout > 0 {
+		p.co.SetWriteDeadline(time.Now().Add(p.writeTimeout))
+	}
+	err := p.co.WriteMsg(msg)
+	if err == nil && p.idleTimeout > 0 {
+		// the response to this query is awaited at least for the idle timeout, the deadline applies also to the read in progress
+		p.co.SetReadDeadline(time.Now().Add(p.idleTimeout))
+	}
+	p.writeMu.Unlock()
+	if err != nil {
+		p.fail(err)
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-q.resp:
+		if !ok {
+			return nil, p.closeErr()
+		}
+		return resp, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		if p.pending[id] == q {
+			delete(p.pending, id)
+			p.expired[id] = struct{}{}
+			q.done = true
+		}
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (p *pipelinedConn) readLoop() {
+	for {
+		if p.idleTimeout > 0 {
+			p.co.SetReadDeadline(time.Now().Add(p.idleTimeout))
+		}
+		resp, err := p.co.ReadMsg()
+		if err != nil {
+			// the connection cannot be read anymore after timeout, because the response might have been read only partially,
+			// the deadline is extended by each sent query, so all pending queries waited at least for the idle timeout
+			p.fail(err)
+			return
+		}
+
+		p.mu.Lock()
+		q, ok := p.pending[resp.Id]
+		if !ok {
+			if _, expired := p.expired[resp.Id]; expired {
+				// late response to the query, which already timed out
+				delete(p.expired, resp.Id)
+			} else {
+				p.rec.addIDMismatch()
+			}
+			p.mu.Unlock()
+			continue
+		}
+		delete(p.pending, resp.Id)
+		for len(p.sent) > 0 && p.sent[0].done {
+			p.sent = p.sent[1:]
+		}
+		if len(p.sent) > 0 && p.sent[0] != q {
+			// there is a query sent earlier, which was not answered yet
+			p.rec.addOutOfOrder()
+		}
+		q.done = true
+		closeConn := p.draining && len(p.pending) == 0
+		p.mu.Unlock()
+
+		q.resp <- resp
+		if closeConn {
+			p.fail(errPipelineClosed)
+			return
+		}
+	}
+}
+
+// drain stops the connection from accepting new queries, the connection is closed as soon as all pending queries are answered.
+func (p *pipelinedConn) drain() {
+	p.mu.Lock()
+	p.draining = true
+	idle := len(p.pending) == 0
+	p.mu.Unlock()
+	if idle {
+		p.fail(errPipelineClosed)
+	}
+}
+
+func (p *pipelinedConn) usable() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err == nil && !p.draining
+}
+
+func (p *pipelinedConn) closeErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// fail closes the connection and fails all pending queries.
+func (p *pipelinedConn) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	p.err = err
+	p.co.Close()
+	for id, q := range p.pending {
+		close(q.resp)
+		delete(p.pending, id)
+	}
+	p.sent = nil
+}
+
+// pipelinedQueryFactory creates query functions for workers, which keep multiple queries in flight on a single connection.
+func pipelinedQueryFactory(b *Benchmark) queryFuncFactory {
+	return func(workerID uint32, worker *connRecorder) queryFunc {
+		dnsClient := getDNSClient(b, worker)
+		var mu sync.Mutex
+		var p *pipelinedConn
+		var i int64
+		return func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+			mu.Lock()
+			if p != nil && (!p.usable() || (b.QperConn > 0 && i%b.QperConn == 0)) {
+				p.drain()
+				p = nil
+			}
+			i++
+			if p == nil {
+				co, err := dialDNS(ctx, b, dnsClient, workerID)
+				if err != nil {
+					mu.Unlock()
+					return nil, err
+				}
+				p = newPipelinedConn(co, b.WriteTimeout, b.ReadTimeout, worker)
+			}
+			conn := p
+			mu.Unlock()
+			return conn.exchange(ctx, msg)
+		}
+	}
+}
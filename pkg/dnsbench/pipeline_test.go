@@ -0,0 +1,75 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pipelinedConn_unexpectedResponses(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	rec := &connRecorder{}
+	p := newPipelinedConn(&dns.Conn{Conn: client}, time.Second, 5*time.Second, rec)
+	defer p.fail(errPipelineClosed)
+	srv := &dns.Conn{Conn: server}
+
+	first := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	first.Id = 100
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	expired := make(chan error, 1)
+	go func() {
+		_, err := p.exchange(ctx, first)
+		expired <- err
+	}()
+	q, err := srv.ReadMsg()
+	require.NoError(t, err)
+	require.ErrorIs(t, <-expired, context.DeadlineExceeded)
+
+	// late response to the expired query is dropped, response with unknown ID is counted as ID mismatch
+	require.NoError(t, srv.WriteMsg(new(dns.Msg).SetReply(q)))
+	unknown := new(dns.Msg).SetReply(q)
+	unknown.Id = 101
+	require.NoError(t, srv.WriteMsg(unknown))
+
+	second := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	second.Id = 102
+	go func() {
+		q, err := srv.ReadMsg()
+		if err == nil {
+			_ = srv.WriteMsg(new(dns.Msg).SetReply(q))
+		}
+	}()
+	resp, err := p.exchange(context.Background(), second)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(102), resp.Id)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.Equal(t, int64(1), rec.idMismatch)
+}
+
+func Test_pipelinedConn_readTimeoutClosesConnection(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	p := newPipelinedConn(&dns.Conn{Conn: client}, time.Second, 50*time.Millisecond, &connRecorder{})
+	srv := &dns.Conn{Conn: server}
+	go func() {
+		// the query is never answered
+		_, _ = srv.ReadMsg()
+	}()
+
+	_, err := p.exchange(context.Background(), new(dns.Msg).SetQuestion("example.org.", dns.TypeA))
+
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "unexpected error %v", err)
+	assert.True(t, netErr.Timeout())
+	assert.False(t, p.usable())
+}
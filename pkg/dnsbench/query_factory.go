@@ -2,31 +2,33 @@ package dnsbench
 
 import (
 	"context"
-	"crypto/tls"
 	"net"
 	"net/http"
 
 	"github.com/miekg/dns"
-	"github.com/quic-go/quic-go/http3"
 	"github.com/tantalor93/doh-go/doh"
 	"github.com/tantalor93/doq-go/doq"
-	"golang.org/x/net/http2"
 )
 
-func workerQueryFactory(b *Benchmark) func() queryFunc {
+// workerQueryFactory creates factory of query functions for workers. Details of connections owned by a single worker
+// are recorded using the worker recorder passed to the factory, details of connections shared between workers are recorded
+// using the shared recorder.
+func workerQueryFactory(b *Benchmark, shared *connRecorder) queryFuncFactory {
 	switch {
 	case b.useDoH:
-		return dohQueryFactory(b)
+		return dohQueryFactory(b, shared)
 	case b.useQuic:
-		return doqQueryFactory(b)
+		return doqQueryFactory(b, shared)
+	case b.Pipeline > 1:
+		return pipelinedQueryFactory(b)
 	default:
 		return dnsQueryFactory(b)
 	}
 }
 
-func dnsQueryFactory(b *Benchmark) func() queryFunc {
-	return func() queryFunc {
-		dnsClient := getDNSClient(b)
+func dnsQueryFactory(b *Benchmark) queryFuncFactory {
+	return func(workerID uint32, worker *connRecorder) queryFunc {
+		dnsClient := getDNSClient(b, worker)
 		var co *dns.Conn
 		var i int64
 		// this allows DoT and plain DNS protocols to support counting queries per connection
@@ -39,7 +41,7 @@ func dnsQueryFactory(b *Benchmark) func() queryFunc {
 			i++
 			if co == nil {
 				var err error
-				co, err = dnsClient.DialContext(ctx, b.Server)
+				co, err = dialDNS(ctx, b, dnsClient, workerID)
 				if err != nil {
 					return nil, err
 				}
@@ -55,71 +57,96 @@ func dnsQueryFactory(b *Benchmark) func() queryFunc {
 	}
 }
 
-func doqQueryFactory(b *Benchmark) func() queryFunc {
+// tcpFallbackQuery creates query function used for retrying queries over TCP, when the UDP response was truncated.
+// Each retry uses a new TCP connection, as stub resolvers usually do.
+func tcpFallbackQuery(b *Benchmark, workerID uint32) queryFunc {
+	dnsClient := getDNSClient(b, nil)
+	dnsClient.Net = TCPTransport
+	return func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		co, err := dialDNS(ctx, b, dnsClient, workerID)
+		if err != nil {
+			return nil, err
+		}
+		defer co.Close()
+		r, _, err := dnsClient.ExchangeWithConnContext(ctx, msg, co)
+		return r, err
+	}
+}
+
+func doqQueryFactory(b *Benchmark, shared *connRecorder) queryFuncFactory {
 	if b.SeparateWorkerConnections {
-		return func() queryFunc {
-			quicClient := getDoQClient(b)
-			return quicClient.Send
+		return func(_ uint32, worker *connRecorder) queryFunc {
+			return doqQuery(b, worker)
 		}
 	}
-	quicClient := getDoQClient(b)
-	return func() queryFunc {
-		return quicClient.Send
+	doqQuery := doqQuery(b, shared)
+	return func(uint32, *connRecorder) queryFunc {
+		return doqQuery
+	}
+}
+
+func doqQuery(b *Benchmark, rec *connRecorder) queryFunc {
+	quicClient, err := getDoQClient(b, rec)
+	if err != nil {
+		return func(context.Context, *dns.Msg) (*dns.Msg, error) {
+			return nil, err
+		}
 	}
+	return quicClient.Send
 }
 
-func dohQueryFactory(b *Benchmark) func() queryFunc {
+func dohQueryFactory(b *Benchmark, shared *connRecorder) queryFuncFactory {
 	if b.SeparateWorkerConnections {
-		return func() queryFunc {
-			return dohQuery(b)
+		return func(_ uint32, worker *connRecorder) queryFunc {
+			return dohQuery(b, worker)
 		}
 	}
-	dohQuery := dohQuery(b)
-	return func() queryFunc {
+	dohQuery := dohQuery(b, shared)
+	return func(uint32, *connRecorder) queryFunc {
 		return dohQuery
 	}
 }
 
-func dohQuery(b *Benchmark) queryFunc {
-	var tr http.RoundTripper
-	switch b.DohProtocol {
-	case HTTP3Proto:
-		// nolint:gosec
-		tr = &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: b.Insecure}}
-	case HTTP2Proto:
-		// nolint:gosec
-		tr = &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: b.Insecure}}
-	case HTTP1Proto:
-		fallthrough
-	default:
-		// nolint:gosec
-		tr = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: b.Insecure}}
-	}
-	c := http.Client{Transport: tr, Timeout: b.ReadTimeout}
+func dohQuery(b *Benchmark, rec *connRecorder) queryFunc {
+	tracker := newDoHConnTracker(rec)
+	c := http.Client{Transport: newDoHTransport(b, rec, tracker), Timeout: b.ReadTimeout}
 	dohClient := doh.NewClient(b.Server, doh.WithHTTPClient(&c))
 
+	var send queryFunc
 	switch b.DohMethod {
 	case PostHTTPMethod:
-		return dohClient.SendViaPost
+		send = dohClient.SendViaPost
 	case GetHTTPMethod:
-		return dohClient.SendViaGet
+		send = dohClient.SendViaGet
 	default:
-		return dohClient.SendViaPost
+		send = dohClient.SendViaPost
+	}
+	return func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		return send(tracker.withTrace(ctx), msg)
 	}
 }
 
-func getDoQClient(b *Benchmark) *doq.Client {
+func getDoQClient(b *Benchmark, rec *connRecorder) (*doq.Client, error) {
 	h, _, _ := net.SplitHostPort(b.Server)
-	return doq.NewClient(b.Server,
-		// nolint:gosec
-		doq.WithTLSConfig(&tls.Config{ServerName: h, InsecureSkipVerify: b.Insecure}),
+	timeout := b.ConnectTimeout
+	if timeout == 0 {
+		timeout = DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addr, err := b.resolver.resolveAddr(ctx, b.Server)
+	if err != nil {
+		return nil, err
+	}
+	return doq.NewClient(addr,
+		doq.WithTLSConfig(b.newTLSConfig(h, rec)),
 		doq.WithReadTimeout(b.ReadTimeout),
 		doq.WithWriteTimeout(b.WriteTimeout),
 		doq.WithConnectTimeout(b.ConnectTimeout),
-	)
+	), nil
 }
 
-func getDNSClient(b *Benchmark) *dns.Client {
+func getDNSClient(b *Benchmark, rec *connRecorder) *dns.Client {
 	network := UDPTransport
 	if b.TCP {
 		network = TCPTransport
@@ -128,13 +155,19 @@ func getDNSClient(b *Benchmark) *dns.Client {
 		network = TLSTransport
 	}
 
+	// the server might be dialed using resolved IP address or through the fault injecting proxy, so the server name
+	// needs to be set explicitly to keep the SNI and verify the server certificate
+	var serverName string
+	if h, _, err := net.SplitHostPort(b.Server); err == nil && (net.ParseIP(h) == nil || b.Faults.Enabled()) {
+		serverName = h
+	}
+
 	return &dns.Client{
 		Net:          network,
 		DialTimeout:  b.ConnectTimeout,
 		WriteTimeout: b.WriteTimeout,
 		ReadTimeout:  b.ReadTimeout,
 		Timeout:      b.RequestTimeout,
-		// nolint:gosec
-		TLSConfig: &tls.Config{InsecureSkipVerify: b.Insecure},
+		TLSConfig:    b.newTLSConfig(serverName, rec),
 	}
 }
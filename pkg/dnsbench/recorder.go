@@ -0,0 +1,235 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"math"
+	"sync"
+	"time"
+)
+
+// TLSStats aggregates details negotiated during TLS handshakes (i.e. connections) with the benchmarked server.
+type TLSStats struct {
+	// Handshakes is number of TLS handshakes.
+	Handshakes int64
+	// Versions contains number of handshakes by the negotiated TLS version, e.g. TLS 1.3.
+	Versions map[string]int64
+	// CipherSuites contains number of handshakes by the negotiated cipher suite.
+	CipherSuites map[string]int64
+	// ALPN contains number of handshakes by the negotiated application protocol, handshakes without negotiated protocol are not counted.
+	ALPN map[string]int64
+	// OCSPStapled is number of handshakes, where the server stapled OCSP response.
+	OCSPStapled int64
+	// Resumed is number of handshakes resuming previous TLS session.
+	Resumed int64
+	// Certificates contains distinct certificates presented by the server in the order they were first seen, so the leaf
+	// certificate precedes the certificates of its chain.
+	Certificates []CertificateInfo
+}
+
+func newTLSStats() *TLSStats {
+	return &TLSStats{
+		Versions:     make(map[string]int64),
+		CipherSuites: make(map[string]int64),
+		ALPN:         make(map[string]int64),
+	}
+}
+
+// Merge adds the handshakes aggregated by other TLSStats.
+func (s *TLSStats) Merge(other *TLSStats) {
+	s.Handshakes += other.Handshakes
+	for k, v := range other.Versions {
+		s.Versions[k] += v
+	}
+	for k, v := range other.CipherSuites {
+		s.CipherSuites[k] += v
+	}
+	for k, v := range other.ALPN {
+		s.ALPN[k] += v
+	}
+	s.OCSPStapled += other.OCSPStapled
+	s.Resumed += other.Resumed
+	seen := make(map[CertificateInfo]struct{}, len(s.Certificates))
+	for _, c := range s.Certificates {
+		seen[c] = struct{}{}
+	}
+	for _, c := range other.Certificates {
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			s.Certificates = append(s.Certificates, c)
+		}
+	}
+}
+
+// CertificateInfo represents basic information about X.509 certificate presented by the server.
+type CertificateInfo struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// DoHConnectionStats aggregates number of DoH requests (streams) carried by the DoH connections opened to the benchmarked server.
+type DoHConnectionStats struct {
+	// Opened is number of opened DoH connections.
+	Opened int64
+	// Streams is number of DoH requests sent over all the connections.
+	Streams int64
+	// MinStreams is the lowest number of DoH requests carried by a single connection.
+	MinStreams int64
+	// MaxStreams is the highest number of DoH requests carried by a single connection.
+	MaxStreams int64
+}
+
+// Merge adds the connections aggregated by other DoHConnectionStats.
+func (s *DoHConnectionStats) Merge(other *DoHConnectionStats) {
+	if s.Opened == 0 {
+		*s = *other
+		return
+	}
+	if other.Opened == 0 {
+		return
+	}
+	s.Opened += other.Opened
+	s.Streams += other.Streams
+	s.MinStreams = min(s.MinStreams, other.MinStreams)
+	s.MaxStreams = max(s.MaxStreams, other.MaxStreams)
+}
+
+// connRecorder collects details about connections opened to the benchmarked server, i.e. TLS handshakes and number of streams
+// carried by each DoH connection. The details are aggregated while recording, so the memory used by the recorder does not
+// grow with the number of connections. Connections might be recorded concurrently by the transports dialing in background.
+type connRecorder struct {
+	mu    sync.Mutex
+	tls   *TLSStats
+	certs map[CertificateInfo]struct{}
+	doh   *DoHConnectionStats
+	// dohOpen contains number of streams carried by the DoH connections, which were not closed yet
+	dohOpen    map[int]int64
+	dohNextID  int
+	outOfOrder int64
+	idMismatch int64
+}
+
+// verifyConnection records the connection state, it is used as tls.Config.VerifyConnection callback, which is called
+// for each handshake even when certificate validation is disabled.
+func (r *connRecorder) verifyConnection(cs tls.ConnectionState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tls == nil {
+		r.tls = newTLSStats()
+		r.certs = make(map[CertificateInfo]struct{})
+	}
+	r.tls.Handshakes++
+	r.tls.Versions[tls.VersionName(cs.Version)]++
+	r.tls.CipherSuites[tls.CipherSuiteName(cs.CipherSuite)]++
+	if len(cs.NegotiatedProtocol) != 0 {
+		r.tls.ALPN[cs.NegotiatedProtocol]++
+	}
+	if len(cs.OCSPResponse) > 0 {
+		r.tls.OCSPStapled++
+	}
+	if cs.DidResume {
+		r.tls.Resumed++
+	}
+	for _, c := range cs.PeerCertificates {
+		info := CertificateInfo{
+			Subject:  c.Subject.String(),
+			Issuer:   c.Issuer.String(),
+			NotAfter: c.NotAfter,
+		}
+		if _, ok := r.certs[info]; ok {
+			continue
+		}
+		r.certs[info] = struct{}{}
+		r.tls.Certificates = append(r.tls.Certificates, info)
+	}
+	return nil
+}
+
+// newDoHConn records newly opened DoH connection and returns its identifier.
+func (r *connRecorder) newDoHConn() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dohOpen == nil {
+		r.dohOpen = make(map[int]int64)
+	}
+	id := r.dohNextID
+	r.dohNextID++
+	r.dohOpen[id] = 0
+	return id
+}
+
+// addDoHStream records DoH request (stream) sent using the connection with the identifier. Requests sent using the connection
+// after it was closed or flushed are not recorded.
+func (r *connRecorder) addDoHStream(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.dohOpen[id]; ok {
+		r.dohOpen[id]++
+	}
+}
+
+// closeDoHConn records closing of the DoH connection with the identifier, the number of streams carried by the connection
+// is added to the aggregated DoH connection stats.
+func (r *connRecorder) closeDoHConn(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finishDoHConn(id)
+}
+
+func (r *connRecorder) finishDoHConn(id int) {
+	streams, ok := r.dohOpen[id]
+	if !ok {
+		return
+	}
+	delete(r.dohOpen, id)
+	if r.doh == nil {
+		r.doh = &DoHConnectionStats{MinStreams: math.MaxInt64}
+	}
+	r.doh.Opened++
+	r.doh.Streams += streams
+	r.doh.MinStreams = min(r.doh.MinStreams, streams)
+	r.doh.MaxStreams = max(r.doh.MaxStreams, streams)
+}
+
+// addOutOfOrder records response received out of order on the pipelined connection.
+func (r *connRecorder) addOutOfOrder() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outOfOrder++
+}
+
+// addIDMismatch records response received on the pipelined connection, which does not match any query sent on the connection.
+func (r *connRecorder) addIDMismatch() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.idMismatch++
+}
+
+// flush moves all recorded connection details to the results and resets the recorder. DoH connections, which are still open,
+// are recorded with the streams carried so far.
+func (r *connRecorder) flush(st *ResultStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id := range r.dohOpen {
+		r.finishDoHConn(id)
+	}
+	if r.tls != nil {
+		if st.TLS == nil {
+			st.TLS = newTLSStats()
+		}
+		st.TLS.Merge(r.tls)
+	}
+	if r.doh != nil {
+		if st.DoHConnections == nil {
+			st.DoHConnections = &DoHConnectionStats{}
+		}
+		st.DoHConnections.Merge(r.doh)
+	}
+	st.Counters.OutOfOrder += r.outOfOrder
+	st.Counters.IDmismatch += r.idMismatch
+	r.tls = nil
+	r.certs = nil
+	r.doh = nil
+	r.outOfOrder = 0
+	r.idMismatch = 0
+}
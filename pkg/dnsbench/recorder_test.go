@@ -0,0 +1,69 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_connRecorder_aggregatesHandshakes(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "dns.example"}, Issuer: pkix.Name{CommonName: "Example CA"}, NotAfter: notAfter}
+	ca := &x509.Certificate{Subject: pkix.Name{CommonName: "Example CA"}, Issuer: pkix.Name{CommonName: "Example CA"}, NotAfter: notAfter}
+
+	rec := &connRecorder{}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, rec.verifyConnection(tls.ConnectionState{
+			Version:            tls.VersionTLS13,
+			CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+			NegotiatedProtocol: "h2",
+			DidResume:          i > 0,
+			PeerCertificates:   []*x509.Certificate{leaf, ca},
+		}))
+	}
+
+	st := &ResultStats{Counters: &Counters{}}
+	rec.flush(st)
+
+	assert.Equal(t, &TLSStats{
+		Handshakes:   100,
+		Versions:     map[string]int64{"TLS 1.3": 100},
+		CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 100},
+		ALPN:         map[string]int64{"h2": 100},
+		Resumed:      99,
+		Certificates: []CertificateInfo{
+			{Subject: "CN=dns.example", Issuer: "CN=Example CA", NotAfter: notAfter},
+			{Subject: "CN=Example CA", Issuer: "CN=Example CA", NotAfter: notAfter},
+		},
+	}, st.TLS)
+}
+
+func Test_connRecorder_flushMergesDoHConnections(t *testing.T) {
+	st := &ResultStats{Counters: &Counters{}}
+
+	worker := &connRecorder{}
+	id := worker.newDoHConn()
+	worker.addDoHStream(id)
+	worker.addDoHStream(id)
+	worker.closeDoHConn(id)
+	worker.flush(st)
+
+	shared := &connRecorder{}
+	open := shared.newDoHConn()
+	for i := 0; i < 5; i++ {
+		shared.addDoHStream(open)
+	}
+	// connection still open at the end of the benchmark is recorded with the streams carried so far
+	shared.flush(st)
+	// streams sent after the flush are not recorded
+	shared.addDoHStream(open)
+	shared.closeDoHConn(open)
+
+	assert.Equal(t, &DoHConnectionStats{Opened: 2, Streams: 7, MinStreams: 2, MaxStreams: 5}, st.DoHConnections)
+	assert.Nil(t, shared.doh)
+}
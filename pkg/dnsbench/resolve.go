@@ -0,0 +1,144 @@
+package dnsbench
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+)
+
+// hostResolver resolves hostnames of the benchmarked server. Static overrides (see Benchmark.Resolve) take precedence,
+// then bootstrap DNS resolver is used (see Benchmark.Bootstrap). When neither is configured, the addresses are left untouched
+// and resolved by the OS resolver while dialing.
+type hostResolver struct {
+	overrides map[string]net.IP
+	resolver  *net.Resolver
+}
+
+func newHostResolver(bootstrap string, overrides []string) (*hostResolver, error) {
+	r := hostResolver{overrides: make(map[string]net.IP)}
+
+	for _, o := range overrides {
+		// IPv6 addresses contain colons, hostnames do not, so split on the first colon only
+		host, ip, found := strings.Cut(o, ":")
+		if !found || len(host) == 0 {
+			return nil, fmt.Errorf("--resolve '%s' is not in correct format, <host>:<IP> is expected", o)
+		}
+		parsedIP := net.ParseIP(strings.Trim(ip, "[]"))
+		if parsedIP == nil {
+			return nil, fmt.Errorf("--resolve '%s' is not in correct format, '%s' is not an IP address", o, ip)
+		}
+		r.overrides[strings.ToLower(strings.TrimSuffix(host, "."))] = parsedIP
+	}
+
+	if len(bootstrap) != 0 {
+		if _, _, err := net.SplitHostPort(bootstrap); err != nil {
+			bootstrap = net.JoinHostPort(bootstrap, "53")
+		}
+		host, _, _ := net.SplitHostPort(bootstrap)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("--bootstrap '%s' must be an IP address", host)
+		}
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{}
+				return d.DialContext(ctx, network, bootstrap)
+			},
+		}
+	}
+	return &r, nil
+}
+
+// lookupIP resolves host to IP addresses, IP literals are returned as they are.
+func (r *hostResolver) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if r != nil {
+		if ip, ok := r.overrides[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+			return []net.IP{ip}, nil
+		}
+		if r.resolver != nil {
+			return r.resolver.LookupIP(ctx, "ip", host)
+		}
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// resolveAddr translates address in format host:port to IP:port using overrides or bootstrap resolver. If neither of them
+// is configured, the address is returned unchanged, so the dialer uses the OS resolver.
+func (r *hostResolver) resolveAddr(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	if r == nil {
+		return addr, nil
+	}
+	if _, ok := r.overrides[strings.ToLower(strings.TrimSuffix(host, "."))]; !ok && r.resolver == nil {
+		return addr, nil
+	}
+	ips, err := r.lookupIP(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// dialContext dials the address resolved using the hostResolver, it can be used as dial function of HTTP transports.
+func (r *hostResolver) dialContext(d *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		resolved, err := r.resolveAddr(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return d.DialContext(ctx, network, resolved)
+	}
+}
+
+// dialTLSContext dials TLS connection to the address resolved using the hostResolver, TLS config is expected to have
+// the server name already set, so the SNI contains the original hostname.
+func (r *hostResolver) dialTLSContext(d *net.Dialer) func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+		resolved, err := r.resolveAddr(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		tlsDialer := tls.Dialer{NetDialer: d, Config: cfg}
+		return tlsDialer.DialContext(ctx, network, resolved)
+	}
+}
+
+// dialQUIC dials QUIC connection to the address resolved using the hostResolver.
+func (r *hostResolver) dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	resolved, err := r.resolveAddr(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return quic.DialAddrEarly(ctx, resolved, tlsCfg, cfg)
+}
+
+// LookupIP resolves the host the same way as the Benchmark resolves the hostname of the benchmarked server,
+// i.e. using Benchmark.Resolve overrides and Benchmark.Bootstrap resolver if configured.
+func (b *Benchmark) LookupIP(host string) ([]net.IP, error) {
+	r := b.resolver
+	if r == nil {
+		var err error
+		if r, err = newHostResolver(b.Bootstrap, b.Resolve); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return r.lookupIP(ctx, host)
+}
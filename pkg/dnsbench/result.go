@@ -6,6 +6,7 @@ import (
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 	"github.com/tantalor93/doh-go/doh"
 )
 
@@ -25,6 +26,16 @@ type Counters struct {
 	IDmismatch int64
 	// Truncated is counter of all responses which had truncated flag.
 	Truncated int64
+	// TCFallback is counter of all queries retried over TCP, because the UDP response had truncated flag.
+	TCFallback int64
+	// TCFallbackError is counter of all queries retried over TCP, for which there was no answer over TCP.
+	TCFallbackError int64
+	// Retried is counter of all queries, which needed more than one attempt.
+	Retried int64
+	// OutOfOrder is counter of all responses received on the pipelined connection before response to a query sent earlier.
+	OutOfOrder int64
+	// InstanceChanges is counter of all responses returned by different server instance than the previous response of the worker.
+	InstanceChanges int64
 }
 
 // Datapoint one datapoint of benchmark (single DNS request).
@@ -50,6 +61,49 @@ type ResultStats struct {
 	Errors               []ErrorDatapoint
 	AuthenticatedDomains map[string]struct{}
 	DoHStatusCodes       map[int]int64
+	// TLS aggregates TLS handshakes of connections used by the worker, it is nil when no TLS handshake was done.
+	// Handshakes of connections shared between workers are part of the results of the first worker.
+	TLS *TLSStats
+	// DoHConnections aggregates DoH requests (streams) carried by DoH connections opened by the worker, it is nil when
+	// no DoH connection was opened. Connections shared between workers are part of the results of the first worker.
+	DoHConnections *DoHConnectionStats
+	// TCFallbackHist contains latencies of queries retried over TCP, the latency includes both UDP and TCP exchange.
+	// It is nil, when Benchmark.TCFallback is disabled.
+	TCFallbackHist *hdrhistogram.Histogram
+	// Attempts contains number of queries by the number of attempts needed, it is nil when Benchmark.Retries is 0.
+	Attempts map[int]int64
+	// FirstAttemptHist contains latencies of the first attempts of the queries, while Hist contains end-to-end latencies
+	// including all the attempts. It is nil when Benchmark.Retries is 0.
+	FirstAttemptHist *hdrhistogram.Histogram
+	// MalformedReactions contains number of server reactions (see Reaction* constants, otherwise the response code)
+	// by the mutation of the malformed query. It is nil when Benchmark.Malformed is disabled.
+	MalformedReactions map[string]map[string]int64
+	// ECS contains results of the queries by the EDNS Client Subnet used, it is nil when Benchmark.ECS is not configured.
+	ECS map[string]*ECSStats
+	// ECSAnswers contains fingerprint of the last successful answer by the EDNS Client Subnet used for each question,
+	// so it is possible to find out whether the answers differ per client subnet. It is nil when Benchmark.ECS is not configured.
+	ECSAnswers map[string]map[string]string
+	// NSIDs contains number of responses by the NSID returned by the server.
+	NSIDs map[string]int64
+	// ExtendedErrors contains number of Extended DNS Errors (RFC 8914) returned by the server by the info code and extra text.
+	ExtendedErrors map[ExtendedError]int64
+	// Instances contains results by the identifier of the server instance which answered the queries (see Benchmark.InstanceID),
+	// responses of unidentified instances are under empty string. It is nil when Benchmark.InstanceID is not configured.
+	Instances map[string]*InstanceStats
+	// DNSSECValidation contains results of the DNSSEC validation benchmark, it is nil when Benchmark.DNSSECValidation is disabled.
+	DNSSECValidation *DNSSECValidationStats
+	// InjectedFaults contains faults injected between the workers and the server (see Benchmark.Faults). The proxy is shared
+	// by all the workers, so the faults are part of the results of the first worker. It is nil when no faults are configured.
+	InjectedFaults *faultproxy.Stats
+
+	// lastInstance is identifier of the server instance which answered the previous query of the worker.
+	lastInstance string
+}
+
+// ExtendedError identifies Extended DNS Error (RFC 8914) returned by the server.
+type ExtendedError struct {
+	InfoCode  uint16
+	ExtraText string
 }
 
 func newResultStats(b *Benchmark) *ResultStats {
@@ -61,6 +115,31 @@ func newResultStats(b *Benchmark) *ResultStats {
 	if b.useDoH {
 		st.DoHStatusCodes = make(map[int]int64)
 	}
+	if b.TCFallback {
+		st.TCFallbackHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
+	if len(b.ECS) > 0 {
+		st.ECS = make(map[string]*ECSStats)
+		st.ECSAnswers = make(map[string]map[string]string)
+	}
+	if b.DNSSECValidation {
+		st.DNSSECValidation = &DNSSECValidationStats{
+			Cases:         make(map[string]*DNSSECCaseStats),
+			Algorithms:    make(map[uint8]*DNSSECAlgorithmStats),
+			ValidatedHist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			UncheckedHist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if len(b.InstanceID) > 0 {
+		st.Instances = make(map[string]*InstanceStats)
+	}
+	if b.Malformed {
+		st.MalformedReactions = make(map[string]map[string]int64)
+	}
+	if b.Retries > 0 {
+		st.Attempts = make(map[int]int64)
+		st.FirstAttemptHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
 	st.Counters = &Counters{}
 	return st
 }
@@ -92,6 +171,8 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 		rs.Counters.Truncated++
 	}
 
+	rs.recordEDNS(resp)
+
 	if resp.Rcode == dns.RcodeSuccess {
 		if resp.Id != req.Id {
 			rs.Counters.IDmismatch++
@@ -130,3 +211,40 @@ func (rs *ResultStats) record(req *dns.Msg, resp *dns.Msg, err error, time time.
 	rs.Hist.RecordValue(duration.Nanoseconds())
 	rs.Timings = append(rs.Timings, Datapoint{Duration: duration, Start: time})
 }
+
+// recordTCFallback records query, which was retried over TCP, because the UDP response had truncated flag.
+func (rs *ResultStats) recordTCFallback(err error, duration time.Duration) {
+	rs.Counters.Truncated++
+	rs.Counters.TCFallback++
+	if err != nil {
+		rs.Counters.TCFallbackError++
+		return
+	}
+	if rs.TCFallbackHist != nil {
+		rs.TCFallbackHist.RecordValue(duration.Nanoseconds())
+	}
+}
+
+// recordAttempts records number of attempts of the query and latency of the first attempt, if it was answered.
+func (rs *ResultStats) recordAttempts(attempts int, firstAttempt time.Duration) {
+	if attempts > 1 {
+		rs.Counters.Retried++
+	}
+	if rs.Attempts != nil {
+		rs.Attempts[attempts]++
+	}
+	if rs.FirstAttemptHist != nil && firstAttempt > 0 {
+		rs.FirstAttemptHist.RecordValue(firstAttempt.Nanoseconds())
+	}
+}
+
+// recordMalformed records reaction of the server to the malformed query created using the mutation.
+func (rs *ResultStats) recordMalformed(mutation, reaction string) {
+	if rs.MalformedReactions == nil {
+		return
+	}
+	if rs.MalformedReactions[mutation] == nil {
+		rs.MalformedReactions[mutation] = make(map[string]int64)
+	}
+	rs.MalformedReactions[mutation][reaction]++
+}
@@ -0,0 +1,136 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// initFallbacks prepares copies of the Benchmark for each of the Benchmark.FallbackServers, so the fallback servers
+// can use the same query factories as the benchmarked server.
+func (b *Benchmark) initFallbacks() error {
+	b.fallbacks = nil
+	for _, server := range b.FallbackServers {
+		isDoH, _ := isHTTPUrl(server)
+		isDoQ := strings.HasPrefix(server, "quic://")
+		if isDoH != b.useDoH || isDoQ != b.useQuic {
+			return fmt.Errorf("fallback server '%s' must use the same protocol as the benchmarked server", server)
+		}
+
+		f := *b
+		f.FallbackServers = nil
+		f.fallbacks = nil
+		f.Server = strings.TrimPrefix(server, "quic://")
+		if isDoH {
+			parsedURL, err := url.Parse(f.Server)
+			if err != nil {
+				return err
+			}
+			if len(parsedURL.Path) == 0 {
+				f.Server += "/dns-query"
+			}
+		}
+		f.addPortIfMissing()
+		b.fallbacks = append(b.fallbacks, &f)
+	}
+	return nil
+}
+
+// retryingQuery sends queries according to the retry policy configured by Benchmark.Retries, Benchmark.RetryTimeout,
+// Benchmark.RetryBackoff and Benchmark.FallbackServers. The benchmarked server is used for the first attempt,
+// the following attempts rotate over the fallback servers and the benchmarked server.
+type retryingQuery struct {
+	b *Benchmark
+	// queries contains query functions of the benchmarked server followed by the fallback servers.
+	queries []queryFunc
+	// tcFallbacks contains query functions used for retrying truncated responses over TCP, nil when Benchmark.TCFallback is disabled.
+	tcFallbacks []queryFunc
+}
+
+// queryOutcome is result of the query including all the attempts.
+type queryOutcome struct {
+	resp     *dns.Msg
+	err      error
+	attempts int
+	// firstAttempt is latency of the first attempt, it is 0 if the first attempt was not answered.
+	firstAttempt time.Duration
+	// tcFallback is true if the query was retried over TCP, because the UDP response was truncated.
+	tcFallback    bool
+	tcFallbackErr error
+	// mutation is the mutation used for the malformed query and reaction is the classified reaction of the server,
+	// both are empty, when Benchmark.Malformed is disabled.
+	mutation string
+	reaction string
+}
+
+func newRetryingQuery(b *Benchmark, query queryFunc, fallbackFactories []queryFuncFactory, workerID uint32, worker *connRecorder) *retryingQuery {
+	r := retryingQuery{b: b, queries: []queryFunc{query}}
+	for _, f := range fallbackFactories {
+		r.queries = append(r.queries, f(workerID, worker))
+	}
+	if b.TCFallback {
+		r.tcFallbacks = append(r.tcFallbacks, tcpFallbackQuery(b, workerID))
+		for _, f := range b.fallbacks {
+			r.tcFallbacks = append(r.tcFallbacks, tcpFallbackQuery(f, workerID))
+		}
+	}
+	return &r
+}
+
+func (r *retryingQuery) exchange(ctx context.Context, req *dns.Msg) queryOutcome {
+	var out queryOutcome
+	backoff := r.b.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		i := attempt % len(r.queries)
+		attemptCtx := ctx
+		cancel := func() {}
+		if r.b.Retries > 0 && r.b.RetryTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, r.b.RetryTimeout)
+		}
+		start := time.Now()
+		out.attempts++
+		out.resp, out.err = r.queries[i](attemptCtx, req)
+		if r.tcFallbacks != nil && out.err == nil && out.resp.Truncated {
+			// retry over TCP within the same attempt, the latency of the attempt includes both exchanges
+			out.tcFallback = true
+			out.resp, out.err = r.tcFallbacks[i](attemptCtx, req)
+			out.tcFallbackErr = out.err
+		}
+		cancel()
+		if attempt == 0 && out.err == nil {
+			out.firstAttempt = time.Since(start)
+		}
+
+		if attempt >= r.b.Retries || !shouldRetry(out.resp, out.err) || ctx.Err() != nil {
+			return out
+		}
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return out
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// shouldRetry returns true if the attempt failed and stub resolver would retry it.
+func shouldRetry(resp *dns.Msg, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	switch resp.Rcode {
+	case dns.RcodeServerFailure, dns.RcodeNotImplemented, dns.RcodeRefused:
+		return true
+	default:
+		return false
+	}
+}
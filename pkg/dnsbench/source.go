@@ -0,0 +1,145 @@
+package dnsbench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/miekg/dns"
+)
+
+// maxSourcePortAttempts is the number of randomly chosen source ports tried, before giving up on binding the socket.
+const maxSourcePortAttempts = 10
+
+// sourceBinder chooses local address and port of the sockets used for plain DNS. Workers are spread over the source
+// addresses in round-robin fashion, the source port is chosen randomly from the configured range for each new socket.
+type sourceBinder struct {
+	ips     []net.IP
+	minPort int
+	maxPort int
+}
+
+func newSourceBinder(addrs []string, iface string, ports string) (*sourceBinder, error) {
+	if len(addrs) == 0 && len(iface) == 0 && len(ports) == 0 {
+		return nil, nil
+	}
+	s := sourceBinder{}
+
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return nil, fmt.Errorf("--source-addr '%s' is not an IP address", a)
+		}
+		s.ips = append(s.ips, ip)
+	}
+
+	if len(iface) != 0 {
+		netIface, err := net.InterfaceByName(iface)
+		if err != nil {
+			return nil, fmt.Errorf("--source-interface '%s' is not valid: %w", iface, err)
+		}
+		ifaceAddrs, err := netIface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses of --source-interface '%s': %w", iface, err)
+		}
+		var found bool
+		for _, a := range ifaceAddrs {
+			if ipNet, ok := a.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				s.ips = append(s.ips, ipNet.IP)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("--source-interface '%s' does not have any usable IP address", iface)
+		}
+	}
+
+	if len(ports) != 0 {
+		from, to, isRange := strings.Cut(ports, "-")
+		if !isRange {
+			to = from
+		}
+		minPort, err := strconv.ParseUint(from, 10, 16)
+		if err != nil || minPort == 0 {
+			return nil, fmt.Errorf("--source-port '%s' is not in correct format, <port> or <min>-<max> is expected", ports)
+		}
+		maxPort, err := strconv.ParseUint(to, 10, 16)
+		if err != nil || maxPort < minPort {
+			return nil, fmt.Errorf("--source-port '%s' is not in correct format, <port> or <min>-<max> is expected", ports)
+		}
+		s.minPort, s.maxPort = int(minPort), int(maxPort)
+	}
+	return &s, nil
+}
+
+// sockets returns number of sockets, which can be bound at once using the configured source addresses and ports.
+func (s *sourceBinder) sockets() int {
+	return (s.maxPort - s.minPort + 1) * max(len(s.ips), 1)
+}
+
+// localAddr returns local address for a new socket of the worker connecting to the remote address.
+func (s *sourceBinder) localAddr(workerID uint32, network string, remote string) (net.Addr, error) {
+	var ip net.IP
+	if len(s.ips) != 0 {
+		host, _, err := net.SplitHostPort(remote)
+		if err != nil {
+			return nil, err
+		}
+		remoteIP := net.ParseIP(host)
+		// only source addresses of the same IP family as the remote address can be used
+		var candidates []net.IP
+		for _, c := range s.ips {
+			if remoteIP == nil || (c.To4() == nil) == (remoteIP.To4() == nil) {
+				candidates = append(candidates, c)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no source address of the same IP family as '%s'", remote)
+		}
+		ip = candidates[int(workerID)%len(candidates)]
+	}
+
+	var port int
+	if s.maxPort != 0 {
+		// nolint:gosec
+		port = s.minPort + rand.Intn(s.maxPort-s.minPort+1)
+	}
+
+	if network == UDPTransport {
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// dialDNS opens connection to the benchmarked server using the DNS client. When source address or port is configured,
+// the socket is bound accordingly, if the randomly chosen source port is already in use, another one is tried.
+func dialDNS(ctx context.Context, b *Benchmark, dnsClient *dns.Client, workerID uint32) (*dns.Conn, error) {
+	addr, err := b.dialAddr(ctx, dnsClient.Net)
+	if err != nil {
+		return nil, err
+	}
+	if b.source == nil {
+		return dnsClient.DialContext(ctx, addr)
+	}
+
+	attempts := 1
+	if b.source.maxPort > b.source.minPort {
+		attempts = maxSourcePortAttempts
+	}
+	for i := 0; ; i++ {
+		local, err := b.source.localAddr(workerID, dnsClient.Net, addr)
+		if err != nil {
+			return nil, err
+		}
+		dnsClient.Dialer = &net.Dialer{Timeout: b.ConnectTimeout, LocalAddr: local}
+		co, err := dnsClient.DialContext(ctx, addr)
+		if err == nil || i+1 >= attempts || !errors.Is(err, syscall.EADDRINUSE) {
+			return co, err
+		}
+	}
+}
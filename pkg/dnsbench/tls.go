@@ -0,0 +1,110 @@
+package dnsbench
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var tlsVersions = map[string]uint16{
+	TLSVersion10: tls.VersionTLS10,
+	TLSVersion11: tls.VersionTLS11,
+	TLSVersion12: tls.VersionTLS12,
+	TLSVersion13: tls.VersionTLS13,
+}
+
+// initTLSConfig validates TLS related Benchmark settings and prepares TLS config shared by all encrypted transports.
+func (b *Benchmark) initTLSConfig() error {
+	// nolint:gosec
+	conf := &tls.Config{InsecureSkipVerify: b.Insecure, ServerName: b.ServerName}
+
+	if len(b.CACertFile) != 0 {
+		pem, err := os.ReadFile(b.CACertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle '%s': %w", b.CACertFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA bundle '%s' does not contain any PEM encoded certificate", b.CACertFile)
+		}
+		conf.RootCAs = pool
+	}
+
+	if len(b.ClientCertFile) != 0 || len(b.ClientKeyFile) != 0 {
+		if len(b.ClientCertFile) == 0 || len(b.ClientKeyFile) == 0 {
+			return errors.New("--tls-cert and --tls-key must be specified together")
+		}
+		cert, err := tls.LoadX509KeyPair(b.ClientCertFile, b.ClientKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(b.TLSMinVersion) != 0 {
+		v, ok := tlsVersions[b.TLSMinVersion]
+		if !ok {
+			return fmt.Errorf("--tls-min-version '%s' is not supported TLS version", b.TLSMinVersion)
+		}
+		conf.MinVersion = v
+	}
+	if len(b.TLSMaxVersion) != 0 {
+		v, ok := tlsVersions[b.TLSMaxVersion]
+		if !ok {
+			return fmt.Errorf("--tls-max-version '%s' is not supported TLS version", b.TLSMaxVersion)
+		}
+		conf.MaxVersion = v
+	}
+	if conf.MinVersion != 0 && conf.MaxVersion != 0 && conf.MinVersion > conf.MaxVersion {
+		return errors.New("--tls-min-version must not be higher than --tls-max-version")
+	}
+
+	for _, name := range b.TLSCipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return fmt.Errorf("--tls-cipher-suite '%s' is not supported cipher suite", name)
+		}
+		conf.CipherSuites = append(conf.CipherSuites, id)
+	}
+
+	b.tlsConfig = conf
+	return nil
+}
+
+// newTLSConfig returns copy of the TLS config shared by all encrypted transports, each transport may adjust the copy.
+// When Benchmark.ServerName is not set, the serverName is used for SNI and certificate validation. Handshakes done
+// using the returned config are recorded by the recorder, if not nil.
+func (b *Benchmark) newTLSConfig(serverName string, rec *connRecorder) *tls.Config {
+	var conf *tls.Config
+	if b.tlsConfig == nil {
+		// nolint:gosec
+		conf = &tls.Config{InsecureSkipVerify: b.Insecure, ServerName: b.ServerName}
+	} else {
+		conf = b.tlsConfig.Clone()
+	}
+	if len(conf.ServerName) == 0 {
+		conf.ServerName = serverName
+	}
+	if rec != nil {
+		conf.VerifyConnection = rec.verifyConnection
+	}
+	return conf
+}
+
+// cipherSuiteID looks up cipher suite by its standard name, as used by crypto/tls package.
+// Note that TLS 1.3 cipher suites are not configurable.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, s := range tls.CipherSuites() {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,3 @@
+// Package faultproxy contains UDP and TCP proxy injecting network faults (packet loss, delay, jitter, reordering
+// and duplication) between DNS clients and DNS server, so it is possible to see how the clients degrade when the network is bad.
+package faultproxy
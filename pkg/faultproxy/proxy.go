@@ -0,0 +1,442 @@
+package faultproxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxDatagramSize is the maximum size of the proxied UDP datagram.
+	maxDatagramSize = 65535
+	// reorderHold is the maximum time the reordered datagram is held back, when no other datagram is sent in the meantime.
+	reorderHold = 10 * time.Millisecond
+	// DefaultIdleTimeout is the default time after which the UDP session of the client, which sent no query, is closed.
+	DefaultIdleTimeout = 10 * time.Second
+)
+
+// Config configures faults injected by the Proxy. The rates are fractions of the datagrams in the interval [0, 1].
+type Config struct {
+	// Loss is the fraction of the datagrams dropped, it is applied to queries and responses independently.
+	Loss float64
+	// Delay is added to each datagram and each chunk of TCP stream.
+	Delay time.Duration
+	// Jitter is maximum random delay added on top of Delay.
+	Jitter time.Duration
+	// Reorder is the fraction of the datagrams held back and sent after the following datagram.
+	Reorder float64
+	// Duplicate is the fraction of the datagrams sent twice.
+	Duplicate float64
+	// IdleTimeout is the time after which the UDP session of the client, which sent no query, is closed together with its
+	// upstream socket. DefaultIdleTimeout is used, when not set. The timeout is never shorter than the maximum injected delay.
+	IdleTimeout time.Duration
+}
+
+// Enabled returns true, when any fault is configured.
+func (c Config) Enabled() bool {
+	return c.Loss > 0 || c.Delay > 0 || c.Jitter > 0 || c.Reorder > 0 || c.Duplicate > 0
+}
+
+// Validate checks that the rates are valid fractions.
+func (c Config) Validate() error {
+	for name, rate := range map[string]float64{"loss": c.Loss, "reorder": c.Reorder, "duplicate": c.Duplicate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate %v is not in the interval [0, 1]", name, rate)
+		}
+	}
+	if c.Delay < 0 || c.Jitter < 0 {
+		return errors.New("delay and jitter cannot be negative")
+	}
+	if c.IdleTimeout < 0 {
+		return errors.New("idle timeout cannot be negative")
+	}
+	return nil
+}
+
+// Stats represents faults injected by the Proxy.
+type Stats struct {
+	// QueriesDropped is counter of all datagrams from the clients dropped by the proxy.
+	QueriesDropped int64
+	// ResponsesDropped is counter of all datagrams from the server dropped by the proxy.
+	ResponsesDropped int64
+	// Duplicated is counter of all datagrams sent twice.
+	Duplicated int64
+	// Reordered is counter of all datagrams sent after the following datagram.
+	Reordered int64
+	// Delayed is counter of all datagrams and TCP chunks delayed by the proxy.
+	Delayed int64
+	// DelayTotal is the sum of all injected delays.
+	DelayTotal time.Duration
+}
+
+type counters struct {
+	queriesDropped   atomic.Int64
+	responsesDropped atomic.Int64
+	duplicated       atomic.Int64
+	reordered        atomic.Int64
+	delayed          atomic.Int64
+	delayTotal       atomic.Int64
+}
+
+// Proxy forwards UDP datagrams and TCP streams received on its listeners to the upstream server, while injecting faults.
+type Proxy struct {
+	// UDPAddr and TCPAddr are the addresses of the proxy listeners.
+	UDPAddr string
+	TCPAddr string
+
+	config      Config
+	idleTimeout time.Duration
+	upstream    string
+	udp         net.PacketConn
+	tcp         net.Listener
+	counters    counters
+	closed      atomic.Bool
+	wg          sync.WaitGroup
+
+	mu       sync.Mutex
+	sessions map[string]*udpSession
+	conns    map[net.Conn]struct{}
+	rnd      *rand.Rand
+}
+
+// Start starts the proxy listening on the listen address (both UDP and TCP) forwarding to the upstream address.
+// If the port of the listen address is 0, the UDP and TCP listeners may use different ports.
+func Start(listen, upstream string, config Config) (*Proxy, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	udp, err := net.ListenPacket("udp", listen)
+	if err != nil {
+		return nil, err
+	}
+	tcp, err := net.Listen("tcp", listen)
+	if err != nil {
+		_ = udp.Close()
+		return nil, err
+	}
+	idleTimeout := config.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	// the delayed queries have to be sent, before the session is closed
+	idleTimeout = max(idleTimeout, config.Delay+config.Jitter+reorderHold)
+	p := &Proxy{
+		UDPAddr:     udp.LocalAddr().String(),
+		TCPAddr:     tcp.Addr().String(),
+		config:      config,
+		idleTimeout: idleTimeout,
+		upstream:    upstream,
+		udp:         udp,
+		tcp:         tcp,
+		sessions:    make(map[string]*udpSession),
+		conns:       make(map[net.Conn]struct{}),
+		// nolint:gosec
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	p.wg.Add(2)
+	go p.serveUDP()
+	go p.serveTCP()
+	return p, nil
+}
+
+// Stats returns faults injected so far.
+func (p *Proxy) Stats() Stats {
+	return Stats{
+		QueriesDropped:   p.counters.queriesDropped.Load(),
+		ResponsesDropped: p.counters.responsesDropped.Load(),
+		Duplicated:       p.counters.duplicated.Load(),
+		Reordered:        p.counters.reordered.Load(),
+		Delayed:          p.counters.delayed.Load(),
+		DelayTotal:       time.Duration(p.counters.delayTotal.Load()),
+	}
+}
+
+// Sessions returns number of the open UDP sessions, the session is open for each client address, which sent a query
+// within the idle timeout.
+func (p *Proxy) Sessions() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+// Close stops the proxy and closes all the proxied connections.
+func (p *Proxy) Close() error {
+	if p.closed.Swap(true) {
+		return nil
+	}
+	err := errors.Join(p.udp.Close(), p.tcp.Close())
+	p.mu.Lock()
+	for _, s := range p.sessions {
+		_ = s.upstream.Close()
+	}
+	for c := range p.conns {
+		_ = c.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Proxy) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rnd.Float64() < rate
+}
+
+func (p *Proxy) delay() time.Duration {
+	d := p.config.Delay
+	if p.config.Jitter > 0 {
+		p.mu.Lock()
+		d += time.Duration(p.rnd.Int63n(int64(p.config.Jitter)))
+		p.mu.Unlock()
+	}
+	if d > 0 {
+		p.counters.delayed.Add(1)
+		p.counters.delayTotal.Add(int64(d))
+	}
+	return d
+}
+
+// direction sends datagrams in one direction (either queries or responses) while injecting the faults.
+type direction struct {
+	p       *Proxy
+	send    func([]byte)
+	dropped *atomic.Int64
+
+	mu    sync.Mutex
+	held  []byte
+	timer *time.Timer
+}
+
+func (d *direction) forward(datagram []byte) {
+	p := d.p
+	if p.chance(p.config.Loss) {
+		d.dropped.Add(1)
+		return
+	}
+	copies := 1
+	if p.chance(p.config.Duplicate) {
+		p.counters.duplicated.Add(1)
+		copies = 2
+	}
+	reorder := p.chance(p.config.Reorder)
+	delay := p.delay()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if p.closed.Load() {
+			return
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if reorder && d.held == nil {
+			p.counters.reordered.Add(1)
+			d.held = datagram
+			d.timer = time.AfterFunc(reorderHold, d.flush)
+			return
+		}
+		for i := 0; i < copies; i++ {
+			d.send(datagram)
+		}
+		if d.held != nil {
+			d.timer.Stop()
+			d.send(d.held)
+			d.held = nil
+		}
+	}()
+}
+
+// flush sends the held datagram, when no other datagram was sent during reorderHold.
+func (d *direction) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.held != nil && !d.p.closed.Load() {
+		d.send(d.held)
+		d.held = nil
+	}
+}
+
+// udpSession represents datagrams exchanged between single client address and the upstream server.
+type udpSession struct {
+	upstream  net.Conn
+	queries   *direction
+	responses *direction
+	// lastQuery is the time of the last query of the client, it is guarded by the proxy lock
+	lastQuery time.Time
+}
+
+func (p *Proxy) serveUDP() {
+	defer p.wg.Done()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, client, err := p.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		datagram := append([]byte(nil), buf[:n]...)
+		s, err := p.session(client)
+		if err != nil {
+			continue
+		}
+		s.queries.forward(datagram)
+	}
+}
+
+func (p *Proxy) session(client net.Addr) (*udpSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := client.String()
+	if s, ok := p.sessions[key]; ok {
+		s.lastQuery = time.Now()
+		return s, nil
+	}
+	upstream, err := net.Dial("udp", p.upstream)
+	if err != nil {
+		return nil, err
+	}
+	s := &udpSession{upstream: upstream, lastQuery: time.Now()}
+	s.queries = &direction{p: p, dropped: &p.counters.queriesDropped, send: func(b []byte) {
+		_, _ = upstream.Write(b)
+	}}
+	s.responses = &direction{p: p, dropped: &p.counters.responsesDropped, send: func(b []byte) {
+		_, _ = p.udp.WriteTo(b, client)
+	}}
+	p.sessions[key] = s
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		buf := make([]byte, maxDatagramSize)
+		for {
+			_ = upstream.SetReadDeadline(time.Now().Add(p.idleTimeout))
+			n, err := upstream.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					if p.expire(key, s) {
+						return
+					}
+					continue
+				}
+				if !errors.Is(err, net.ErrClosed) && !p.closed.Load() {
+					// e.g. ICMP port unreachable, keep the session until the proxy is closed
+					continue
+				}
+				return
+			}
+			s.responses.forward(append([]byte(nil), buf[:n]...))
+		}
+	}()
+	return s, nil
+}
+
+// expire closes the session, when the client sent no query within the idle timeout, so the clients, which change their
+// source port (e.g. redial after timeout), do not exhaust the file descriptors of the proxy.
+func (p *Proxy) expire(key string, s *udpSession) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(s.lastQuery) < p.idleTimeout {
+		return false
+	}
+	if p.sessions[key] == s {
+		delete(p.sessions, key)
+	}
+	_ = s.upstream.Close()
+	return true
+}
+
+func (p *Proxy) serveTCP() {
+	defer p.wg.Done()
+	for {
+		client, err := p.tcp.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.proxyTCP(client)
+		}()
+	}
+}
+
+// proxyTCP forwards the TCP stream, only delay and jitter are injected, since the other faults are handled by TCP itself.
+func (p *Proxy) proxyTCP(client net.Conn) {
+	upstream, err := net.Dial("tcp", p.upstream)
+	if err != nil {
+		_ = client.Close()
+		return
+	}
+	if !p.track(client, upstream) {
+		return
+	}
+	defer p.untrack(client, upstream)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.copyDelayed(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.copyDelayed(client, upstream)
+		done <- struct{}{}
+	}()
+	// when one direction is closed, the other is closed as well
+	<-done
+	_ = client.Close()
+	_ = upstream.Close()
+	<-done
+}
+
+func (p *Proxy) track(conns ...net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed.Load() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+		return false
+	}
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+	return true
+}
+
+func (p *Proxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		delete(p.conns, c)
+	}
+}
+
+func (p *Proxy) copyDelayed(dst io.Writer, src io.Reader) {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if d := p.delay(); d > 0 {
+				time.Sleep(d)
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package faultproxy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
+	"github.com/tantalor93/dnspyre/v3/pkg/server"
+)
+
+func startProxy(t *testing.T, config faultproxy.Config) *faultproxy.Proxy {
+	t.Helper()
+	s, err := server.New(server.Config{Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	require.NoError(t, s.Start())
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	p, err := faultproxy.Start("127.0.0.1:0", s.Addr, config)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+	return p
+}
+
+func TestProxy_passthrough(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{})
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			addr := p.UDPAddr
+			if network == "tcp" {
+				addr = p.TCPAddr
+			}
+			c := dns.Client{Net: network}
+			resp, _, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), addr)
+			require.NoError(t, err)
+			require.Len(t, resp.Answer, 1)
+			assert.Equal(t, "127.0.0.1", resp.Answer[0].(*dns.A).A.String())
+		})
+	}
+	assert.Equal(t, faultproxy.Stats{}, p.Stats())
+}
+
+func TestProxy_loss(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{Loss: 1})
+
+	c := dns.Client{Timeout: 200 * time.Millisecond}
+	_, _, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), p.UDPAddr)
+	require.Error(t, err)
+
+	st := p.Stats()
+	assert.Equal(t, int64(1), st.QueriesDropped)
+	assert.Zero(t, st.ResponsesDropped)
+}
+
+func TestProxy_sessionIdleTimeout(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{Loss: 1, IdleTimeout: 100 * time.Millisecond})
+
+	// each exchange uses new source port, as the benchmark redials after every timeout
+	c := dns.Client{Timeout: 10 * time.Millisecond}
+	maxSessions := 0
+	for i := 0; i < 50; i++ {
+		_, _, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), p.UDPAddr)
+		require.Error(t, err)
+		maxSessions = max(maxSessions, p.Sessions())
+	}
+
+	assert.Equal(t, int64(50), p.Stats().QueriesDropped)
+	assert.Less(t, maxSessions, 50)
+	assert.Eventually(t, func() bool {
+		return p.Sessions() == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestProxy_duplicate(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{Duplicate: 1})
+
+	conn, err := dns.Dial("udp", p.UDPAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	m := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	require.NoError(t, conn.WriteMsg(m))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+
+	// the query is duplicated, so the server answers twice and each of the answers is duplicated again
+	for i := 0; i < 4; i++ {
+		resp, err := conn.ReadMsg()
+		require.NoError(t, err)
+		assert.Equal(t, m.Id, resp.Id)
+	}
+	assert.Equal(t, int64(3), p.Stats().Duplicated)
+}
+
+func TestProxy_reorder(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{Reorder: 1})
+
+	c := dns.Client{Timeout: time.Second}
+	resp, _, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), p.UDPAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+
+	// the held back datagrams are released after a while, even when no other datagram follows
+	assert.Equal(t, int64(2), p.Stats().Reordered)
+}
+
+func TestProxy_delay(t *testing.T) {
+	p := startProxy(t, faultproxy.Config{Delay: 50 * time.Millisecond})
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			addr := p.UDPAddr
+			if network == "tcp" {
+				addr = p.TCPAddr
+			}
+			c := dns.Client{Net: network, Timeout: time.Second}
+			_, rtt, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), addr)
+			require.NoError(t, err)
+			// both the query and the response are delayed
+			assert.GreaterOrEqual(t, rtt, 100*time.Millisecond)
+		})
+	}
+	st := p.Stats()
+	assert.GreaterOrEqual(t, st.Delayed, int64(4))
+	assert.GreaterOrEqual(t, st.DelayTotal, 200*time.Millisecond)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  faultproxy.Config
+		wantErr bool
+	}{
+		{name: "valid", config: faultproxy.Config{Loss: 0.5, Reorder: 1, Duplicate: 0, Delay: time.Millisecond}},
+		{name: "loss over 1", config: faultproxy.Config{Loss: 1.5}, wantErr: true},
+		{name: "negative duplicate", config: faultproxy.Config{Duplicate: -0.1}, wantErr: true},
+		{name: "negative idle timeout", config: faultproxy.Config{IdleTimeout: -time.Second}, wantErr: true},
+		{name: "negative delay", config: faultproxy.Config{Delay: -time.Second}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ import (
 // GeoService provides IP geolocation services
 type GeoService struct {
 	db *geoip2.Reader
+	// LookupIP is used for resolving hostnames of DNS servers, by default the OS resolver is used
+	LookupIP func(host string) ([]net.IP, error)
 }
 
 // NewGeoService creates a new geo service with embedded GeoIP data
@@ -39,7 +41,7 @@ func NewGeoService() (*GeoService, error) {
 		return nil, fmt.Errorf("GeoIP service not available - database not found: %v", err)
 	}
 
-	return &GeoService{db: db}, nil
+	return &GeoService{db: db, LookupIP: net.LookupIP}, nil
 }
 
 // CheckGeo analyzes a DNS server address and returns its IP and country code
@@ -78,7 +80,7 @@ func (g *GeoService) CheckGeo(server string, preferIPv4 bool) (string, string, e
 		}
 
 		// Resolve to IP
-		ips, err := net.LookupIP(server)
+		ips, err := g.lookupIP(server)
 		if err != nil || len(ips) == 0 {
 			return "0.0.0.0", "PRIVATE", fmt.Errorf("unable to resolve IP address")
 		}
@@ -107,7 +109,7 @@ func (g *GeoService) CheckGeo(server string, preferIPv4 bool) (string, string, e
 			}
 		}
 
-		ips, err := net.LookupIP(server)
+		ips, err := g.lookupIP(server)
 		if err != nil || len(ips) == 0 {
 			return "0.0.0.0", "PRIVATE", fmt.Errorf("local resolver cannot resolve host IP address")
 		}
@@ -122,6 +124,14 @@ func (g *GeoService) CheckGeo(server string, preferIPv4 bool) (string, string, e
 	return ip.String(), geoCode, err
 }
 
+// lookupIP resolves host using configured LookupIP function, falling back to the OS resolver
+func (g *GeoService) lookupIP(host string) ([]net.IP, error) {
+	if g.LookupIP == nil {
+		return net.LookupIP(host)
+	}
+	return g.LookupIP(host)
+}
+
 // checkIPGeo queries the GeoIP database for country information
 func (g *GeoService) checkIPGeo(ip net.IP) (string, error) {
 	if g.db == nil {
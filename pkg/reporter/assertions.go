@@ -0,0 +1,91 @@
+package reporter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+const (
+	// AssertionFailCondition is the kind of assertions created from dnsbench.Benchmark.FailConditions.
+	AssertionFailCondition = "fail"
+	// AssertionSLO is the kind of assertions created from dnsbench.Benchmark.SLOs.
+	AssertionSLO = "slo"
+)
+
+// AssertionSuite represents assertions evaluated against results of a single benchmark run. JUnit and TAP reports contain
+// a test case for the benchmark run itself and for each assertion.
+type AssertionSuite struct {
+	// Name identifies the benchmark run, usually the benchmarked server.
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+	// Error is set, when the benchmark failed to run, the assertions are not evaluated in such case.
+	Error      string
+	Assertions []AssertionResult
+	// SLO are the detailed results of the SLO thresholds, which are included in Assertions as well.
+	SLO []ThresholdResult
+}
+
+// AssertionResult represents result of a single assertion.
+type AssertionResult struct {
+	// Kind is either AssertionFailCondition or AssertionSLO.
+	Kind string
+	// Name is the fail condition (e.g. ioerror) or the SLO threshold (e.g. p99<50ms).
+	Name    string
+	Passed  bool
+	Message string
+}
+
+// Tests returns number of test cases of the suite, the benchmark run itself is counted as a test case.
+func (s AssertionSuite) Tests() int {
+	return len(s.Assertions) + 1
+}
+
+// Failures returns number of failed test cases of the suite.
+func (s AssertionSuite) Failures() int {
+	failures := 0
+	if len(s.Error) != 0 {
+		failures++
+	}
+	for _, a := range s.Assertions {
+		if !a.Passed {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Passed returns true, if the benchmark ran and all the assertions passed.
+func (s AssertionSuite) Passed() bool {
+	return s.Failures() == 0
+}
+
+// EvaluateAssertions evaluates fail conditions and SLOs configured in the benchmark against the merged benchmark results.
+func EvaluateAssertions(b *dnsbench.Benchmark, stats BenchmarkResultStats, benchStart time.Time, benchDuration time.Duration) (AssertionSuite, error) {
+	suite := AssertionSuite{Name: b.Server, Start: benchStart, Duration: benchDuration}
+
+	for _, f := range b.FailConditions {
+		if _, ok := counterMetrics[f]; !ok {
+			return suite, fmt.Errorf("unsupported fail condition '%s'", f)
+		}
+		// fail condition is violated, when the corresponding counter is not zero
+		t, err := ParseThreshold(f + "==0")
+		if err != nil {
+			return suite, err
+		}
+		r := EvaluateThresholds(stats, benchDuration, []Threshold{t})[0]
+		suite.Assertions = append(suite.Assertions, AssertionResult{Kind: AssertionFailCondition, Name: f, Passed: r.Passed, Message: r.Explanation})
+	}
+
+	thresholds, err := ParseThresholds(b.SLOs)
+	if err != nil {
+		return suite, fmt.Errorf("invalid SLO: %w", err)
+	}
+	suite.SLO = EvaluateThresholds(stats, benchDuration, thresholds)
+	for _, r := range suite.SLO {
+		suite.Assertions = append(suite.Assertions, AssertionResult{Kind: AssertionSLO, Name: r.Threshold, Passed: r.Passed, Message: r.Explanation})
+	}
+	return suite, nil
+}
@@ -0,0 +1,55 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func TestEvaluateAssertions(t *testing.T) {
+	hist := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+	require.NoError(t, hist.RecordValue((20 * time.Millisecond).Nanoseconds()))
+	stats := BenchmarkResultStats{
+		Hist:     hist,
+		Counters: dnsbench.Counters{Total: 100, Success: 97, IOError: 3},
+	}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := dnsbench.Benchmark{
+		Server:         "8.8.8.8:53",
+		FailConditions: []string{"ioerror", "idmismatch"},
+		SLOs:           []string{"p99<50ms"},
+	}
+
+	got, err := EvaluateAssertions(&b, stats, start, time.Second)
+
+	require.NoError(t, err)
+	assert.Equal(t, "8.8.8.8:53", got.Name)
+	assert.Equal(t, start, got.Start)
+	assert.Equal(t, time.Second, got.Duration)
+	require.Len(t, got.Assertions, 3)
+	assert.Equal(t, AssertionResult{Kind: AssertionFailCondition, Name: "ioerror", Passed: false, Message: got.Assertions[0].Message}, got.Assertions[0])
+	assert.Contains(t, got.Assertions[0].Message, "ioerror 3")
+	assert.Equal(t, AssertionFailCondition, got.Assertions[1].Kind)
+	assert.True(t, got.Assertions[1].Passed)
+	assert.Equal(t, AssertionSLO, got.Assertions[2].Kind)
+	assert.Equal(t, "p99<50ms", got.Assertions[2].Name)
+	assert.True(t, got.Assertions[2].Passed)
+	assert.Equal(t, []ThresholdResult{{Threshold: "p99<50ms", Actual: "20ms", Passed: true, Explanation: "p99 20ms < 50ms"}}, got.SLO)
+	assert.Equal(t, 4, got.Tests())
+	assert.Equal(t, 1, got.Failures())
+	assert.False(t, got.Passed())
+}
+
+func TestEvaluateAssertions_invalid(t *testing.T) {
+	stats := BenchmarkResultStats{Hist: hdrhistogram.New(0, time.Second.Nanoseconds(), 3)}
+
+	_, err := EvaluateAssertions(&dnsbench.Benchmark{FailConditions: []string{"latency"}}, stats, time.Now(), time.Second)
+	require.Error(t, err)
+
+	_, err = EvaluateAssertions(&dnsbench.Benchmark{SLOs: []string{"p99"}}, stats, time.Now(), time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,490 @@
+package reporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+// DefaultSignificanceAlpha is the default significance level of the statistical tests comparing latency distributions.
+const DefaultSignificanceAlpha = 0.05
+
+type metricUnit int
+
+const (
+	millisecondsUnit metricUnit = iota
+	countUnit
+	ratioUnit
+	numberUnit
+	secondsUnit
+)
+
+type metricDirection int
+
+const (
+	neutralDirection metricDirection = iota
+	lowerIsBetter
+	higherIsBetter
+)
+
+// comparedMetric is a metric of the JSON output compared between the runs, the metrics use the same names as thresholds
+// (see ParseThreshold), so the same metric can be used in SLOs and regression thresholds.
+type comparedMetric struct {
+	name      string
+	unit      metricUnit
+	direction metricDirection
+	value     func(r jsonResult) (float64, bool)
+}
+
+// latencyMetricOf returns latency metric in milliseconds, the latency in microseconds is used for the results of schema version 2.
+func latencyMetricOf(name string, ms func(l latencyStats) int64, us func(l latencyStats) float64) comparedMetric {
+	return comparedMetric{name: name, unit: millisecondsUnit, direction: lowerIsBetter, value: func(r jsonResult) (float64, bool) {
+		if r.SchemaVersion >= 2 {
+			return us(r.LatencyStats) / 1000, true
+		}
+		return float64(ms(r.LatencyStats)), true
+	}}
+}
+
+func counterMetricOf(name string, value func(r jsonResult) int64) comparedMetric {
+	// absolute counters depend on the duration of the run, so they are neither better nor worse
+	return comparedMetric{name: name, unit: countUnit, direction: neutralDirection, value: func(r jsonResult) (float64, bool) {
+		return float64(value(r)), true
+	}}
+}
+
+func ratioMetricOf(name string, direction metricDirection, value func(r jsonResult) int64) comparedMetric {
+	return comparedMetric{name: name + "_ratio", unit: ratioUnit, direction: direction, value: func(r jsonResult) (float64, bool) {
+		if r.TotalRequests == 0 {
+			return 0, false
+		}
+		return float64(value(r)) / float64(r.TotalRequests), true
+	}}
+}
+
+func scoreMetricOf(name string, value func(s scoring.ScoreResult) float64) comparedMetric {
+	return comparedMetric{name: name, unit: numberUnit, direction: higherIsBetter, value: func(r jsonResult) (float64, bool) {
+		if r.Score == nil {
+			return 0, false
+		}
+		return value(*r.Score), true
+	}}
+}
+
+var comparedMetrics = []comparedMetric{
+	latencyMetricOf("min", func(l latencyStats) int64 { return l.MinMs }, func(l latencyStats) float64 { return l.MinUs }),
+	latencyMetricOf("mean", func(l latencyStats) int64 { return l.MeanMs }, func(l latencyStats) float64 { return l.MeanUs }),
+	latencyMetricOf("std", func(l latencyStats) int64 { return l.StdMs }, func(l latencyStats) float64 { return l.StdUs }),
+	latencyMetricOf("max", func(l latencyStats) int64 { return l.MaxMs }, func(l latencyStats) float64 { return l.MaxUs }),
+	latencyMetricOf("p50", func(l latencyStats) int64 { return l.P50Ms }, func(l latencyStats) float64 { return l.P50Us }),
+	latencyMetricOf("p75", func(l latencyStats) int64 { return l.P75Ms }, func(l latencyStats) float64 { return l.P75Us }),
+	latencyMetricOf("p90", func(l latencyStats) int64 { return l.P90Ms }, func(l latencyStats) float64 { return l.P90Us }),
+	latencyMetricOf("p95", func(l latencyStats) int64 { return l.P95Ms }, func(l latencyStats) float64 { return l.P95Us }),
+	latencyMetricOf("p99", func(l latencyStats) int64 { return l.P99Ms }, func(l latencyStats) float64 { return l.P99Us }),
+	{name: "qps", unit: numberUnit, direction: higherIsBetter, value: func(r jsonResult) (float64, bool) { return r.QueriesPerSecond, true }},
+	{name: "duration", unit: secondsUnit, direction: neutralDirection, value: func(r jsonResult) (float64, bool) { return r.BenchmarkDurationSeconds, true }},
+	counterMetricOf("total", func(r jsonResult) int64 { return r.TotalRequests }),
+	counterMetricOf("success", func(r jsonResult) int64 { return r.TotalSuccessResponses }),
+	counterMetricOf("negative", func(r jsonResult) int64 { return r.TotalNegativeResponses }),
+	counterMetricOf("error", func(r jsonResult) int64 { return r.TotalErrorResponses }),
+	counterMetricOf("ioerror", func(r jsonResult) int64 { return r.TotalIOErrors }),
+	counterMetricOf("idmismatch", func(r jsonResult) int64 { return r.TotalIDmismatch }),
+	counterMetricOf("truncated", func(r jsonResult) int64 { return r.TotalTruncatedResponses }),
+	ratioMetricOf("success", higherIsBetter, func(r jsonResult) int64 { return r.TotalSuccessResponses }),
+	ratioMetricOf("negative", neutralDirection, func(r jsonResult) int64 { return r.TotalNegativeResponses }),
+	ratioMetricOf("error", lowerIsBetter, func(r jsonResult) int64 { return r.TotalErrorResponses }),
+	ratioMetricOf("ioerror", lowerIsBetter, func(r jsonResult) int64 { return r.TotalIOErrors }),
+	ratioMetricOf("idmismatch", lowerIsBetter, func(r jsonResult) int64 { return r.TotalIDmismatch }),
+	ratioMetricOf("truncated", lowerIsBetter, func(r jsonResult) int64 { return r.TotalTruncatedResponses }),
+	scoreMetricOf("score", func(s scoring.ScoreResult) float64 { return s.Total }),
+	scoreMetricOf("score_success", func(s scoring.ScoreResult) float64 { return s.SuccessRate }),
+	scoreMetricOf("score_error", func(s scoring.ScoreResult) float64 { return s.ErrorRate }),
+	scoreMetricOf("score_latency", func(s scoring.ScoreResult) float64 { return s.Latency }),
+	scoreMetricOf("score_qps", func(s scoring.ScoreResult) float64 { return s.QPS }),
+}
+
+func findComparedMetric(name string) (comparedMetric, bool) {
+	for _, m := range comparedMetrics {
+		if m.name == name {
+			return m, true
+		}
+	}
+	return comparedMetric{}, false
+}
+
+// MetricDelta represents change of a metric between the baseline and the current run.
+type MetricDelta struct {
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Delta    float64 `json:"delta"`
+	// DeltaPercent is the delta relative to the baseline, it is not set, when the baseline is zero.
+	DeltaPercent *float64 `json:"deltaPercent,omitempty"`
+	// Change is one of "better", "worse" and "unchanged", metrics which are neither better nor worse (e.g. total) are "changed",
+	// when they differ.
+	Change string `json:"change"`
+
+	unit      metricUnit
+	direction metricDirection
+}
+
+// ServerComparison represents comparison of the results of a single server.
+type ServerComparison struct {
+	Server string `json:"server"`
+	// CurrentServer is set, when the aligned server of the current results has different name.
+	CurrentServer string        `json:"currentServer,omitempty"`
+	Metrics       []MetricDelta `json:"metrics"`
+	// Significance is set, when latency distributions are available in both results (benchmarks were not run with --no-distribution).
+	Significance *SignificanceTest `json:"significance,omitempty"`
+	Regressions  []ThresholdResult `json:"regressions,omitempty"`
+	Passed       bool              `json:"passed"`
+}
+
+// Comparison represents comparison of the baseline and current JSON results.
+type Comparison struct {
+	Servers []ServerComparison `json:"servers"`
+	// OnlyInBaseline are the servers missing in the current results, the comparison fails, when there are any.
+	OnlyInBaseline []string `json:"onlyInBaseline,omitempty"`
+	OnlyInCurrent  []string `json:"onlyInCurrent,omitempty"`
+	Passed         bool     `json:"passed"`
+}
+
+// CompareOptions controls, when the comparison fails.
+type CompareOptions struct {
+	// MaxRegressions are the maximal regressions of the metrics allowed for each server.
+	MaxRegressions []RegressionThreshold
+	// Alpha is the significance level of the statistical tests, DefaultSignificanceAlpha is used, when not set.
+	Alpha float64
+	// FailOnSignificant fails the comparison, when the current latencies are significantly higher than the baseline latencies.
+	FailOnSignificant bool
+}
+
+// RegressionThreshold represents maximal allowed regression of a metric in format <metric>=<limit>, e.g. p99=10%, p99=5ms
+// or score=2. The limit with % suffix is relative to the baseline value, otherwise it is absolute in units of the metric,
+// durations for latencies, fractions for ratios and numbers for the rest. The regression is the change in the worse direction,
+// e.g. increase of latency or decrease of qps, changes of metrics without direction (e.g. total) are regressions either way.
+type RegressionThreshold struct {
+	// Expression is the original threshold expression.
+	Expression string
+	Metric     string
+	Limit      float64
+	// Relative is true, when the limit is in percents of the baseline value.
+	Relative bool
+
+	limit string
+}
+
+// ParseRegressionThreshold parses regression threshold in format <metric>=<limit>.
+func ParseRegressionThreshold(expr string) (RegressionThreshold, error) {
+	metric, limit, ok := strings.Cut(strings.ToLower(strings.TrimSpace(expr)), "=")
+	metric, limit = strings.TrimSpace(metric), strings.TrimSpace(limit)
+	if !ok || len(metric) == 0 || len(limit) == 0 {
+		return RegressionThreshold{}, fmt.Errorf("regression threshold '%s' is not in format <metric>=<limit>, e.g. p99=10%%", expr)
+	}
+	m, ok := findComparedMetric(metric)
+	if !ok {
+		return RegressionThreshold{}, fmt.Errorf("regression threshold '%s' uses unknown metric '%s'", expr, metric)
+	}
+	t := RegressionThreshold{Expression: strings.TrimSpace(expr), Metric: metric, limit: limit}
+
+	var err error
+	switch {
+	case strings.HasSuffix(limit, "%"):
+		t.Relative = true
+		t.Limit, err = strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+	case m.unit == millisecondsUnit:
+		var d time.Duration
+		d, err = time.ParseDuration(limit)
+		t.Limit = float64(d) / float64(time.Millisecond)
+	default:
+		t.Limit, err = strconv.ParseFloat(limit, 64)
+	}
+	if err != nil || t.Limit < 0 || math.IsNaN(t.Limit) {
+		return RegressionThreshold{}, fmt.Errorf("regression threshold '%s' has invalid limit '%s'", expr, limit)
+	}
+	return t, nil
+}
+
+// ParseRegressionThresholds parses list of regression thresholds.
+func ParseRegressionThresholds(exprs []string) ([]RegressionThreshold, error) {
+	res := make([]RegressionThreshold, 0, len(exprs))
+	for _, e := range exprs {
+		t, err := ParseRegressionThreshold(e)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// parseResults parses JSON output of the benchmark (single server or batch), which is map of servers to their results.
+func parseResults(data []byte) (map[string]jsonResult, error) {
+	var res map[string]jsonResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON results: %w", err)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("JSON results do not contain any server")
+	}
+	return res, nil
+}
+
+// Compare compares JSON outputs of two benchmark runs. The servers are aligned by their names, if both outputs contain single
+// server, they are compared even if the names differ.
+func Compare(baseline, current []byte, opts CompareOptions) (Comparison, error) {
+	baseRes, err := parseResults(baseline)
+	if err != nil {
+		return Comparison{}, fmt.Errorf("baseline: %w", err)
+	}
+	curRes, err := parseResults(current)
+	if err != nil {
+		return Comparison{}, fmt.Errorf("current: %w", err)
+	}
+	if opts.Alpha <= 0 || opts.Alpha >= 1 {
+		opts.Alpha = DefaultSignificanceAlpha
+	}
+
+	res := Comparison{Passed: true}
+	if len(baseRes) == 1 && len(curRes) == 1 {
+		for baseServer, b := range baseRes {
+			for curServer, c := range curRes {
+				sc := compareServer(baseServer, b, c, opts)
+				if curServer != baseServer {
+					sc.CurrentServer = curServer
+				}
+				res.Servers = append(res.Servers, sc)
+			}
+		}
+	} else {
+		for server, b := range baseRes {
+			c, ok := curRes[server]
+			if !ok {
+				res.OnlyInBaseline = append(res.OnlyInBaseline, server)
+				continue
+			}
+			res.Servers = append(res.Servers, compareServer(server, b, c, opts))
+		}
+		for server := range curRes {
+			if _, ok := baseRes[server]; !ok {
+				res.OnlyInCurrent = append(res.OnlyInCurrent, server)
+			}
+		}
+	}
+	sort.Slice(res.Servers, func(i, j int) bool { return res.Servers[i].Server < res.Servers[j].Server })
+	sort.Strings(res.OnlyInBaseline)
+	sort.Strings(res.OnlyInCurrent)
+
+	res.Passed = len(res.OnlyInBaseline) == 0
+	for _, s := range res.Servers {
+		res.Passed = res.Passed && s.Passed
+	}
+	return res, nil
+}
+
+func compareServer(server string, baseline, current jsonResult, opts CompareOptions) ServerComparison {
+	res := ServerComparison{Server: server, Passed: true}
+	// results of different schema versions are compared with the precision of the older version
+	if baseline.SchemaVersion != current.SchemaVersion {
+		baseline.SchemaVersion, current.SchemaVersion = 0, 0
+	}
+	for _, m := range comparedMetrics {
+		b, okBase := m.value(baseline)
+		c, okCur := m.value(current)
+		if !okBase || !okCur {
+			continue
+		}
+		res.Metrics = append(res.Metrics, newMetricDelta(m, b, c))
+	}
+
+	switch {
+	case len(baseline.LatencyDistributionUs) > 0 && len(current.LatencyDistributionUs) > 0:
+		res.Significance = testSignificance(latencyBinsUs(baseline.LatencyDistributionUs), latencyBinsUs(current.LatencyDistributionUs), opts.Alpha)
+	case len(baseline.LatencyDistribution) > 0 && len(current.LatencyDistribution) > 0:
+		res.Significance = testSignificance(latencyBins(baseline.LatencyDistribution), latencyBins(current.LatencyDistribution), opts.Alpha)
+	}
+	if opts.FailOnSignificant && res.Significance != nil && res.Significance.Slower {
+		res.Passed = false
+	}
+
+	for _, t := range opts.MaxRegressions {
+		r := t.evaluate(res.Metrics)
+		res.Passed = res.Passed && r.Passed
+		res.Regressions = append(res.Regressions, r)
+	}
+	return res
+}
+
+func newMetricDelta(m comparedMetric, baseline, current float64) MetricDelta {
+	d := MetricDelta{Metric: m.name, Baseline: baseline, Current: current, Delta: current - baseline, unit: m.unit, direction: m.direction}
+	if baseline != 0 {
+		p := d.Delta / math.Abs(baseline) * 100
+		d.DeltaPercent = &p
+	}
+	switch {
+	case d.Delta == 0:
+		d.Change = "unchanged"
+	case m.direction == neutralDirection:
+		d.Change = "changed"
+	case (d.Delta < 0) == (m.direction == lowerIsBetter):
+		d.Change = "better"
+	default:
+		d.Change = "worse"
+	}
+	return d
+}
+
+// regression returns the change of the metric in the worse direction, negative value means improvement.
+func (d MetricDelta) regression(relative bool) float64 {
+	worse := d.Delta
+	switch d.direction {
+	case higherIsBetter:
+		worse = -d.Delta
+	case neutralDirection:
+		worse = math.Abs(d.Delta)
+	}
+	if !relative {
+		return worse
+	}
+	if d.Baseline == 0 {
+		if worse > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return worse / math.Abs(d.Baseline) * 100
+}
+
+func (t RegressionThreshold) evaluate(metrics []MetricDelta) ThresholdResult {
+	res := ThresholdResult{Threshold: t.Expression}
+	var d *MetricDelta
+	for i := range metrics {
+		if metrics[i].Metric == t.Metric {
+			d = &metrics[i]
+		}
+	}
+	if d == nil {
+		res.Actual = "n/a"
+		res.Explanation = fmt.Sprintf("%s is not available in both results", t.Metric)
+		return res
+	}
+
+	regression := d.regression(t.Relative)
+	res.Passed = regression <= t.Limit
+	if t.Relative {
+		res.Actual = strconv.FormatFloat(regression, 'f', 2, 64) + "%"
+	} else {
+		res.Actual = formatMetricValue(d.unit, regression)
+	}
+	values := fmt.Sprintf("%s -> %s", formatMetricValue(d.unit, d.Baseline), formatMetricValue(d.unit, d.Current))
+	switch {
+	case regression <= 0:
+		res.Explanation = fmt.Sprintf("%s did not regress (%s), limit %s", t.Metric, values, t.limit)
+	case res.Passed:
+		res.Explanation = fmt.Sprintf("%s regressed by %s (%s), limit %s", t.Metric, res.Actual, values, t.limit)
+	default:
+		res.Explanation = fmt.Sprintf("%s regressed by %s (%s), exceeds limit %s", t.Metric, res.Actual, values, t.limit)
+	}
+	return res
+}
+
+func formatMetricValue(unit metricUnit, v float64) string {
+	switch unit {
+	case millisecondsUnit:
+		return roundDuration(time.Duration(v * float64(time.Millisecond))).String()
+	case countUnit:
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	case ratioUnit:
+		return strconv.FormatFloat(v*100, 'f', 3, 64) + "%"
+	case secondsUnit:
+		return strconv.FormatFloat(v, 'f', 2, 64) + "s"
+	default:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+}
+
+func formatMetricDelta(d MetricDelta) string {
+	sign := "+"
+	if d.Delta < 0 {
+		sign = "-"
+	}
+	delta := sign + formatMetricValue(d.unit, math.Abs(d.Delta))
+	if d.DeltaPercent != nil {
+		delta += " (" + strconv.FormatFloat(*d.DeltaPercent, 'f', 2, 64) + "%)"
+	}
+	return delta
+}
+
+// PrintComparison prints human-readable comparison of the benchmark runs.
+func PrintComparison(w io.Writer, c Comparison) {
+	for _, s := range c.Servers {
+		name := printutils.HighlightSprint(s.Server)
+		if len(s.CurrentServer) != 0 {
+			name += " -> " + printutils.HighlightSprint(s.CurrentServer)
+		}
+		printutils.NeutralFprintf(w, "\nComparison of %s:\n", name)
+
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Metric", "Baseline", "Current", "Delta", "Change"})
+		table.SetBorder(false)
+		table.SetAutoWrapText(false)
+		for _, d := range s.Metrics {
+			table.Append([]string{d.Metric, formatMetricValue(d.unit, d.Baseline), formatMetricValue(d.unit, d.Current), formatMetricDelta(d), d.Change})
+		}
+		table.Render()
+
+		if sig := s.Significance; sig != nil {
+			printutils.NeutralFprintf(w, "\nLatency distributions (%d baseline and %d current samples):\n", sig.BaselineSamples, sig.CurrentSamples)
+			printutils.NeutralFprintf(w, "\tMann-Whitney U:\tz=%.3f, p=%.4g, P(current slower)=%.3f\n", sig.MannWhitneyZ, sig.MannWhitneyP, sig.ProbabilitySlower)
+			printutils.NeutralFprintf(w, "\tKolmogorov-Smirnov:\tD=%.3f, p=%.4g\n", sig.KolmogorovSmirnovD, sig.KolmogorovSmirnovP)
+			switch {
+			case sig.Slower:
+				printutils.ErrFprintf(w, "\tcurrent latencies are significantly higher (alpha %v)\n", sig.Alpha)
+			case sig.Significant:
+				printutils.SuccessFprintf(w, "\tcurrent latencies are significantly lower (alpha %v)\n", sig.Alpha)
+			default:
+				printutils.NeutralFprintf(w, "\tno significant difference (alpha %v)\n", sig.Alpha)
+			}
+		}
+
+		if len(s.Regressions) > 0 {
+			printutils.NeutralFprintf(w, "\nRegression thresholds:\n")
+			for _, r := range s.Regressions {
+				if r.Passed {
+					printutils.SuccessFprintf(w, "\tPASS\t%s\n", r.Explanation)
+				} else {
+					printutils.ErrFprintf(w, "\tFAIL\t%s\n", r.Explanation)
+				}
+			}
+		}
+	}
+
+	if len(c.OnlyInBaseline) > 0 {
+		printutils.ErrFprintf(w, "\nServers missing in current results: %s\n", strings.Join(c.OnlyInBaseline, ", "))
+	}
+	if len(c.OnlyInCurrent) > 0 {
+		printutils.NeutralFprintf(w, "\nServers missing in baseline results: %s\n", strings.Join(c.OnlyInCurrent, ", "))
+	}
+
+	if c.Passed {
+		printutils.SuccessFprintf(w, "\nComparison passed\n")
+		return
+	}
+	failed := len(c.OnlyInBaseline)
+	for _, s := range c.Servers {
+		if !s.Passed {
+			failed++
+		}
+	}
+	printutils.ErrFprintf(w, "\nComparison failed, %d of %d servers regressed or are missing\n", failed, len(c.Servers)+len(c.OnlyInBaseline))
+}
@@ -0,0 +1,197 @@
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+func TestParseRegressionThreshold(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    RegressionThreshold
+		wantErr bool
+	}{
+		{expr: "p99=10%", want: RegressionThreshold{Expression: "p99=10%", Metric: "p99", Limit: 10, Relative: true, limit: "10%"}},
+		{expr: "p99 = 5ms", want: RegressionThreshold{Expression: "p99 = 5ms", Metric: "p99", Limit: 5, limit: "5ms"}},
+		{expr: "qps=100", want: RegressionThreshold{Expression: "qps=100", Metric: "qps", Limit: 100, limit: "100"}},
+		{expr: "ioerror_ratio=0.001", want: RegressionThreshold{Expression: "ioerror_ratio=0.001", Metric: "ioerror_ratio", Limit: 0.001, limit: "0.001"}},
+		{expr: "score=2", want: RegressionThreshold{Expression: "score=2", Metric: "score", Limit: 2, limit: "2"}},
+		{expr: "p99", wantErr: true},
+		{expr: "p99=", wantErr: true},
+		{expr: "p99=5", wantErr: true},
+		{expr: "latency=10%", wantErr: true},
+		{expr: "qps=-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseRegressionThreshold(tt.expr)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func marshalResults(t *testing.T, res multiServerResult) []byte {
+	t.Helper()
+	data, err := json.Marshal(res)
+	require.NoError(t, err)
+	return data
+}
+
+func TestCompare(t *testing.T) {
+	baseline := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:         1000,
+			TotalSuccessResponses: 990,
+			TotalIOErrors:         10,
+			QueriesPerSecond:      200,
+			LatencyStats:          latencyStats{P50Ms: 10, P99Ms: 20},
+			LatencyDistribution:   []histogramPoint{{LatencyMs: 10, Count: 500}, {LatencyMs: 11, Count: 490}},
+			Score:                 &scoring.ScoreResult{Total: 90},
+		},
+		"1.1.1.1:53": {TotalRequests: 1000, TotalSuccessResponses: 1000, QueriesPerSecond: 200},
+		"9.9.9.9:53": {TotalRequests: 1000, TotalSuccessResponses: 1000, QueriesPerSecond: 200},
+	})
+	current := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:         1000,
+			TotalSuccessResponses: 1000,
+			QueriesPerSecond:      180,
+			LatencyStats:          latencyStats{P50Ms: 12, P99Ms: 25},
+			LatencyDistribution:   []histogramPoint{{LatencyMs: 12, Count: 500}, {LatencyMs: 13, Count: 500}},
+			Score:                 &scoring.ScoreResult{Total: 85},
+		},
+		"1.1.1.1:53":     {TotalRequests: 1000, TotalSuccessResponses: 1000, QueriesPerSecond: 210},
+		"208.67.222.222": {TotalRequests: 1000, TotalSuccessResponses: 1000, QueriesPerSecond: 200},
+	})
+	thresholds, err := ParseRegressionThresholds([]string{"p99=10%", "qps=50", "ioerror_ratio=0.001"})
+	require.NoError(t, err)
+
+	got, err := Compare(baseline, current, CompareOptions{MaxRegressions: thresholds})
+
+	require.NoError(t, err)
+	assert.False(t, got.Passed)
+	assert.Equal(t, []string{"9.9.9.9:53"}, got.OnlyInBaseline)
+	assert.Equal(t, []string{"208.67.222.222"}, got.OnlyInCurrent)
+	require.Len(t, got.Servers, 2)
+
+	cloudflare := got.Servers[0]
+	assert.Equal(t, "1.1.1.1:53", cloudflare.Server)
+	assert.True(t, cloudflare.Passed)
+	assert.Nil(t, cloudflare.Significance)
+
+	google := got.Servers[1]
+	assert.Equal(t, "8.8.8.8:53", google.Server)
+	assert.False(t, google.Passed)
+	metrics := make(map[string]MetricDelta)
+	for _, m := range google.Metrics {
+		metrics[m.Metric] = m
+	}
+	assert.Equal(t, 5.0, metrics["p99"].Delta)
+	assert.Equal(t, 25.0, *metrics["p99"].DeltaPercent)
+	assert.Equal(t, "worse", metrics["p99"].Change)
+	assert.Equal(t, "worse", metrics["qps"].Change)
+	assert.Equal(t, "better", metrics["ioerror_ratio"].Change)
+	assert.Equal(t, "unchanged", metrics["total"].Change)
+	assert.Equal(t, -5.0, metrics["score"].Delta)
+	assert.Nil(t, metrics["negative_ratio"].DeltaPercent)
+
+	require.NotNil(t, google.Significance)
+	assert.True(t, google.Significance.Slower)
+
+	assert.Equal(t, []ThresholdResult{
+		{Threshold: "p99=10%", Actual: "25.00%", Passed: false, Explanation: "p99 regressed by 25.00% (20ms -> 25ms), exceeds limit 10%"},
+		{Threshold: "qps=50", Actual: "20.00", Passed: true, Explanation: "qps regressed by 20.00 (200.00 -> 180.00), limit 50"},
+		{Threshold: "ioerror_ratio=0.001", Actual: "-1.000%", Passed: true, Explanation: "ioerror_ratio did not regress (1.000% -> 0.000%), limit 0.001"},
+	}, google.Regressions)
+}
+
+func TestCompare_singleServer(t *testing.T) {
+	baseline := marshalResults(t, multiServerResult{"8.8.8.8:53": {TotalRequests: 10, TotalSuccessResponses: 10, LatencyStats: latencyStats{P99Ms: 20}}})
+	current := marshalResults(t, multiServerResult{"1.1.1.1:53": {TotalRequests: 10, TotalSuccessResponses: 10, LatencyStats: latencyStats{P99Ms: 10}}})
+	thresholds, err := ParseRegressionThresholds([]string{"p99=1ms", "score=1"})
+	require.NoError(t, err)
+
+	got, err := Compare(baseline, current, CompareOptions{MaxRegressions: thresholds})
+
+	require.NoError(t, err)
+	require.Len(t, got.Servers, 1)
+	assert.Equal(t, "8.8.8.8:53", got.Servers[0].Server)
+	assert.Equal(t, "1.1.1.1:53", got.Servers[0].CurrentServer)
+	require.Len(t, got.Servers[0].Regressions, 2)
+	assert.True(t, got.Servers[0].Regressions[0].Passed)
+	// score is not available in the results
+	assert.False(t, got.Servers[0].Regressions[1].Passed)
+	assert.False(t, got.Passed)
+}
+
+func TestCompare_schemaVersions(t *testing.T) {
+	v1 := marshalResults(t, multiServerResult{"8.8.8.8:53": {LatencyStats: latencyStats{P99Ms: 1}}})
+	v2 := marshalResults(t, multiServerResult{"8.8.8.8:53": {SchemaVersion: 2, LatencyStats: latencyStats{P99Ms: 1, P99Us: 1200}}})
+	faster := marshalResults(t, multiServerResult{"8.8.8.8:53": {SchemaVersion: 2, LatencyStats: latencyStats{P99Ms: 1, P99Us: 900}}})
+
+	p99 := func(baseline, current []byte) MetricDelta {
+		t.Helper()
+		got, err := Compare(baseline, current, CompareOptions{})
+		require.NoError(t, err)
+		require.Len(t, got.Servers, 1)
+		for _, m := range got.Servers[0].Metrics {
+			if m.Metric == "p99" {
+				return m
+			}
+		}
+		t.Fatal("p99 metric not compared")
+		return MetricDelta{}
+	}
+
+	// sub-millisecond differences are compared, when both results contain microsecond latencies
+	got := p99(v2, faster)
+	assert.InDelta(t, 1.2, got.Baseline, 1e-9)
+	assert.InDelta(t, 0.9, got.Current, 1e-9)
+	assert.Equal(t, "better", got.Change)
+
+	// results of different schema versions are compared with millisecond precision
+	got = p99(v1, faster)
+	assert.Equal(t, 1.0, got.Baseline)
+	assert.Equal(t, 1.0, got.Current)
+	assert.Equal(t, "unchanged", got.Change)
+}
+
+func TestCompare_failOnSignificant(t *testing.T) {
+	baseline := marshalResults(t, multiServerResult{"8.8.8.8:53": {
+		TotalRequests:       100,
+		LatencyDistribution: []histogramPoint{{LatencyMs: 10, Count: 100}},
+	}})
+	current := marshalResults(t, multiServerResult{"8.8.8.8:53": {
+		TotalRequests:       100,
+		LatencyDistribution: []histogramPoint{{LatencyMs: 10, Count: 50}, {LatencyMs: 12, Count: 50}},
+	}})
+
+	got, err := Compare(baseline, current, CompareOptions{})
+	require.NoError(t, err)
+	assert.True(t, got.Passed)
+	assert.Equal(t, DefaultSignificanceAlpha, got.Servers[0].Significance.Alpha)
+
+	got, err = Compare(baseline, current, CompareOptions{FailOnSignificant: true, Alpha: 0.01})
+	require.NoError(t, err)
+	assert.False(t, got.Passed)
+	assert.True(t, got.Servers[0].Significance.Slower)
+}
+
+func TestCompare_invalid(t *testing.T) {
+	valid := marshalResults(t, multiServerResult{"8.8.8.8:53": {TotalRequests: 10}})
+
+	_, err := Compare([]byte("{}"), valid, CompareOptions{})
+	require.Error(t, err)
+
+	_, err = Compare(valid, []byte("not json"), CompareOptions{})
+	require.Error(t, err)
+}
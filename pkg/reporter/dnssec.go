@@ -0,0 +1,133 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type dnssecCaseSummary struct {
+	Expected string `json:"expected"`
+	// Results maps result of the validation to the number of responses.
+	Results map[string]int64 `json:"results"`
+	// Correct is true, when all the responses were validated as expected.
+	Correct bool `json:"correct"`
+}
+
+type dnssecAlgorithmSummary struct {
+	Validated    int64 `json:"validated"`
+	NotValidated int64 `json:"notValidated"`
+	// Supported is true, when all the signed responses using the algorithm were authenticated by the server.
+	Supported bool `json:"supported"`
+}
+
+type dnssecSummary struct {
+	CorrectCases   int                               `json:"correctCases"`
+	IncorrectCases int                               `json:"incorrectCases"`
+	Cases          map[string]dnssecCaseSummary      `json:"cases"`
+	Algorithms     map[string]dnssecAlgorithmSummary `json:"algorithms"`
+	// ValidatedLatencyStats contains latencies of the validating queries, UncheckedLatencyStats contains latencies
+	// of the queries with checking disabled flag set.
+	ValidatedLatencyStats latencyStats `json:"validatedLatencyStats"`
+	UncheckedLatencyStats latencyStats `json:"uncheckedLatencyStats"`
+	// ValidationOverheadMs is the difference between mean latencies of validating queries and queries with checking disabled flag set.
+	ValidationOverheadMs int64 `json:"validationOverheadMs"`
+	// ValidationOverheadUs is the validation overhead in microseconds, it is available since schema version 2.
+	ValidationOverheadUs float64 `json:"validationOverheadUs"`
+}
+
+// summarizeDNSSEC aggregates results of the DNSSEC validation benchmark, nil is returned if the benchmark was not enabled.
+func summarizeDNSSEC(st *dnsbench.DNSSECValidationStats) *dnssecSummary {
+	if st == nil {
+		return nil
+	}
+	summary := &dnssecSummary{
+		Cases:                 make(map[string]dnssecCaseSummary),
+		Algorithms:            make(map[string]dnssecAlgorithmSummary),
+		ValidatedLatencyStats: newLatencyStats(st.ValidatedHist),
+		UncheckedLatencyStats: newLatencyStats(st.UncheckedHist),
+	}
+	for name, c := range st.Cases {
+		correct := len(c.Results) == 1 && c.Results[c.Expected] > 0
+		if correct {
+			summary.CorrectCases++
+		} else {
+			summary.IncorrectCases++
+		}
+		summary.Cases[name] = dnssecCaseSummary{Expected: c.Expected, Results: c.Results, Correct: correct}
+	}
+	for alg, a := range st.Algorithms {
+		summary.Algorithms[algorithmName(alg)] = dnssecAlgorithmSummary{
+			Validated:    a.Validated,
+			NotValidated: a.NotValidated,
+			Supported:    a.Validated > 0 && a.NotValidated == 0,
+		}
+	}
+	if st.ValidatedHist.TotalCount() > 0 && st.UncheckedHist.TotalCount() > 0 {
+		overhead := time.Duration(st.ValidatedHist.Mean() - st.UncheckedHist.Mean())
+		summary.ValidationOverheadMs = roundDuration(overhead).Milliseconds()
+		summary.ValidationOverheadUs = durationUs(overhead)
+	}
+	return summary
+}
+
+func algorithmName(alg uint8) string {
+	if name, ok := dns.AlgorithmToString[alg]; ok {
+		return name
+	}
+	return "ALG" + strconv.Itoa(int(alg))
+}
+
+func printDNSSEC(w io.Writer, summary *dnssecSummary, st *dnsbench.DNSSECValidationStats) {
+	printutils.NeutralFprintf(w, "\nDNSSEC validation:\n")
+	names := make([]string, 0, len(summary.Cases))
+	for k := range summary.Cases {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := summary.Cases[name]
+		if c.Correct {
+			printutils.NeutralFprintf(w, "\t%s (expected %s):\t%s\n", name, c.Expected, printutils.HighlightSprint("ok"))
+			continue
+		}
+		printutils.ErrFprintf(w, "\t%s (expected %s):\tFAILED\n", name, c.Expected)
+		results := make([]string, 0, len(c.Results))
+		for k := range c.Results {
+			results = append(results, k)
+		}
+		sort.Strings(results)
+		for _, r := range results {
+			printutils.NeutralFprintf(w, "\t\t%s:\t%d\n", r, c.Results[r])
+		}
+	}
+
+	if len(summary.Algorithms) > 0 {
+		printutils.NeutralFprintf(w, "DNSSEC algorithms:\n")
+		algs := make([]string, 0, len(summary.Algorithms))
+		for k := range summary.Algorithms {
+			algs = append(algs, k)
+		}
+		sort.Strings(algs)
+		for _, alg := range algs {
+			a := summary.Algorithms[alg]
+			if a.Supported {
+				printutils.NeutralFprintf(w, "\t%s:\t%s\n", alg, printutils.HighlightSprint("validated"))
+			} else {
+				printutils.ErrFprintf(w, "\t%s:\tnot validated in %d of %d responses\n", alg, a.NotValidated, a.Validated+a.NotValidated)
+			}
+		}
+	}
+
+	if st.ValidatedHist.TotalCount() > 0 && st.UncheckedHist.TotalCount() > 0 {
+		printutils.NeutralFprintf(w, "Mean latency with validation:\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(st.ValidatedHist.Mean()))))
+		printutils.NeutralFprintf(w, "Mean latency with checking disabled:\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(st.UncheckedHist.Mean()))))
+		printutils.NeutralFprintf(w, "Validation overhead:\t%s\n",
+			printutils.HighlightSprint(roundDuration(time.Duration(st.ValidatedHist.Mean()-st.UncheckedHist.Mean()))))
+	}
+}
@@ -0,0 +1,61 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func Test_summarizeDNSSEC(t *testing.T) {
+	validated := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+	validated.RecordValue((15 * time.Millisecond).Nanoseconds())
+	unchecked := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+	unchecked.RecordValue((10 * time.Millisecond).Nanoseconds())
+
+	st := &dnsbench.DNSSECValidationStats{
+		Cases: map[string]*dnsbench.DNSSECCaseStats{
+			"secure.test.":  {Expected: dnsbench.DNSSECSecure, Results: map[string]int64{dnsbench.DNSSECSecure: 2}},
+			"ed25519.test.": {Expected: dnsbench.DNSSECSecure, Results: map[string]int64{dnsbench.DNSSECInsecure: 2}},
+			"bogus.test.": {
+				Expected: dnsbench.DNSSECBogus,
+				Results:  map[string]int64{dnsbench.DNSSECBogus: 1, dnsbench.DNSSECInsecure: 1},
+			},
+		},
+		Algorithms: map[uint8]*dnsbench.DNSSECAlgorithmStats{
+			dns.ECDSAP256SHA256: {Validated: 2},
+			dns.ED25519:         {NotValidated: 2},
+			200:                 {Validated: 1, NotValidated: 1},
+		},
+		ValidatedHist: validated,
+		UncheckedHist: unchecked,
+	}
+
+	want := &dnssecSummary{
+		CorrectCases:   1,
+		IncorrectCases: 2,
+		Cases: map[string]dnssecCaseSummary{
+			"secure.test.":  {Expected: dnsbench.DNSSECSecure, Results: map[string]int64{dnsbench.DNSSECSecure: 2}, Correct: true},
+			"ed25519.test.": {Expected: dnsbench.DNSSECSecure, Results: map[string]int64{dnsbench.DNSSECInsecure: 2}},
+			"bogus.test.": {
+				Expected: dnsbench.DNSSECBogus,
+				Results:  map[string]int64{dnsbench.DNSSECBogus: 1, dnsbench.DNSSECInsecure: 1},
+			},
+		},
+		Algorithms: map[string]dnssecAlgorithmSummary{
+			"ECDSAP256SHA256": {Validated: 2, Supported: true},
+			"ED25519":         {NotValidated: 2},
+			"ALG200":          {Validated: 1, NotValidated: 1},
+		},
+		ValidatedLatencyStats: newLatencyStats(validated),
+		UncheckedLatencyStats: newLatencyStats(unchecked),
+		ValidationOverheadMs:  5,
+		ValidationOverheadUs:  5010,
+	}
+
+	assert.Equal(t, want, summarizeDNSSEC(st))
+	assert.Nil(t, summarizeDNSSEC(nil))
+}
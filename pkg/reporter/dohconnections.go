@@ -0,0 +1,37 @@
+package reporter
+
+import (
+	"io"
+	"math"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type dohConnectionsSummary struct {
+	Opened             int64   `json:"opened"`
+	MinStreamsPerConn  int64   `json:"minStreamsPerConnection"`
+	MeanStreamsPerConn float64 `json:"meanStreamsPerConnection"`
+	MaxStreamsPerConn  int64   `json:"maxStreamsPerConnection"`
+}
+
+// summarizeDoHConnections summarizes number of streams carried by the DoH connections, nil is returned if no DoH connection was opened.
+func summarizeDoHConnections(conns *dnsbench.DoHConnectionStats) *dohConnectionsSummary {
+	if conns == nil || conns.Opened == 0 {
+		return nil
+	}
+	return &dohConnectionsSummary{
+		Opened:             conns.Opened,
+		MinStreamsPerConn:  conns.MinStreams,
+		MeanStreamsPerConn: math.Round(float64(conns.Streams)/float64(conns.Opened)*100) / 100,
+		MaxStreamsPerConn:  conns.MaxStreams,
+	}
+}
+
+func printDoHConnections(w io.Writer, summary *dohConnectionsSummary) {
+	printutils.NeutralFprintf(w, "\nDoH connections opened:\t%s\n", printutils.HighlightSprint(summary.Opened))
+	printutils.NeutralFprintf(w, "DoH streams per connection:\n")
+	printutils.NeutralFprintf(w, "\t min:\t\t%s\n", printutils.HighlightSprint(summary.MinStreamsPerConn))
+	printutils.NeutralFprintf(w, "\t mean:\t\t%s\n", printutils.HighlightSprintf("%0.2f", summary.MeanStreamsPerConn))
+	printutils.NeutralFprintf(w, "\t max:\t\t%s\n", printutils.HighlightSprint(summary.MaxStreamsPerConn))
+}
@@ -0,0 +1,35 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func Test_summarizeDoHConnections(t *testing.T) {
+	tests := []struct {
+		name  string
+		conns *dnsbench.DoHConnectionStats
+		want  *dohConnectionsSummary
+	}{
+		{
+			name: "no connections",
+		},
+		{
+			name:  "connections",
+			conns: &dnsbench.DoHConnectionStats{Opened: 3, Streams: 9, MinStreams: 1, MaxStreams: 5},
+			want: &dohConnectionsSummary{
+				Opened:             3,
+				MinStreamsPerConn:  1,
+				MeanStreamsPerConn: 3,
+				MaxStreamsPerConn:  5,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeDoHConnections(tt.conns))
+		})
+	}
+}
@@ -17,3 +17,8 @@ func roundDuration(dur time.Duration) time.Duration {
 	}
 	return dur
 }
+
+// durationUs converts the rounded duration to microseconds.
+func durationUs(dur time.Duration) float64 {
+	return float64(roundDuration(dur)) / float64(time.Microsecond)
+}
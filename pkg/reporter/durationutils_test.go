@@ -48,3 +48,9 @@ func Test_roundDuration(t *testing.T) {
 		})
 	}
 }
+
+func Test_durationUs(t *testing.T) {
+	assert.InDelta(t, 2120.0, durationUs(2*time.Millisecond+123*time.Microsecond), 1e-9)
+	assert.InDelta(t, 345.68, durationUs(345*time.Microsecond+678*time.Nanosecond), 1e-9)
+	assert.Zero(t, durationUs(0))
+}
@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type ecsSummary struct {
+	Subnets map[string]ecsSubnetSummary `json:"subnets"`
+	// Questions is number of questions successfully answered for at least two client subnets.
+	Questions int `json:"questions"`
+	// QuestionsWithDifferentAnswers is number of questions, which were answered differently for different client subnets.
+	QuestionsWithDifferentAnswers int `json:"questionsWithDifferentAnswers"`
+}
+
+type ecsSubnetSummary struct {
+	Queries       int64         `json:"queries"`
+	NoECS         int64         `json:"responsesWithoutECS"`
+	ScopePrefixes map[int]int64 `json:"scopePrefixes"`
+}
+
+// summarizeECS aggregates results of the queries sent with EDNS Client Subnet, nil is returned if ECS was not used.
+func summarizeECS(stats map[string]*dnsbench.ECSStats, answers map[string]map[string]string) *ecsSummary {
+	if len(stats) == 0 {
+		return nil
+	}
+	summary := ecsSummary{Subnets: make(map[string]ecsSubnetSummary)}
+	for k, v := range stats {
+		summary.Subnets[k] = ecsSubnetSummary{Queries: v.Queries, NoECS: v.NoECS, ScopePrefixes: v.ScopePrefixes}
+	}
+	for _, bySubnet := range answers {
+		if len(bySubnet) < 2 {
+			continue
+		}
+		summary.Questions++
+		distinct := make(map[string]struct{})
+		for _, fingerprint := range bySubnet {
+			distinct[fingerprint] = struct{}{}
+		}
+		if len(distinct) > 1 {
+			summary.QuestionsWithDifferentAnswers++
+		}
+	}
+	return &summary
+}
+
+func printECSSummary(w io.Writer, summary *ecsSummary) {
+	subnets := make([]string, 0, len(summary.Subnets))
+	for k := range summary.Subnets {
+		subnets = append(subnets, k)
+	}
+	sort.Strings(subnets)
+
+	printutils.NeutralFprintf(w, "\nEDNS Client Subnet:\n")
+	for _, subnet := range subnets {
+		s := summary.Subnets[subnet]
+		printutils.NeutralFprintf(w, "\t%s:\t%s responses\n", subnet, printutils.HighlightSprint(s.Queries))
+		if s.NoECS > 0 {
+			printutils.NeutralFprintf(w, "\t\twithout ECS:\t%d\n", s.NoECS)
+		}
+		prefixes := make([]int, 0, len(s.ScopePrefixes))
+		for k := range s.ScopePrefixes {
+			prefixes = append(prefixes, k)
+		}
+		sort.Ints(prefixes)
+		for _, p := range prefixes {
+			printutils.NeutralFprintf(w, "\t\tscope /%d:\t%d\n", p, s.ScopePrefixes[p])
+		}
+	}
+	printutils.NeutralFprintf(w, "Questions answered differently per subnet:\t%s of %s\n",
+		printutils.HighlightSprint(summary.QuestionsWithDifferentAnswers), printutils.HighlightSprint(summary.Questions))
+}
@@ -0,0 +1,46 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func Test_summarizeECS(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   map[string]*dnsbench.ECSStats
+		answers map[string]map[string]string
+		want    *ecsSummary
+	}{
+		{
+			name: "ECS not used",
+		},
+		{
+			name: "answers differ per subnet",
+			stats: map[string]*dnsbench.ECSStats{
+				"10.0.0.0/24":    {Queries: 2, ScopePrefixes: map[int]int64{24: 2}},
+				"192.168.0.0/16": {Queries: 2, NoECS: 1, ScopePrefixes: map[int]int64{0: 1}},
+			},
+			answers: map[string]map[string]string{
+				"example.org. A":    {"10.0.0.0/24": "a", "192.168.0.0/16": "b"},
+				"example.com. A":    {"10.0.0.0/24": "a", "192.168.0.0/16": "a"},
+				"example.net. AAAA": {"10.0.0.0/24": "a"},
+			},
+			want: &ecsSummary{
+				Subnets: map[string]ecsSubnetSummary{
+					"10.0.0.0/24":    {Queries: 2, ScopePrefixes: map[int]int64{24: 2}},
+					"192.168.0.0/16": {Queries: 2, NoECS: 1, ScopePrefixes: map[int]int64{0: 1}},
+				},
+				Questions:                     2,
+				QuestionsWithDifferentAnswers: 1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeECS(tt.stats, tt.answers))
+		})
+	}
+}
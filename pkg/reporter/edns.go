@@ -0,0 +1,77 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type extendedErrorSummary struct {
+	InfoCode uint16 `json:"infoCode"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+	// ExtraTexts contains number of errors by the extra text, errors without extra text are not included.
+	ExtraTexts map[string]int64 `json:"extraTexts,omitempty"`
+}
+
+// summarizeExtendedErrors aggregates Extended DNS Errors by the info code, the summaries are sorted from the most frequent.
+// nil is returned if there were no Extended DNS Errors.
+func summarizeExtendedErrors(errs map[dnsbench.ExtendedError]int64) []extendedErrorSummary {
+	if len(errs) == 0 {
+		return nil
+	}
+	byCode := make(map[uint16]*extendedErrorSummary)
+	for k, v := range errs {
+		s, ok := byCode[k.InfoCode]
+		if !ok {
+			s = &extendedErrorSummary{InfoCode: k.InfoCode, Name: extendedErrorName(k.InfoCode)}
+			byCode[k.InfoCode] = s
+		}
+		s.Count += v
+		if len(k.ExtraText) != 0 {
+			if s.ExtraTexts == nil {
+				s.ExtraTexts = make(map[string]int64)
+			}
+			s.ExtraTexts[k.ExtraText] += v
+		}
+	}
+
+	summaries := make([]extendedErrorSummary, 0, len(byCode))
+	for _, s := range byCode {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].InfoCode < summaries[j].InfoCode
+	})
+	return summaries
+}
+
+func extendedErrorName(code uint16) string {
+	if name, ok := dns.ExtendedErrorCodeToString[code]; ok {
+		return name
+	}
+	return "EDE" + strconv.Itoa(int(code))
+}
+
+func printExtendedErrors(w io.Writer, summaries []extendedErrorSummary) {
+	printutils.NeutralFprintf(w, "\nExtended DNS errors:\n")
+	for _, s := range summaries {
+		printutils.ErrFprintf(w, "\t%s (%d):\t%d\n", s.Name, s.InfoCode, s.Count)
+
+		texts := make([]string, 0, len(s.ExtraTexts))
+		for k := range s.ExtraTexts {
+			texts = append(texts, k)
+		}
+		sort.Strings(texts)
+		for _, t := range texts {
+			printutils.NeutralFprintf(w, "\t\t%q:\t%d\n", t, s.ExtraTexts[t])
+		}
+	}
+}
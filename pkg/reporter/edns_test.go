@@ -0,0 +1,47 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func Test_summarizeExtendedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		errs map[dnsbench.ExtendedError]int64
+		want []extendedErrorSummary
+	}{
+		{
+			name: "no extended errors",
+		},
+		{
+			name: "extended errors grouped by info code",
+			errs: map[dnsbench.ExtendedError]int64{
+				{InfoCode: dns.ExtendedErrorCodeDNSBogus}:                                   1,
+				{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream timeout"}: 2,
+				{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream refused"}: 1,
+				{InfoCode: dns.ExtendedErrorCodeBlocked}:                                    1,
+				{InfoCode: 1000}:                                                            4,
+			},
+			want: []extendedErrorSummary{
+				{InfoCode: 1000, Name: "EDE1000", Count: 4},
+				{
+					InfoCode:   dns.ExtendedErrorCodeStaleAnswer,
+					Name:       "Stale Answer",
+					Count:      3,
+					ExtraTexts: map[string]int64{"upstream timeout": 2, "upstream refused": 1},
+				},
+				{InfoCode: dns.ExtendedErrorCodeDNSBogus, Name: "DNSSEC Bogus", Count: 1},
+				{InfoCode: dns.ExtendedErrorCodeBlocked, Name: "Blocked", Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeExtendedErrors(tt.errs))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package reporter
+
+import (
+	"io"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type faultsSummary struct {
+	QueriesDropped   int64 `json:"queriesDropped"`
+	ResponsesDropped int64 `json:"responsesDropped"`
+	Duplicated       int64 `json:"duplicated"`
+	Reordered        int64 `json:"reordered"`
+	Delayed          int64 `json:"delayed"`
+	MeanDelayMs      int64 `json:"meanDelayMs"`
+	// MeanDelayUs is the mean delay in microseconds, it is available since schema version 2.
+	MeanDelayUs float64 `json:"meanDelayUs"`
+	// IOErrors and IDMismatches are the observed errors, which can be caused by the injected faults. Dropped datagrams
+	// cause I/O errors (timeouts), duplicated and reordered datagrams cause ID mismatches on the reused connections.
+	IOErrors     int64 `json:"ioErrors"`
+	IDMismatches int64 `json:"idMismatches"`
+}
+
+// summarizeFaults correlates the injected faults with the observed errors, nil is returned if no faults were injected.
+func summarizeFaults(c dnsbench.Counters, faults *faultproxy.Stats) *faultsSummary {
+	if faults == nil {
+		return nil
+	}
+	summary := &faultsSummary{
+		QueriesDropped:   faults.QueriesDropped,
+		ResponsesDropped: faults.ResponsesDropped,
+		Duplicated:       faults.Duplicated,
+		Reordered:        faults.Reordered,
+		Delayed:          faults.Delayed,
+		IOErrors:         c.IOError,
+		IDMismatches:     c.IDmismatch,
+	}
+	if faults.Delayed > 0 {
+		meanDelay := faults.DelayTotal / time.Duration(faults.Delayed)
+		summary.MeanDelayMs = roundDuration(meanDelay).Milliseconds()
+		summary.MeanDelayUs = durationUs(meanDelay)
+	}
+	return summary
+}
+
+func printFaults(w io.Writer, summary *faultsSummary, faults *faultproxy.Stats) {
+	printutils.NeutralFprintf(w, "\nInjected network faults:\n")
+	printutils.NeutralFprintf(w, "\tDropped queries:\t%s\n", printutils.HighlightSprint(summary.QueriesDropped))
+	printutils.NeutralFprintf(w, "\tDropped responses:\t%s\n", printutils.HighlightSprint(summary.ResponsesDropped))
+	printutils.NeutralFprintf(w, "\tDuplicated datagrams:\t%s\n", printutils.HighlightSprint(summary.Duplicated))
+	printutils.NeutralFprintf(w, "\tReordered datagrams:\t%s\n", printutils.HighlightSprint(summary.Reordered))
+	if faults.Delayed > 0 {
+		printutils.NeutralFprintf(w, "\tDelayed:\t\t%s (mean delay %s)\n", printutils.HighlightSprint(summary.Delayed),
+			printutils.HighlightSprint(roundDuration(faults.DelayTotal/time.Duration(faults.Delayed))))
+	}
+	printutils.NeutralFprintf(w, "I/O errors:\t\t%s (dropped datagrams: %s)\n", printutils.HighlightSprint(summary.IOErrors),
+		printutils.HighlightSprint(summary.QueriesDropped+summary.ResponsesDropped))
+	printutils.NeutralFprintf(w, "ID mismatches:\t\t%s (duplicated and reordered datagrams: %s)\n", printutils.HighlightSprint(summary.IDMismatches),
+		printutils.HighlightSprint(summary.Duplicated+summary.Reordered))
+}
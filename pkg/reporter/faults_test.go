@@ -0,0 +1,51 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
+)
+
+func Test_summarizeFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters dnsbench.Counters
+		faults   *faultproxy.Stats
+		want     *faultsSummary
+	}{
+		{
+			name: "faults not injected",
+		},
+		{
+			name:     "faults injected",
+			counters: dnsbench.Counters{Total: 10, IOError: 3, IDmismatch: 1},
+			faults: &faultproxy.Stats{
+				QueriesDropped: 2, ResponsesDropped: 1, Duplicated: 1, Reordered: 2, Delayed: 4, DelayTotal: 12 * time.Millisecond,
+			},
+			want: &faultsSummary{
+				QueriesDropped: 2, ResponsesDropped: 1, Duplicated: 1, Reordered: 2, Delayed: 4, MeanDelayMs: 3, MeanDelayUs: 3000,
+				IOErrors: 3, IDMismatches: 1,
+			},
+		},
+		{
+			name:     "sub-millisecond delay",
+			counters: dnsbench.Counters{Total: 10},
+			faults:   &faultproxy.Stats{Delayed: 2, DelayTotal: 500 * time.Microsecond},
+			want:     &faultsSummary{Delayed: 2, MeanDelayMs: 0, MeanDelayUs: 250},
+		},
+		{
+			name:     "no delay",
+			counters: dnsbench.Counters{Total: 10, IOError: 1},
+			faults:   &faultproxy.Stats{QueriesDropped: 1},
+			want:     &faultsSummary{QueriesDropped: 1, IOErrors: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeFaults(tt.counters, tt.faults))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+// unidentifiedInstance is used in the reports for responses, whose server instance could not be identified.
+const unidentifiedInstance = "<unidentified>"
+
+type instanceSummary struct {
+	Responses    int64        `json:"responses"`
+	LatencyStats latencyStats `json:"latencyStats"`
+}
+
+type instancesSummary struct {
+	Instances map[string]instanceSummary `json:"instances"`
+	// Changes is number of responses returned by different instance than the previous response of the same worker,
+	// non-zero value means the instances were flapping during the benchmark.
+	Changes int64 `json:"changes"`
+}
+
+// summarizeInstances aggregates responses by the server instance, nil is returned if the instances were not identified.
+func summarizeInstances(c dnsbench.Counters, instances map[string]*dnsbench.InstanceStats) *instancesSummary {
+	if instances == nil {
+		return nil
+	}
+	summary := &instancesSummary{Instances: make(map[string]instanceSummary), Changes: c.InstanceChanges}
+	for k, v := range instances {
+		if len(k) == 0 {
+			k = unidentifiedInstance
+		}
+		summary.Instances[k] = instanceSummary{Responses: v.Responses, LatencyStats: newLatencyStats(v.Hist)}
+	}
+	return summary
+}
+
+func printInstances(w io.Writer, summary *instancesSummary, instances map[string]*dnsbench.InstanceStats) {
+	printutils.NeutralFprintf(w, "\nAnswering server instances:\n")
+
+	keys := make([]string, 0, len(instances))
+	for k := range instances {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := k
+		if len(name) == 0 {
+			name = unidentifiedInstance
+		}
+		printInstance(w, name, instances[k].Hist)
+	}
+
+	if summary.Changes > 0 {
+		printutils.ErrFprintf(w, "Server instance changes:\t%d\n", summary.Changes)
+	} else {
+		printutils.NeutralFprintf(w, "Server instance changes:\t%s\n", printutils.HighlightSprint(summary.Changes))
+	}
+}
+
+func printInstance(w io.Writer, name string, hist *hdrhistogram.Histogram) {
+	printutils.NeutralFprintf(w, "\t%s:\t%s responses\n", name, printutils.HighlightSprint(hist.TotalCount()))
+	printutils.NeutralFprintf(w, "\t\t min:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Min()))))
+	printutils.NeutralFprintf(w, "\t\t mean:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Mean()))))
+	printutils.NeutralFprintf(w, "\t\t max:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Max()))))
+	printutils.NeutralFprintf(w, "\t\t p99:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.ValueAtQuantile(99)))))
+	printutils.NeutralFprintf(w, "\t\t p50:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.ValueAtQuantile(50)))))
+}
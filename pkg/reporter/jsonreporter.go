@@ -5,10 +5,17 @@ import (
 	"math"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/miekg/dns"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
 	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
 )
 
+// JSONSchemaVersion is the version of the JSON output schema. Version 2 added latencies in microseconds (e.g. meanUs, validationOverheadUs
+// or meanDelayUs) next to the latencies rounded to milliseconds (e.g. meanMs), which are kept, so the consumers of version 1 keep working.
+// Results without schemaVersion are version 1.
+const JSONSchemaVersion = 2
+
 type jsonReporter struct{}
 
 type latencyStats struct {
@@ -21,6 +28,17 @@ type latencyStats struct {
 	P90Ms  int64 `json:"p90Ms"`
 	P75Ms  int64 `json:"p75Ms"`
 	P50Ms  int64 `json:"p50Ms"`
+
+	// the latencies in microseconds are available since schema version 2, zeros are kept, so they can be distinguished from version 1
+	MinUs  float64 `json:"minUs"`
+	MeanUs float64 `json:"meanUs"`
+	StdUs  float64 `json:"stdUs"`
+	MaxUs  float64 `json:"maxUs"`
+	P99Us  float64 `json:"p99Us"`
+	P95Us  float64 `json:"p95Us"`
+	P90Us  float64 `json:"p90Us"`
+	P75Us  float64 `json:"p75Us"`
+	P50Us  float64 `json:"p50Us"`
 }
 
 type histogramPoint struct {
@@ -28,25 +46,49 @@ type histogramPoint struct {
 	Count     int64 `json:"count"`
 }
 
+type histogramPointUs struct {
+	LatencyUs float64 `json:"latencyUs"`
+	Count     int64   `json:"count"`
+}
+
 type jsonResult struct {
-	TotalRequests              int64                `json:"totalRequests"`
-	TotalSuccessResponses      int64                `json:"totalSuccessResponses"`
-	TotalNegativeResponses     int64                `json:"totalNegativeResponses"`
-	TotalErrorResponses        int64                `json:"totalErrorResponses"`
-	TotalIOErrors              int64                `json:"totalIOErrors"`
-	TotalIDmismatch            int64                `json:"totalIDmismatch"`
-	TotalTruncatedResponses    int64                `json:"totalTruncatedResponses"`
-	ResponseRcodes             map[string]int64     `json:"responseRcodes,omitempty"`
-	QuestionTypes              map[string]int64     `json:"questionTypes"`
-	QueriesPerSecond           float64              `json:"queriesPerSecond"`
-	BenchmarkDurationSeconds   float64              `json:"benchmarkDurationSeconds"`
-	LatencyStats               latencyStats         `json:"latencyStats"`
-	LatencyDistribution        []histogramPoint     `json:"latencyDistribution,omitempty"`
-	TotalDNSSECSecuredDomains  *int                 `json:"totalDNSSECSecuredDomains,omitempty"`
-	DohHTTPResponseStatusCodes map[int]int64        `json:"dohHTTPResponseStatusCodes,omitempty"`
-	Geocode                    string               `json:"geocode,omitempty"`
-	IP                         string               `json:"ip,omitempty"`
-	Score                      *scoring.ScoreResult `json:"score,omitempty"`
+	SchemaVersion              int                    `json:"schemaVersion,omitempty"`
+	TotalRequests              int64                  `json:"totalRequests"`
+	TotalSuccessResponses      int64                  `json:"totalSuccessResponses"`
+	TotalNegativeResponses     int64                  `json:"totalNegativeResponses"`
+	TotalErrorResponses        int64                  `json:"totalErrorResponses"`
+	TotalIOErrors              int64                  `json:"totalIOErrors"`
+	TotalIDmismatch            int64                  `json:"totalIDmismatch"`
+	TotalTruncatedResponses    int64                  `json:"totalTruncatedResponses"`
+	TotalOutOfOrderResponses   int64                  `json:"totalOutOfOrderResponses,omitempty"`
+	ResponseRcodes             map[string]int64       `json:"responseRcodes,omitempty"`
+	QuestionTypes              map[string]int64       `json:"questionTypes"`
+	QueriesPerSecond           float64                `json:"queriesPerSecond"`
+	BenchmarkDurationSeconds   float64                `json:"benchmarkDurationSeconds"`
+	LatencyStats               latencyStats           `json:"latencyStats"`
+	LatencyDistribution        []histogramPoint       `json:"latencyDistribution,omitempty"`
+	LatencyDistributionUs      []histogramPointUs     `json:"latencyDistributionUs,omitempty"`
+	HDRHistogram               string                 `json:"hdrHistogram,omitempty"`
+	MergedResults              int                    `json:"mergedResults,omitempty"`
+	TotalDNSSECSecuredDomains  *int                   `json:"totalDNSSECSecuredDomains,omitempty"`
+	DohHTTPResponseStatusCodes map[int]int64          `json:"dohHTTPResponseStatusCodes,omitempty"`
+	DohConnections             *dohConnectionsSummary `json:"dohConnections,omitempty"`
+	TLS                        *tlsSummary            `json:"tls,omitempty"`
+	TCFallback                 *tcFallbackSummary     `json:"tcFallback,omitempty"`
+	Retries                    *retriesSummary        `json:"retries,omitempty"`
+	MalformedQueries           malformedSummary       `json:"malformedQueries,omitempty"`
+	ECS                        *ecsSummary            `json:"ecs,omitempty"`
+	NSID                       map[string]int64       `json:"nsid,omitempty"`
+	Instances                  *instancesSummary      `json:"instances,omitempty"`
+	DNSSECValidation           *dnssecSummary         `json:"dnssecValidation,omitempty"`
+	InjectedFaults             *faultsSummary         `json:"injectedFaults,omitempty"`
+	ExtendedDNSErrors          []extendedErrorSummary `json:"extendedDNSErrors,omitempty"`
+	Geocode                    string                 `json:"geocode,omitempty"`
+	IP                         string                 `json:"ip,omitempty"`
+	Score                      *scoring.ScoreResult   `json:"score,omitempty"`
+	SLO                        []ThresholdResult      `json:"slo,omitempty"`
+	Repeat                     *RepeatSummary         `json:"repeat,omitempty"`
+	Config                     map[string]interface{} `json:"config,omitempty"`
 }
 
 // multiServerResult wraps single server results in the format expected by frontend
@@ -81,35 +123,14 @@ func (s *jsonReporter) buildSingleResult(params reportParameters) jsonResult {
 	}
 
 	var res []histogramPoint
-
+	var resUs []histogramPointUs
 	if params.benchmark.HistDisplay {
-		dist := params.hist.Distribution()
-		for _, d := range dist {
-			res = append(res, histogramPoint{
-				LatencyMs: roundDuration(time.Duration(d.To/2 + d.From/2)).Milliseconds(),
-				Count:     d.Count,
-			})
-		}
-
-		var dedupRes []histogramPoint
-		i := -1
-		for _, r := range res {
-			if i >= 0 {
-				if dedupRes[i].LatencyMs == r.LatencyMs {
-					dedupRes[i].Count += r.Count
-				} else {
-					dedupRes = append(dedupRes, r)
-					i++
-				}
-			} else {
-				dedupRes = append(dedupRes, r)
-				i++
-			}
-		}
-		res = dedupRes
+		res = newLatencyDistribution(params.hist)
+		resUs = newLatencyDistributionUs(params.hist)
 	}
 
 	result := jsonResult{
+		SchemaVersion:            JSONSchemaVersion,
 		TotalRequests:            params.totalCounters.Total,
 		TotalSuccessResponses:    params.totalCounters.Success,
 		TotalNegativeResponses:   params.totalCounters.Negative,
@@ -117,24 +138,32 @@ func (s *jsonReporter) buildSingleResult(params reportParameters) jsonResult {
 		TotalIOErrors:            params.totalCounters.IOError,
 		TotalIDmismatch:          params.totalCounters.IDmismatch,
 		TotalTruncatedResponses:  params.totalCounters.Truncated,
-		QueriesPerSecond:         math.Round(float64(params.totalCounters.Total)/params.benchmarkDuration.Seconds()*100) / 100,
+		TotalOutOfOrderResponses: params.totalCounters.OutOfOrder,
+		QueriesPerSecond:         queriesPerSecond(params.totalCounters, params.benchmarkDuration),
 		BenchmarkDurationSeconds: roundDuration(params.benchmarkDuration).Seconds(),
 		ResponseRcodes:           codeTotalsMapped,
 		QuestionTypes:            params.qtypeTotals,
-		LatencyStats: latencyStats{
-			MinMs:  roundDuration(time.Duration(params.hist.Min())).Milliseconds(),
-			MeanMs: roundDuration(time.Duration(params.hist.Mean())).Milliseconds(),
-			StdMs:  roundDuration(time.Duration(params.hist.StdDev())).Milliseconds(),
-			MaxMs:  roundDuration(time.Duration(params.hist.Max())).Milliseconds(),
-			P99Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(99))).Milliseconds(),
-			P95Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(95))).Milliseconds(),
-			P90Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(90))).Milliseconds(),
-			P75Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(75))).Milliseconds(),
-			P50Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(50))).Milliseconds(),
-		},
+		LatencyStats:             newLatencyStats(params.hist),
+
 		LatencyDistribution:        res,
+		LatencyDistributionUs:      resUs,
+		HDRHistogram:               encodeHistogram(params.hist),
 		DohHTTPResponseStatusCodes: params.dohResponseStatusesTotals,
+		DohConnections:             params.dohConnections,
+		TLS:                        params.tlsSummary,
+		TCFallback:                 params.tcFallback,
+		Retries:                    params.retries,
+		MalformedQueries:           params.malformedReactions,
+		ECS:                        params.ecs,
+		NSID:                       params.nsids,
+		Instances:                  params.instances,
+		DNSSECValidation:           params.dnssec,
+		InjectedFaults:             params.faults,
+		ExtendedDNSErrors:          params.extendedErrors,
 		Geocode:                    params.geocode,
+		SLO:                        params.slo,
+		Repeat:                     params.repeat,
+		Config:                     params.benchmark.Config,
 	}
 
 	if params.benchmark.DNSSEC {
@@ -145,25 +174,98 @@ func (s *jsonReporter) buildSingleResult(params reportParameters) jsonResult {
 	return result
 }
 
+// newLatencyDistribution converts the histogram to the latency distribution with latencies rounded to milliseconds.
+func newLatencyDistribution(hist *hdrhistogram.Histogram) []histogramPoint {
+	var res []histogramPoint
+	for _, d := range hist.Distribution() {
+		p := histogramPoint{
+			LatencyMs: roundDuration(time.Duration(d.To/2 + d.From/2)).Milliseconds(),
+			Count:     d.Count,
+		}
+		if len(res) > 0 && res[len(res)-1].LatencyMs == p.LatencyMs {
+			res[len(res)-1].Count += p.Count
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// newLatencyDistributionUs converts the histogram to the latency distribution with latencies in microseconds, empty bars are omitted.
+func newLatencyDistributionUs(hist *hdrhistogram.Histogram) []histogramPointUs {
+	var res []histogramPointUs
+	for _, d := range hist.Distribution() {
+		if d.Count == 0 {
+			continue
+		}
+		res = append(res, histogramPointUs{LatencyUs: durationUs(time.Duration(d.To/2 + d.From/2)), Count: d.Count})
+	}
+	return res
+}
+
+// encodeHistogram encodes the histogram using compressed V2 encoding of HdrHistogram, empty string is returned, if the histogram
+// cannot be encoded.
+func encodeHistogram(hist *hdrhistogram.Histogram) string {
+	encoded, err := hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+func newLatencyStats(hist *hdrhistogram.Histogram) latencyStats {
+	return latencyStats{
+		MinMs:  roundDuration(time.Duration(hist.Min())).Milliseconds(),
+		MeanMs: roundDuration(time.Duration(hist.Mean())).Milliseconds(),
+		StdMs:  roundDuration(time.Duration(hist.StdDev())).Milliseconds(),
+		MaxMs:  roundDuration(time.Duration(hist.Max())).Milliseconds(),
+		P99Ms:  roundDuration(time.Duration(hist.ValueAtQuantile(99))).Milliseconds(),
+		P95Ms:  roundDuration(time.Duration(hist.ValueAtQuantile(95))).Milliseconds(),
+		P90Ms:  roundDuration(time.Duration(hist.ValueAtQuantile(90))).Milliseconds(),
+		P75Ms:  roundDuration(time.Duration(hist.ValueAtQuantile(75))).Milliseconds(),
+		P50Ms:  roundDuration(time.Duration(hist.ValueAtQuantile(50))).Milliseconds(),
+		MinUs:  durationUs(time.Duration(hist.Min())),
+		MeanUs: durationUs(time.Duration(hist.Mean())),
+		StdUs:  durationUs(time.Duration(hist.StdDev())),
+		MaxUs:  durationUs(time.Duration(hist.Max())),
+		P99Us:  durationUs(time.Duration(hist.ValueAtQuantile(99))),
+		P95Us:  durationUs(time.Duration(hist.ValueAtQuantile(95))),
+		P90Us:  durationUs(time.Duration(hist.ValueAtQuantile(90))),
+		P75Us:  durationUs(time.Duration(hist.ValueAtQuantile(75))),
+		P50Us:  durationUs(time.Duration(hist.ValueAtQuantile(50))),
+	}
+}
+
 func (s *jsonReporter) calculateScore(params reportParameters) *scoring.ScoreResult {
-	// Build metrics for scoring
-	metrics := scoring.BenchmarkMetrics{
-		TotalRequests:         params.totalCounters.Total,
-		TotalSuccessResponses: params.totalCounters.Success,
-		TotalErrorResponses:   params.totalCounters.Error,
-		TotalIOErrors:         params.totalCounters.IOError,
-		QueriesPerSecond:      math.Round(float64(params.totalCounters.Total)/params.benchmarkDuration.Seconds()*100) / 100,
+	if params.repeat != nil {
+		// score of the repeated runs is the mean of the trial scores, so it is consistent with its confidence interval
+		score := params.repeat.Score()
+		return &score
+	}
+	score := scoring.CalculateScore(scoreMetrics(params.totalCounters, params.hist, params.benchmarkDuration))
+	return &score
+}
+
+// scoreMetrics builds metrics used for scoring of the benchmark results.
+func scoreMetrics(counters dnsbench.Counters, hist *hdrhistogram.Histogram, benchDuration time.Duration) scoring.BenchmarkMetrics {
+	return scoring.BenchmarkMetrics{
+		TotalRequests:         counters.Total,
+		TotalSuccessResponses: counters.Success,
+		TotalErrorResponses:   counters.Error,
+		TotalIOErrors:         counters.IOError,
+		QueriesPerSecond:      queriesPerSecond(counters, benchDuration),
 		LatencyStats: scoring.LatencyMetrics{
-			MeanMs: roundDuration(time.Duration(params.hist.Mean())).Milliseconds(),
-			StdMs:  roundDuration(time.Duration(params.hist.StdDev())).Milliseconds(),
-			P50Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(50))).Milliseconds(),
-			P95Ms:  roundDuration(time.Duration(params.hist.ValueAtQuantile(95))).Milliseconds(),
+			MeanMs: durationUs(time.Duration(hist.Mean())) / 1000,
+			StdMs:  durationUs(time.Duration(hist.StdDev())) / 1000,
+			P50Ms:  durationUs(time.Duration(hist.ValueAtQuantile(50))) / 1000,
+			P95Ms:  durationUs(time.Duration(hist.ValueAtQuantile(95))) / 1000,
 		},
 	}
+}
 
-	// Calculate and return score
-	score := scoring.CalculateScore(metrics)
-	return &score
+// queriesPerSecond returns QPS rounded to two decimal places.
+func queriesPerSecond(counters dnsbench.Counters, benchDuration time.Duration) float64 {
+	return math.Round(float64(counters.Total)/benchDuration.Seconds()*100) / 100
 }
 
 // extractIPFromServer extracts IP address from server string
@@ -0,0 +1,26 @@
+package reporter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newLatencyStats_zeroMicroseconds(t *testing.T) {
+	hist := hdrhistogram.New(0, int64(time.Minute), 3)
+	require.NoError(t, hist.RecordValue(int64(500*time.Microsecond)))
+
+	data, err := json.Marshal(newLatencyStats(hist))
+	require.NoError(t, err)
+
+	var fields map[string]float64
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Contains(t, fields, "stdUs", "zero of schema version 2 field must be kept")
+	assert.Zero(t, fields["stdUs"])
+	assert.InDelta(t, 500, fields["meanUs"], 1)
+	assert.Zero(t, fields["meanMs"], "sub-millisecond latency is 0 in milliseconds")
+}
@@ -0,0 +1,105 @@
+package reporter
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const benchmarkTestCase = "benchmark"
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+var junitFailureTypes = map[string]string{
+	AssertionFailCondition: "FailCondition",
+	AssertionSLO:           "SLOViolation",
+}
+
+type junitReporter struct{}
+
+func (s *junitReporter) print(params reportParameters) error {
+	return WriteJUnit(params.outputWriter, []AssertionSuite{params.assertions})
+}
+
+func writeJUnitFile(path string, suites []AssertionSuite) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteJUnit(f, suites); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJUnit writes the assertion suites in JUnit XML format. Each suite contains test case for the benchmark run, which
+// carries the duration of the benchmark and fails when the benchmark failed to run, and test case for each assertion.
+func WriteJUnit(w io.Writer, suites []AssertionSuite) error {
+	res := junitTestSuites{Suites: make([]junitTestSuite, 0, len(suites))}
+	for _, s := range suites {
+		suite := junitTestSuite{
+			Name:      "dnspyre " + s.Name,
+			Tests:     s.Tests(),
+			Failures:  s.Failures(),
+			Time:      junitSeconds(s.Duration),
+			Timestamp: s.Start.Format(time.RFC3339),
+		}
+		tc := junitTestCase{Name: benchmarkTestCase, Classname: s.Name, Time: junitSeconds(s.Duration)}
+		if len(s.Error) != 0 {
+			tc.Failure = &junitFailure{Message: s.Error, Type: "BenchmarkError", Text: s.Error}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+		for _, a := range s.Assertions {
+			// assertions are evaluated after the benchmark, so they do not take any time of their own
+			tc := junitTestCase{Name: a.Kind + " " + a.Name, Classname: s.Name, Time: junitSeconds(0)}
+			if !a.Passed {
+				tc.Failure = &junitFailure{Message: a.Message, Type: junitFailureTypes[a.Kind], Text: a.Message}
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		res.Suites = append(res.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
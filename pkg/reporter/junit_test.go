@@ -0,0 +1,84 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAssertionSuites() []AssertionSuite {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []AssertionSuite{
+		{
+			Name:     "8.8.8.8:53",
+			Start:    start,
+			Duration: 1500 * time.Millisecond,
+			Assertions: []AssertionResult{
+				{Kind: AssertionFailCondition, Name: "ioerror", Passed: true, Message: "ioerror 0 == 0"},
+				{Kind: AssertionSLO, Name: "p99<50ms", Passed: true, Message: "p99 20ms < 50ms"},
+				{Kind: AssertionSLO, Name: "qps>=20000", Passed: false, Message: "qps 1000 is not >= 20000"},
+			},
+		},
+		{
+			Name:     "1.1.1.1:53",
+			Start:    start,
+			Duration: 0,
+			Error:    "connection refused",
+		},
+	}
+}
+
+func Test_writeJUnit(t *testing.T) {
+	suite := AssertionSuite{
+		Name:     "8.8.8.8:53",
+		Start:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration: 1500 * time.Millisecond,
+		Assertions: []AssertionResult{
+			{Kind: AssertionSLO, Name: "p99<50ms", Passed: true, Message: "p99 20ms < 50ms"},
+			{Kind: AssertionSLO, Name: "qps>=20000", Passed: false, Message: "qps 1000 is not >= 20000"},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, []AssertionSuite{suite})
+
+	require.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="dnspyre 8.8.8.8:53" tests="3" failures="1" time="1.500" timestamp="2024-01-02T03:04:05Z">
+    <testcase name="benchmark" classname="8.8.8.8:53" time="1.500"></testcase>
+    <testcase name="slo p99&lt;50ms" classname="8.8.8.8:53" time="0.000"></testcase>
+    <testcase name="slo qps&gt;=20000" classname="8.8.8.8:53" time="0.000">
+      <failure message="qps 1000 is not &gt;= 20000" type="SLOViolation">qps 1000 is not &gt;= 20000</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func Test_writeJUnit_multipleSuites(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJUnit(&buf, testAssertionSuites())
+
+	require.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="dnspyre 8.8.8.8:53" tests="4" failures="1" time="1.500" timestamp="2024-01-02T03:04:05Z">
+    <testcase name="benchmark" classname="8.8.8.8:53" time="1.500"></testcase>
+    <testcase name="fail ioerror" classname="8.8.8.8:53" time="0.000"></testcase>
+    <testcase name="slo p99&lt;50ms" classname="8.8.8.8:53" time="0.000"></testcase>
+    <testcase name="slo qps&gt;=20000" classname="8.8.8.8:53" time="0.000">
+      <failure message="qps 1000 is not &gt;= 20000" type="SLOViolation">qps 1000 is not &gt;= 20000</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="dnspyre 1.1.1.1:53" tests="1" failures="1" time="0.000" timestamp="2024-01-02T03:04:05Z">
+    <testcase name="benchmark" classname="1.1.1.1:53" time="0.000">
+      <failure message="connection refused" type="BenchmarkError">connection refused</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
@@ -0,0 +1,39 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+// malformedSummary contains number of server reactions by the mutation of the malformed query.
+type malformedSummary map[string]map[string]int64
+
+func printMalformedReactions(w io.Writer, reactions malformedSummary) {
+	mutations := make([]string, 0, len(reactions))
+	for k := range reactions {
+		mutations = append(mutations, k)
+	}
+	sort.Strings(mutations)
+
+	printutils.NeutralFprintf(w, "\nMalformed queries:\n")
+	for _, m := range mutations {
+		printutils.NeutralFprintf(w, "\t%s:\n", m)
+
+		keys := make([]string, 0, len(reactions[m]))
+		for k := range reactions[m] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			printFn := printutils.NeutralFprintf
+			if k == dnsbench.ReactionUnresponsive || k == dnsbench.ReactionMalformedResponse {
+				// the server might have crashed or it does not handle the malformed input correctly
+				printFn = printutils.ErrFprintf
+			}
+			printFn(w, "\t\t%s:\t%d\n", k, reactions[m][k])
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 )
 
 // BenchmarkResultStats represents merged results of the dnsbench.Benchmark execution.
@@ -20,6 +21,19 @@ type BenchmarkResultStats struct {
 	GroupedErrors        map[string]int
 	AuthenticatedDomains map[string]struct{}
 	DoHStatusCodes       map[int]int64
+	TLS                  *dnsbench.TLSStats
+	DoHConnections       *dnsbench.DoHConnectionStats
+	TCFallbackHist       *hdrhistogram.Histogram
+	Attempts             map[int]int64
+	FirstAttemptHist     *hdrhistogram.Histogram
+	MalformedReactions   map[string]map[string]int64
+	ECS                  map[string]*dnsbench.ECSStats
+	ECSAnswers           map[string]map[string]string
+	NSIDs                map[string]int64
+	ExtendedErrors       map[dnsbench.ExtendedError]int64
+	Instances            map[string]*dnsbench.InstanceStats
+	DNSSECValidation     *dnsbench.DNSSECValidationStats
+	InjectedFaults       *faultproxy.Stats
 }
 
 // Merge takes results of the executed dnsbench.Benchmark and merges them.
@@ -31,6 +45,33 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 		GroupedErrors:        make(map[string]int),
 		AuthenticatedDomains: make(map[string]struct{}),
 		DoHStatusCodes:       make(map[int]int64),
+		NSIDs:                make(map[string]int64),
+		ExtendedErrors:       make(map[dnsbench.ExtendedError]int64),
+	}
+	if len(b.ECS) > 0 {
+		totals.ECS = make(map[string]*dnsbench.ECSStats)
+		totals.ECSAnswers = make(map[string]map[string]string)
+	}
+	if b.DNSSECValidation {
+		totals.DNSSECValidation = &dnsbench.DNSSECValidationStats{
+			Cases:         make(map[string]*dnsbench.DNSSECCaseStats),
+			Algorithms:    make(map[uint8]*dnsbench.DNSSECAlgorithmStats),
+			ValidatedHist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+			UncheckedHist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre),
+		}
+	}
+	if len(b.InstanceID) > 0 {
+		totals.Instances = make(map[string]*dnsbench.InstanceStats)
+	}
+	if b.Malformed {
+		totals.MalformedReactions = make(map[string]map[string]int64)
+	}
+	if b.Retries > 0 {
+		totals.Attempts = make(map[int]int64)
+		totals.FirstAttemptHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
+	}
+	if b.TCFallback {
+		totals.TCFallbackHist = hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)
 	}
 
 	for _, s := range stats {
@@ -45,7 +86,92 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 		}
 		totals.Errors = append(totals.Errors, s.Errors...)
 
+		if s.TLS != nil {
+			if totals.TLS == nil {
+				totals.TLS = &dnsbench.TLSStats{
+					Versions:     make(map[string]int64),
+					CipherSuites: make(map[string]int64),
+					ALPN:         make(map[string]int64),
+				}
+			}
+			totals.TLS.Merge(s.TLS)
+		}
+		if s.DoHConnections != nil {
+			if totals.DoHConnections == nil {
+				totals.DoHConnections = &dnsbench.DoHConnectionStats{}
+			}
+			totals.DoHConnections.Merge(s.DoHConnections)
+		}
+
 		totals.Hist.Merge(s.Hist)
+		if totals.TCFallbackHist != nil && s.TCFallbackHist != nil {
+			totals.TCFallbackHist.Merge(s.TCFallbackHist)
+		}
+		if totals.FirstAttemptHist != nil && s.FirstAttemptHist != nil {
+			totals.FirstAttemptHist.Merge(s.FirstAttemptHist)
+		}
+		if totals.MalformedReactions != nil {
+			for mutation, reactions := range s.MalformedReactions {
+				if totals.MalformedReactions[mutation] == nil {
+					totals.MalformedReactions[mutation] = make(map[string]int64)
+				}
+				for k, v := range reactions {
+					totals.MalformedReactions[mutation][k] += v
+				}
+			}
+		}
+		if totals.ECS != nil {
+			for subnet, st := range s.ECS {
+				t, ok := totals.ECS[subnet]
+				if !ok {
+					t = &dnsbench.ECSStats{ScopePrefixes: make(map[int]int64)}
+					totals.ECS[subnet] = t
+				}
+				t.Queries += st.Queries
+				t.NoECS += st.NoECS
+				for k, v := range st.ScopePrefixes {
+					t.ScopePrefixes[k] += v
+				}
+			}
+			for question, bySubnet := range s.ECSAnswers {
+				if totals.ECSAnswers[question] == nil {
+					totals.ECSAnswers[question] = make(map[string]string)
+				}
+				for k, v := range bySubnet {
+					totals.ECSAnswers[question][k] = v
+				}
+			}
+		}
+		if s.InjectedFaults != nil {
+			if totals.InjectedFaults == nil {
+				totals.InjectedFaults = &faultproxy.Stats{}
+			}
+			totals.InjectedFaults.QueriesDropped += s.InjectedFaults.QueriesDropped
+			totals.InjectedFaults.ResponsesDropped += s.InjectedFaults.ResponsesDropped
+			totals.InjectedFaults.Duplicated += s.InjectedFaults.Duplicated
+			totals.InjectedFaults.Reordered += s.InjectedFaults.Reordered
+			totals.InjectedFaults.Delayed += s.InjectedFaults.Delayed
+			totals.InjectedFaults.DelayTotal += s.InjectedFaults.DelayTotal
+		}
+		if totals.DNSSECValidation != nil && s.DNSSECValidation != nil {
+			mergeDNSSEC(totals.DNSSECValidation, s.DNSSECValidation)
+		}
+		if totals.Instances != nil {
+			for instance, st := range s.Instances {
+				t, ok := totals.Instances[instance]
+				if !ok {
+					t = &dnsbench.InstanceStats{Hist: hdrhistogram.New(b.HistMin.Nanoseconds(), b.HistMax.Nanoseconds(), b.HistPre)}
+					totals.Instances[instance] = t
+				}
+				t.Responses += st.Responses
+				t.Hist.Merge(st.Hist)
+			}
+		}
+		if totals.Attempts != nil {
+			for k, v := range s.Attempts {
+				totals.Attempts[k] += v
+			}
+		}
 		totals.Timings = append(totals.Timings, s.Timings...)
 		if s.Codes != nil {
 			for k, v := range s.Codes {
@@ -57,6 +183,12 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 				totals.Qtypes[k] += v
 			}
 		}
+		for k, v := range s.NSIDs {
+			totals.NSIDs[k] += v
+		}
+		for k, v := range s.ExtendedErrors {
+			totals.ExtendedErrors[k] += v
+		}
 		if s.DoHStatusCodes != nil {
 			for k, v := range s.DoHStatusCodes {
 				totals.DoHStatusCodes[k] += v
@@ -64,13 +196,18 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 		}
 		if s.Counters != nil {
 			totals.Counters = dnsbench.Counters{
-				Total:      totals.Counters.Total + s.Counters.Total,
-				IOError:    totals.Counters.IOError + s.Counters.IOError,
-				Success:    totals.Counters.Success + s.Counters.Success,
-				Negative:   totals.Counters.Negative + s.Counters.Negative,
-				Error:      totals.Counters.Error + s.Counters.Error,
-				IDmismatch: totals.Counters.IDmismatch + s.Counters.IDmismatch,
-				Truncated:  totals.Counters.Truncated + s.Counters.Truncated,
+				Total:           totals.Counters.Total + s.Counters.Total,
+				IOError:         totals.Counters.IOError + s.Counters.IOError,
+				Success:         totals.Counters.Success + s.Counters.Success,
+				Negative:        totals.Counters.Negative + s.Counters.Negative,
+				Error:           totals.Counters.Error + s.Counters.Error,
+				IDmismatch:      totals.Counters.IDmismatch + s.Counters.IDmismatch,
+				Truncated:       totals.Counters.Truncated + s.Counters.Truncated,
+				TCFallback:      totals.Counters.TCFallback + s.Counters.TCFallback,
+				TCFallbackError: totals.Counters.TCFallbackError + s.Counters.TCFallbackError,
+				Retried:         totals.Counters.Retried + s.Counters.Retried,
+				OutOfOrder:      totals.Counters.OutOfOrder + s.Counters.OutOfOrder,
+				InstanceChanges: totals.Counters.InstanceChanges + s.Counters.InstanceChanges,
 			}
 		}
 		if b.DNSSEC {
@@ -92,6 +229,30 @@ func Merge(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats) BenchmarkResult
 	return totals
 }
 
+func mergeDNSSEC(totals, st *dnsbench.DNSSECValidationStats) {
+	for name, c := range st.Cases {
+		t, ok := totals.Cases[name]
+		if !ok {
+			t = &dnsbench.DNSSECCaseStats{Expected: c.Expected, Results: make(map[string]int64)}
+			totals.Cases[name] = t
+		}
+		for k, v := range c.Results {
+			t.Results[k] += v
+		}
+	}
+	for alg, a := range st.Algorithms {
+		t, ok := totals.Algorithms[alg]
+		if !ok {
+			t = &dnsbench.DNSSECAlgorithmStats{}
+			totals.Algorithms[alg] = t
+		}
+		t.Validated += a.Validated
+		t.NotValidated += a.NotValidated
+	}
+	totals.ValidatedHist.Merge(st.ValidatedHist)
+	totals.UncheckedHist.Merge(st.UncheckedHist)
+}
+
 func errString(err dnsbench.ErrorDatapoint) string {
 	var errorString string
 	var netOpErr *net.OpError
@@ -9,6 +9,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 	"github.com/tantalor93/dnspyre/v3/pkg/reporter"
 )
 
@@ -44,9 +45,12 @@ func TestMerge(t *testing.T) {
 				Success:    2,
 				Negative:   1,
 				Truncated:  1,
+				TCFallback: 1,
+				Retried:    1,
 				IOError:    2,
 				Error:      1,
 				IDmismatch: 1,
+				OutOfOrder: 1,
 				Total:      8,
 			},
 			Errors: []dnsbench.ErrorDatapoint{
@@ -66,6 +70,15 @@ func TestMerge(t *testing.T) {
 				200: 5,
 				503: 1,
 			},
+			TLS: &dnsbench.TLSStats{
+				Handshakes:   1,
+				Versions:     map[string]int64{"TLS 1.3": 1},
+				CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 1},
+				Certificates: []dnsbench.CertificateInfo{{Subject: "CN=dns.example", Issuer: "CN=Example CA"}},
+			},
+			DoHConnections: &dnsbench.DoHConnectionStats{Opened: 1, Streams: 3, MinStreams: 3, MaxStreams: 3},
+			NSIDs:          map[string]int64{"ns1": 2},
+			ExtendedErrors: map[dnsbench.ExtendedError]int64{{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream timeout"}: 1},
 		},
 		{
 			Codes: map[int]int64{
@@ -95,6 +108,7 @@ func TestMerge(t *testing.T) {
 				IOError:    1,
 				Error:      1,
 				IDmismatch: 1,
+				OutOfOrder: 2,
 				Total:      6,
 			},
 			Errors: []dnsbench.ErrorDatapoint{
@@ -110,6 +124,15 @@ func TestMerge(t *testing.T) {
 				200: 4,
 				500: 1,
 			},
+			TLS: &dnsbench.TLSStats{
+				Handshakes:   2,
+				Versions:     map[string]int64{"TLS 1.2": 1, "TLS 1.3": 1},
+				CipherSuites: map[string]int64{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 1, "TLS_AES_128_GCM_SHA256": 1},
+				Resumed:      1,
+				Certificates: []dnsbench.CertificateInfo{{Subject: "CN=dns.example", Issuer: "CN=Example CA"}, {Subject: "CN=Example CA", Issuer: "CN=Example CA"}},
+			},
+			DoHConnections: &dnsbench.DoHConnectionStats{Opened: 2, Streams: 3, MinStreams: 1, MaxStreams: 2},
+			NSIDs:          map[string]int64{"ns1": 1, "ns2": 1},
 		},
 	}
 
@@ -150,9 +173,12 @@ func TestMerge(t *testing.T) {
 			Success:    3,
 			Negative:   2,
 			Truncated:  2,
+			TCFallback: 1,
+			Retried:    1,
 			IOError:    3,
 			Error:      2,
 			IDmismatch: 2,
+			OutOfOrder: 3,
 			Total:      14,
 		},
 		Errors: []dnsbench.ErrorDatapoint{
@@ -181,6 +207,17 @@ func TestMerge(t *testing.T) {
 			500: 1,
 			503: 1,
 		},
+		TLS: &dnsbench.TLSStats{
+			Handshakes:   3,
+			Versions:     map[string]int64{"TLS 1.3": 2, "TLS 1.2": 1},
+			CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 2, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 1},
+			ALPN:         map[string]int64{},
+			Resumed:      1,
+			Certificates: []dnsbench.CertificateInfo{{Subject: "CN=dns.example", Issuer: "CN=Example CA"}, {Subject: "CN=Example CA", Issuer: "CN=Example CA"}},
+		},
+		DoHConnections: &dnsbench.DoHConnectionStats{Opened: 3, Streams: 6, MinStreams: 1, MaxStreams: 3},
+		NSIDs:          map[string]int64{"ns1": 3, "ns2": 1},
+		ExtendedErrors: map[dnsbench.ExtendedError]int64{{InfoCode: dns.ExtendedErrorCodeStaleAnswer, ExtraText: "upstream timeout"}: 1},
 	}
 
 	res := reporter.Merge(&dnsbench.Benchmark{DNSSEC: true, HistMin: 0, HistMax: 5 * time.Second, HistPre: 1}, stats)
@@ -188,6 +225,59 @@ func TestMerge(t *testing.T) {
 	assert.Equal(t, want, res)
 }
 
+func TestMerge_instances(t *testing.T) {
+	stats := []*dnsbench.ResultStats{
+		{
+			Hist:     histogramWithValues(time.Second, 2*time.Second),
+			Counters: &dnsbench.Counters{Total: 2, Success: 2, InstanceChanges: 1},
+			Instances: map[string]*dnsbench.InstanceStats{
+				"pop1": {Responses: 1, Hist: histogramWithValues(time.Second)},
+				"pop2": {Responses: 1, Hist: histogramWithValues(2 * time.Second)},
+			},
+		},
+		{
+			Hist:     histogramWithValues(time.Second),
+			Counters: &dnsbench.Counters{Total: 1, Success: 1},
+			Instances: map[string]*dnsbench.InstanceStats{
+				"pop1": {Responses: 1, Hist: histogramWithValues(time.Second)},
+			},
+		},
+	}
+
+	res := reporter.Merge(&dnsbench.Benchmark{InstanceID: dnsbench.InstanceIDNSID, HistMin: 0, HistMax: 5 * time.Second, HistPre: 1}, stats)
+
+	assert.Equal(t, dnsbench.Counters{Total: 3, Success: 3, InstanceChanges: 1}, res.Counters)
+	assert.Equal(t, map[string]*dnsbench.InstanceStats{
+		"pop1": {Responses: 2, Hist: histogramWithValues(time.Second, time.Second)},
+		"pop2": {Responses: 1, Hist: histogramWithValues(2 * time.Second)},
+	}, res.Instances)
+}
+
+func TestMerge_faults(t *testing.T) {
+	stats := []*dnsbench.ResultStats{
+		{
+			Hist:           histogramWithValues(time.Second),
+			Counters:       &dnsbench.Counters{Total: 2, Success: 1, IOError: 1},
+			InjectedFaults: &faultproxy.Stats{QueriesDropped: 1, Duplicated: 2, Delayed: 3, DelayTotal: 3 * time.Millisecond},
+		},
+		{
+			Hist:     histogramWithValues(time.Second),
+			Counters: &dnsbench.Counters{Total: 1, Success: 1},
+		},
+		{
+			Hist:           histogramWithValues(time.Second),
+			Counters:       &dnsbench.Counters{Total: 1, Success: 1},
+			InjectedFaults: &faultproxy.Stats{ResponsesDropped: 1, Reordered: 1, Delayed: 1, DelayTotal: time.Millisecond},
+		},
+	}
+
+	res := reporter.Merge(&dnsbench.Benchmark{HistMin: 0, HistMax: 5 * time.Second, HistPre: 1}, stats)
+
+	assert.Equal(t, &faultproxy.Stats{
+		QueriesDropped: 1, ResponsesDropped: 1, Duplicated: 2, Reordered: 1, Delayed: 4, DelayTotal: 4 * time.Millisecond,
+	}, res.InjectedFaults)
+}
+
 func histogramWithValues(durations ...time.Duration) *hdrhistogram.Histogram {
 	hst := hdrhistogram.New(0, 5*time.Second.Nanoseconds(), 1)
 	for _, v := range durations {
@@ -0,0 +1,282 @@
+package reporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/olekukonko/tablewriter"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+// MergeResults merges JSON outputs of the benchmarks run against the same servers, for example by multiple load generators,
+// and writes the merged JSON output to the writer. The results of the same server are merged using their HDR histograms,
+// so the latency statistics, distribution and score are recomputed exactly as if the benchmark was run by a single load generator.
+// The load generators are expected to run concurrently, so the duration of the merged benchmark is the longest duration of the merged results.
+func MergeResults(w io.Writer, results ...[]byte) error {
+	merged, err := mergeResults(results...)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(merged)
+}
+
+// PrintMergedResults prints the metrics of the merged JSON output in human-readable format.
+func PrintMergedResults(w io.Writer, merged []byte) error {
+	res, err := parseResults(merged)
+	if err != nil {
+		return err
+	}
+	servers := make([]string, 0, len(res))
+	for server := range res {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	for _, server := range servers {
+		r := res[server]
+		printutils.NeutralFprintf(w, "\nMerged results of %s (%s results):\n", printutils.HighlightSprint(server), printutils.HighlightSprint(r.MergedResults))
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Metric", "Value"})
+		table.SetBorder(false)
+		table.SetAutoWrapText(false)
+		for _, m := range comparedMetrics {
+			if v, ok := m.value(r); ok {
+				table.Append([]string{m.name, formatMetricValue(m.unit, v)})
+			}
+		}
+		table.Render()
+	}
+	return nil
+}
+
+// EvaluateMergedAssertions evaluates fail conditions and SLOs configured in the benchmark against each server of the merged JSON output,
+// the latency thresholds are evaluated using the merged HDR histograms. The suites are ordered by the server.
+func EvaluateMergedAssertions(b *dnsbench.Benchmark, merged []byte, benchStart time.Time) ([]AssertionSuite, error) {
+	res, err := parseResults(merged)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]string, 0, len(res))
+	for server := range res {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	suites := make([]AssertionSuite, 0, len(servers))
+	for _, server := range servers {
+		r := res[server]
+		hist, err := hdrhistogram.Decode([]byte(r.HDRHistogram))
+		if err != nil {
+			return nil, fmt.Errorf("server %s: invalid HDR histogram: %w", server, err)
+		}
+		stats := BenchmarkResultStats{
+			Hist: hist,
+			Counters: dnsbench.Counters{
+				Total:      r.TotalRequests,
+				Success:    r.TotalSuccessResponses,
+				Negative:   r.TotalNegativeResponses,
+				Error:      r.TotalErrorResponses,
+				IOError:    r.TotalIOErrors,
+				IDmismatch: r.TotalIDmismatch,
+				Truncated:  r.TotalTruncatedResponses,
+				OutOfOrder: r.TotalOutOfOrderResponses,
+			},
+		}
+		duration := time.Duration(r.BenchmarkDurationSeconds * float64(time.Second)).Round(time.Millisecond)
+		suite, err := EvaluateAssertions(b, stats, benchStart, duration)
+		if err != nil {
+			return nil, err
+		}
+		suite.Name = server
+		suites = append(suites, suite)
+	}
+	return suites, nil
+}
+
+func mergeResults(results ...[]byte) (multiServerResult, error) {
+	if len(results) == 0 {
+		return nil, errors.New("no JSON results to merge")
+	}
+	byServer := make(map[string][]jsonResult)
+	var servers []string
+	for i, data := range results {
+		res, err := parseResults(data)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %w", i+1, err)
+		}
+		for server, r := range res {
+			if _, ok := byServer[server]; !ok {
+				servers = append(servers, server)
+			}
+			byServer[server] = append(byServer[server], r)
+		}
+	}
+
+	merged := make(multiServerResult)
+	for _, server := range servers {
+		r, err := mergeServerResults(byServer[server])
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", server, err)
+		}
+		merged[server] = r
+	}
+	return merged, nil
+}
+
+// mergeServerResults merges results of the single server. Only the counters, latencies and the sections, which can be merged
+// exactly, are kept in the merged result. The results have to be created with the same configuration.
+func mergeServerResults(results []jsonResult) (jsonResult, error) {
+	hists := make([]*hdrhistogram.Histogram, 0, len(results))
+	for i, r := range results {
+		if keys := configDiff(results[0].Config, r.Config); len(keys) != 0 {
+			return jsonResult{}, fmt.Errorf("result %d was created with different config than result 1 (%s), only results of the same benchmark can be merged",
+				i+1, strings.Join(keys, ", "))
+		}
+		if len(r.HDRHistogram) == 0 {
+			return jsonResult{}, fmt.Errorf("result %d does not contain HDR histogram, it was created by older version of dnspyre", i+1)
+		}
+		h, err := hdrhistogram.Decode([]byte(r.HDRHistogram))
+		if err != nil {
+			return jsonResult{}, fmt.Errorf("result %d contains invalid HDR histogram: %w", i+1, err)
+		}
+		hists = append(hists, h)
+	}
+	hist, err := mergeHistograms(hists)
+	if err != nil {
+		return jsonResult{}, err
+	}
+
+	var counters dnsbench.Counters
+	var duration time.Duration
+	var distribution, distributionUs bool
+	extendedErrors := make(map[dnsbench.ExtendedError]int64)
+	merged := jsonResult{
+		SchemaVersion: JSONSchemaVersion,
+		Geocode:       results[0].Geocode,
+		IP:            results[0].IP,
+		Config:        results[0].Config,
+	}
+	for _, r := range results {
+		counters.Total += r.TotalRequests
+		counters.Success += r.TotalSuccessResponses
+		counters.Negative += r.TotalNegativeResponses
+		counters.Error += r.TotalErrorResponses
+		counters.IOError += r.TotalIOErrors
+		counters.IDmismatch += r.TotalIDmismatch
+		counters.Truncated += r.TotalTruncatedResponses
+		counters.OutOfOrder += r.TotalOutOfOrderResponses
+
+		// durations in JSON output are rounded to milliseconds, so this conversion is exact
+		if d := time.Duration(r.BenchmarkDurationSeconds * float64(time.Second)).Round(time.Millisecond); d > duration {
+			duration = d
+		}
+		distribution = distribution || len(r.LatencyDistribution) > 0
+		distributionUs = distributionUs || len(r.LatencyDistributionUs) > 0
+
+		merged.ResponseRcodes = mergeCounts(merged.ResponseRcodes, r.ResponseRcodes)
+		merged.QuestionTypes = mergeCounts(merged.QuestionTypes, r.QuestionTypes)
+		merged.DohHTTPResponseStatusCodes = mergeCounts(merged.DohHTTPResponseStatusCodes, r.DohHTTPResponseStatusCodes)
+		merged.NSID = mergeCounts(merged.NSID, r.NSID)
+		for _, e := range r.ExtendedDNSErrors {
+			withoutText := e.Count
+			for text, count := range e.ExtraTexts {
+				extendedErrors[dnsbench.ExtendedError{InfoCode: e.InfoCode, ExtraText: text}] += count
+				withoutText -= count
+			}
+			if withoutText > 0 {
+				extendedErrors[dnsbench.ExtendedError{InfoCode: e.InfoCode}] += withoutText
+			}
+		}
+
+		if r.Geocode != merged.Geocode {
+			merged.Geocode = ""
+		}
+		if r.IP != merged.IP {
+			merged.IP = ""
+		}
+	}
+
+	merged.TotalRequests = counters.Total
+	merged.TotalSuccessResponses = counters.Success
+	merged.TotalNegativeResponses = counters.Negative
+	merged.TotalErrorResponses = counters.Error
+	merged.TotalIOErrors = counters.IOError
+	merged.TotalIDmismatch = counters.IDmismatch
+	merged.TotalTruncatedResponses = counters.Truncated
+	merged.TotalOutOfOrderResponses = counters.OutOfOrder
+	merged.ExtendedDNSErrors = summarizeExtendedErrors(extendedErrors)
+	if duration > 0 {
+		merged.QueriesPerSecond = queriesPerSecond(counters, duration)
+	}
+	merged.BenchmarkDurationSeconds = duration.Seconds()
+	merged.LatencyStats = newLatencyStats(hist)
+	if distribution {
+		merged.LatencyDistribution = newLatencyDistribution(hist)
+	}
+	if distributionUs {
+		merged.LatencyDistributionUs = newLatencyDistributionUs(hist)
+	}
+	merged.HDRHistogram = encodeHistogram(hist)
+	score := scoring.CalculateScore(scoreMetrics(counters, hist, duration))
+	merged.Score = &score
+	merged.MergedResults = len(results)
+	return merged, nil
+}
+
+// configDiff returns sorted keys of the configs with different values.
+func configDiff(a, b map[string]interface{}) []string {
+	var keys []string
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || !reflect.DeepEqual(v, bv) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeHistograms merges the histograms into a new histogram covering the ranges of all the histograms with the highest precision
+// of the histograms, so no value is lost.
+func mergeHistograms(hists []*hdrhistogram.Histogram) (*hdrhistogram.Histogram, error) {
+	lowest, highest, figures := hists[0].LowestTrackableValue(), hists[0].HighestTrackableValue(), hists[0].SignificantFigures()
+	for _, h := range hists[1:] {
+		lowest = min(lowest, h.LowestTrackableValue())
+		highest = max(highest, h.HighestTrackableValue())
+		figures = max(figures, h.SignificantFigures())
+	}
+	merged := hdrhistogram.New(lowest, highest, int(figures))
+	for _, h := range hists {
+		if dropped := merged.Merge(h); dropped > 0 {
+			return nil, fmt.Errorf("failed to merge %d latencies into HDR histogram", dropped)
+		}
+	}
+	return merged, nil
+}
+
+// mergeCounts adds the counts to the merged counts, nil is returned, if there are no counts.
+func mergeCounts[K comparable](merged, counts map[K]int64) map[K]int64 {
+	if len(counts) == 0 {
+		return merged
+	}
+	if merged == nil {
+		merged = make(map[K]int64)
+	}
+	for k, v := range counts {
+		merged[k] += v
+	}
+	return merged
+}
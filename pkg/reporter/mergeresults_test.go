@@ -0,0 +1,214 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+func latencyHistogram(t *testing.T, figures int, latencies ...time.Duration) *hdrhistogram.Histogram {
+	t.Helper()
+	hist := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Minute.Nanoseconds(), figures)
+	for _, l := range latencies {
+		require.NoError(t, hist.RecordValue(l.Nanoseconds()))
+	}
+	return hist
+}
+
+func TestMergeResults(t *testing.T) {
+	first := []time.Duration{1 * time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 40 * time.Millisecond}
+	second := []time.Duration{5 * time.Millisecond, 6 * time.Millisecond, 70 * time.Millisecond}
+
+	res1 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:            4,
+			TotalSuccessResponses:    3,
+			TotalIOErrors:            1,
+			ResponseRcodes:           map[string]int64{"NOERROR": 3},
+			QuestionTypes:            map[string]int64{"A": 4},
+			BenchmarkDurationSeconds: 2,
+			LatencyDistribution:      []histogramPoint{{LatencyMs: 1, Count: 4}},
+			HDRHistogram:             encodeHistogram(latencyHistogram(t, 3, first...)),
+			ExtendedDNSErrors:        []extendedErrorSummary{{InfoCode: 18, Name: "Prohibited", Count: 2, ExtraTexts: map[string]int64{"blocked": 1}}},
+			IP:                       "8.8.8.8:53",
+			Config:                   map[string]interface{}{"concurrency": float64(2)},
+		},
+	})
+	res2 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:            3,
+			TotalSuccessResponses:    2,
+			TotalNegativeResponses:   1,
+			ResponseRcodes:           map[string]int64{"NOERROR": 2, "NXDOMAIN": 1},
+			QuestionTypes:            map[string]int64{"A": 2, "AAAA": 1},
+			BenchmarkDurationSeconds: 2.5,
+			HDRHistogram:             encodeHistogram(latencyHistogram(t, 3, second...)),
+			ExtendedDNSErrors:        []extendedErrorSummary{{InfoCode: 18, Name: "Prohibited", Count: 1, ExtraTexts: map[string]int64{"blocked": 1}}},
+			IP:                       "8.8.8.8:53",
+			Config:                   map[string]interface{}{"concurrency": float64(2)},
+		},
+		"1.1.1.1:53": {
+			TotalRequests:            1,
+			TotalSuccessResponses:    1,
+			BenchmarkDurationSeconds: 1,
+			HDRHistogram:             encodeHistogram(latencyHistogram(t, 3, time.Millisecond)),
+		},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, MergeResults(&buf, res1, res2))
+	var merged multiServerResult
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &merged))
+	require.Len(t, merged, 2)
+
+	want := latencyHistogram(t, 3, append(first, second...)...)
+	got := merged["8.8.8.8:53"]
+	assert.Equal(t, int64(7), got.TotalRequests)
+	assert.Equal(t, int64(5), got.TotalSuccessResponses)
+	assert.Equal(t, int64(1), got.TotalNegativeResponses)
+	assert.Equal(t, int64(1), got.TotalIOErrors)
+	assert.Equal(t, map[string]int64{"NOERROR": 5, "NXDOMAIN": 1}, got.ResponseRcodes)
+	assert.Equal(t, map[string]int64{"A": 6, "AAAA": 1}, got.QuestionTypes)
+	assert.Equal(t, []extendedErrorSummary{{InfoCode: 18, Name: "Prohibited", Count: 3, ExtraTexts: map[string]int64{"blocked": 2}}}, got.ExtendedDNSErrors)
+	assert.InDelta(t, 2.5, got.BenchmarkDurationSeconds, 1e-9)
+	assert.InDelta(t, 2.8, got.QueriesPerSecond, 1e-9)
+	assert.Equal(t, newLatencyStats(want), got.LatencyStats)
+	assert.Equal(t, newLatencyDistribution(want), got.LatencyDistribution)
+	assert.Nil(t, got.LatencyDistributionUs)
+	assert.Equal(t, JSONSchemaVersion, got.SchemaVersion)
+	assert.Equal(t, 2, got.MergedResults)
+	assert.Equal(t, "8.8.8.8:53", got.IP)
+	assert.Equal(t, map[string]interface{}{"concurrency": float64(2)}, got.Config)
+
+	counters := dnsbench.Counters{Total: 7, Success: 5, Negative: 1, IOError: 1}
+	wantScore := scoring.CalculateScore(scoreMetrics(counters, want, 2500*time.Millisecond))
+	require.NotNil(t, got.Score)
+	assert.Equal(t, wantScore, *got.Score)
+
+	mergedHist, err := hdrhistogram.Decode([]byte(got.HDRHistogram))
+	require.NoError(t, err)
+	assert.True(t, want.Equals(mergedHist))
+
+	single := merged["1.1.1.1:53"]
+	assert.Equal(t, int64(1), single.TotalRequests)
+	assert.Equal(t, 1, single.MergedResults)
+	assert.Nil(t, single.LatencyDistribution)
+}
+
+func TestMergeResults_differentPrecision(t *testing.T) {
+	res1 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {TotalRequests: 1, BenchmarkDurationSeconds: 1, HDRHistogram: encodeHistogram(latencyHistogram(t, 1, 1234*time.Microsecond))},
+	})
+	res2 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {TotalRequests: 1, BenchmarkDurationSeconds: 1, HDRHistogram: encodeHistogram(latencyHistogram(t, 3, 1234*time.Microsecond))},
+	})
+
+	merged, err := mergeResults(res1, res2)
+	require.NoError(t, err)
+	hist, err := hdrhistogram.Decode([]byte(merged["8.8.8.8:53"].HDRHistogram))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), hist.SignificantFigures())
+	assert.Equal(t, int64(2), hist.TotalCount())
+	assert.Equal(t, latencyHistogram(t, 3, 1234*time.Microsecond).Max(), hist.Max())
+}
+
+func TestMergeResults_invalid(t *testing.T) {
+	valid := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {TotalRequests: 1, HDRHistogram: encodeHistogram(latencyHistogram(t, 3, time.Millisecond))},
+	})
+	tests := []struct {
+		name    string
+		results [][]byte
+	}{
+		{name: "no results"},
+		{name: "invalid JSON", results: [][]byte{valid, []byte("{")}},
+		{name: "missing histogram", results: [][]byte{valid, marshalResults(t, multiServerResult{"8.8.8.8:53": {TotalRequests: 1}})}},
+		{name: "invalid histogram", results: [][]byte{valid, marshalResults(t, multiServerResult{"8.8.8.8:53": {HDRHistogram: "invalid"}})}},
+		{name: "different config", results: [][]byte{valid, marshalResults(t, multiServerResult{
+			"8.8.8.8:53": {TotalRequests: 1, HDRHistogram: encodeHistogram(latencyHistogram(t, 3, time.Millisecond)), Config: map[string]interface{}{"concurrency": "2"}},
+		})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mergeResults(tt.results...)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestMergeResults_differentConfig(t *testing.T) {
+	hist := encodeHistogram(latencyHistogram(t, 3, time.Millisecond))
+	res1 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {TotalRequests: 1, HDRHistogram: hist, Config: map[string]interface{}{"concurrency": "2", "type": []string{"A"}, "server": "8.8.8.8"}},
+	})
+	res2 := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {TotalRequests: 1, HDRHistogram: hist, Config: map[string]interface{}{"concurrency": "4", "type": []string{"A", "AAAA"}, "server": "8.8.8.8", "recurse": "false"}},
+	})
+
+	_, err := mergeResults(res1, res2)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "result 2 was created with different config than result 1 (concurrency, recurse, type)")
+}
+
+func TestPrintMergedResults(t *testing.T) {
+	merged := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:            10,
+			TotalSuccessResponses:    10,
+			QueriesPerSecond:         5,
+			BenchmarkDurationSeconds: 2,
+			LatencyStats:             latencyStats{P99Ms: 12},
+			Score:                    &scoring.ScoreResult{Total: 95},
+			MergedResults:            2,
+		},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, PrintMergedResults(&buf, merged))
+	out := buf.String()
+	assert.Contains(t, out, "Merged results of 8.8.8.8:53 (2 results)")
+	assert.Regexp(t, `p99\s+\|\s+12ms`, out)
+	assert.Regexp(t, `qps\s+\|\s+5.00`, out)
+	assert.Regexp(t, `score\s+\|\s+95.00`, out)
+}
+
+func TestEvaluateMergedAssertions(t *testing.T) {
+	merged := marshalResults(t, multiServerResult{
+		"8.8.8.8:53": {
+			TotalRequests:            4,
+			TotalSuccessResponses:    3,
+			TotalIOErrors:            1,
+			BenchmarkDurationSeconds: 2,
+			HDRHistogram:             encodeHistogram(latencyHistogram(t, 3, 10*time.Millisecond, 20*time.Millisecond, 80*time.Millisecond)),
+		},
+		"1.1.1.1:53": {
+			TotalRequests:            2,
+			TotalSuccessResponses:    2,
+			BenchmarkDurationSeconds: 1,
+			HDRHistogram:             encodeHistogram(latencyHistogram(t, 3, 5*time.Millisecond, 6*time.Millisecond)),
+		},
+	})
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := dnsbench.Benchmark{FailConditions: []string{"ioerror"}, SLOs: []string{"p99<50ms"}}
+
+	got, err := EvaluateMergedAssertions(&b, merged, start)
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "1.1.1.1:53", got[0].Name)
+	assert.Equal(t, time.Second, got[0].Duration)
+	assert.True(t, got[0].Passed())
+	assert.Equal(t, "8.8.8.8:53", got[1].Name)
+	assert.Equal(t, start, got[1].Start)
+	assert.Equal(t, 2, got[1].Failures())
+	require.Len(t, got[1].SLO, 1)
+	assert.Equal(t, "p99 80.02ms is not < 50ms", got[1].SLO[0].Explanation)
+}
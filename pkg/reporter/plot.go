@@ -112,6 +112,31 @@ func plotResponses(file string, rcodes map[int]int64) {
 	}
 	sort.Ints(sortedKeys)
 
+	labels := make([]string, 0, len(sortedKeys))
+	values := make([]int64, 0, len(sortedKeys))
+	for _, v := range sortedKeys {
+		labels = append(labels, dns.RcodeToString[v])
+		values = append(values, rcodes[v])
+	}
+	plotBarChart(file, "Response code distribution", "Response codes", labels, values)
+}
+
+func plotExtendedErrors(file string, summaries []extendedErrorSummary) {
+	if len(summaries) == 0 {
+		// nothing to plot
+		return
+	}
+	labels := make([]string, 0, len(summaries))
+	values := make([]int64, 0, len(summaries))
+	for _, v := range summaries {
+		labels = append(labels, fmt.Sprintf("%s (%d)", v.Name, v.InfoCode))
+		values = append(values, v.Count)
+	}
+	plotBarChart(file, "Extended DNS error distribution", "Extended DNS errors", labels, values)
+}
+
+// plotBarChart plots bar for each of the values, the bars are described by the labels in the legend.
+func plotBarChart(file, title, nominal string, labels []string, values []int64) {
 	colors := []color.Color{
 		color.RGBA{R: 122, G: 195, B: 106, A: 255},
 		color.RGBA{R: 241, G: 90, B: 96, A: 255},
@@ -124,23 +149,21 @@ func plotResponses(file string, rcodes map[int]int64) {
 	colors = append(colors, plotutil.DarkColors...)
 
 	p := plot.New()
-	p.Title.Text = "Response code distribution"
-	p.NominalX("Response codes")
+	p.Title.Text = title
+	p.NominalX(nominal)
 
 	width := vg.Points(40)
 
-	c := 0
-	off := -vg.Length(len(rcodes)/2) * width
-	for _, v := range sortedKeys {
-		bar, err := plotter.NewBarChart(plotter.Values{float64(rcodes[v])}, width)
+	off := -vg.Length(len(values)/2) * width
+	for i, v := range values {
+		bar, err := plotter.NewBarChart(plotter.Values{float64(v)}, width)
 		if err != nil {
 			panic(err)
 		}
-		p.Legend.Add(dns.RcodeToString[v], bar)
-		bar.Color = colors[c%len(colors)]
+		p.Legend.Add(labels[i], bar)
+		bar.Color = colors[i%len(colors)]
 		bar.Offset = off
 		p.Add(bar)
-		c++
 		off += width
 	}
 
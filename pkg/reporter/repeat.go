@@ -0,0 +1,258 @@
+package reporter
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/olekukonko/tablewriter"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// ConfidenceLevel is the confidence level of the intervals reported for repeated benchmark runs.
+const ConfidenceLevel = 0.95
+
+// repeatedPercentiles are the latency percentiles summarized over the repeated benchmark runs.
+var repeatedPercentiles = []float64{50, 75, 90, 95, 99}
+
+// Trial represents results of a single run of the repeated benchmark.
+type Trial struct {
+	Stats    []*dnsbench.ResultStats
+	Start    time.Time
+	Duration time.Duration
+}
+
+// RepeatSummary represents metrics of the repeated benchmark runs with their confidence intervals.
+type RepeatSummary struct {
+	Trials          int     `json:"trials"`
+	ConfidenceLevel float64 `json:"confidenceLevel"`
+	Interleaved     bool    `json:"interleaved,omitempty"`
+	// Metrics are the latency percentiles (in milliseconds), qps and score of the trials with Student's t confidence intervals of their mean.
+	Metrics []TrialMetric `json:"metrics"`
+	// Bootstrap are the bootstrap confidence intervals of the latency percentiles (in milliseconds) of all the trials combined.
+	Bootstrap []BootstrapInterval `json:"bootstrap,omitempty"`
+
+	scores []scoring.ScoreResult
+}
+
+// TrialMetric represents values of a metric in the repeated benchmark runs.
+type TrialMetric struct {
+	Metric string    `json:"metric"`
+	Values []float64 `json:"values"`
+	Mean   float64   `json:"mean"`
+	StdDev float64   `json:"stdDev"`
+	Low    float64   `json:"low"`
+	High   float64   `json:"high"`
+
+	unit metricUnit
+}
+
+// BootstrapInterval represents bootstrap confidence interval of a latency percentile.
+type BootstrapInterval struct {
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+}
+
+// Metric returns the summarized metric by its name (p50, p75, p90, p95, p99, qps or score).
+func (s RepeatSummary) Metric(name string) (TrialMetric, bool) {
+	for _, m := range s.Metrics {
+		if m.Metric == name {
+			return m, true
+		}
+	}
+	return TrialMetric{}, false
+}
+
+// Score returns the mean of the trial scores with confidence interval of the total score. Each component of the score is
+// the mean of the component over the trials, so the total score is consistent with its breakdown.
+func (s RepeatSummary) Score() scoring.ScoreResult {
+	var score scoring.ScoreResult
+	if len(s.scores) == 0 {
+		return score
+	}
+	for _, sc := range s.scores {
+		score.Total += sc.Total
+		score.SuccessRate += sc.SuccessRate
+		score.ErrorRate += sc.ErrorRate
+		score.Latency += sc.Latency
+		score.QPS += sc.QPS
+	}
+	n := float64(len(s.scores))
+	score.Total /= n
+	score.SuccessRate /= n
+	score.ErrorRate /= n
+	score.Latency /= n
+	score.QPS /= n
+	if m, ok := s.Metric("score"); ok {
+		score.ConfidenceInterval = &scoring.Interval{Low: m.Low, High: m.High}
+	}
+	return score
+}
+
+// MergeTrials returns the results of all the trials, which can be reported as a single benchmark run, and the total duration of the trials.
+func MergeTrials(trials []Trial) ([]*dnsbench.ResultStats, time.Duration) {
+	var stats []*dnsbench.ResultStats
+	var duration time.Duration
+	for _, t := range trials {
+		stats = append(stats, t.Stats...)
+		duration += t.Duration
+	}
+	return stats, duration
+}
+
+// SummarizeTrials computes the metrics of the trials and their confidence intervals.
+func SummarizeTrials(b *dnsbench.Benchmark, trials []Trial) RepeatSummary {
+	res := RepeatSummary{Trials: len(trials), ConfidenceLevel: ConfidenceLevel, Interleaved: b.Interleave}
+
+	values := make(map[string][]float64)
+	for _, t := range trials {
+		stats := Merge(b, t.Stats)
+		for _, q := range repeatedPercentiles {
+			values[percentileName(q)] = append(values[percentileName(q)], durationMs(stats.Hist.ValueAtQuantile(q)))
+		}
+		values["qps"] = append(values["qps"], queriesPerSecond(stats.Counters, t.Duration))
+		score := scoring.CalculateScore(scoreMetrics(stats.Counters, stats.Hist, t.Duration))
+		res.scores = append(res.scores, score)
+		values["score"] = append(values["score"], score.Total)
+	}
+	for _, q := range repeatedPercentiles {
+		res.Metrics = append(res.Metrics, newTrialMetric(percentileName(q), millisecondsUnit, values[percentileName(q)]))
+	}
+	res.Metrics = append(res.Metrics, newTrialMetric("qps", numberUnit, values["qps"]))
+	res.Metrics = append(res.Metrics, newTrialMetric("score", numberUnit, values["score"]))
+
+	all, _ := MergeTrials(trials)
+	hist := Merge(b, all).Hist
+	for _, q := range repeatedPercentiles {
+		if ci, ok := bootstrapPercentile(hist, q, ConfidenceLevel); ok {
+			res.Bootstrap = append(res.Bootstrap, ci)
+		}
+	}
+	return res
+}
+
+func percentileName(q float64) string {
+	return "p" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func durationMs(v int64) float64 {
+	return float64(v) / float64(time.Millisecond)
+}
+
+// newTrialMetric computes mean of the values and its confidence interval using Student's t-distribution.
+func newTrialMetric(name string, unit metricUnit, values []float64) TrialMetric {
+	m := TrialMetric{Metric: name, Values: values, unit: unit}
+	n := float64(len(values))
+	if n == 0 {
+		return m
+	}
+	for _, v := range values {
+		m.Mean += v
+	}
+	m.Mean /= n
+	m.Low, m.High = m.Mean, m.Mean
+	if n < 2 {
+		return m
+	}
+	var sum float64
+	for _, v := range values {
+		sum += (v - m.Mean) * (v - m.Mean)
+	}
+	m.StdDev = math.Sqrt(sum / (n - 1))
+	t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 1}.Quantile(1 - (1-ConfidenceLevel)/2)
+	margin := t * m.StdDev / math.Sqrt(n)
+	m.Low, m.High = m.Mean-margin, m.Mean+margin
+	return m
+}
+
+// bootstrapPercentile computes bootstrap confidence interval of the latency percentile from the histogram. The bootstrap
+// distribution of the percentile is computed exactly instead of resampling, the percentile of a resample of n latencies is
+// at most x, when at least k of the resampled latencies are at most x, where k is the rank of the percentile, and the number
+// of such latencies is binomial with probability of the histogram CDF at x.
+func bootstrapPercentile(hist *hdrhistogram.Histogram, q, level float64) (BootstrapInterval, bool) {
+	n := hist.TotalCount()
+	if n == 0 {
+		return BootstrapInterval{}, false
+	}
+	type point struct {
+		value      int64
+		cumulative int64
+	}
+	var points []point
+	var cumulative int64
+	for _, bar := range hist.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		cumulative += bar.Count
+		points = append(points, point{value: bar.To, cumulative: cumulative})
+	}
+
+	k := int64(math.Ceil(q / 100 * float64(n)))
+	if k < 1 {
+		k = 1
+	}
+	// probability, that the percentile of a resample is at most the value of the point
+	atMost := func(p point) float64 {
+		if p.cumulative >= n {
+			return 1
+		}
+		return 1 - distuv.Binomial{N: float64(n), P: float64(p.cumulative) / float64(n)}.CDF(float64(k-1))
+	}
+	quantileOf := func(prob float64) int64 {
+		i := sort.Search(len(points), func(i int) bool { return atMost(points[i]) >= prob })
+		if i == len(points) {
+			i = len(points) - 1
+		}
+		return points[i].value
+	}
+
+	alpha := 1 - level
+	return BootstrapInterval{
+		Metric: percentileName(q),
+		Value:  durationMs(hist.ValueAtQuantile(q)),
+		Low:    durationMs(quantileOf(alpha / 2)),
+		High:   durationMs(quantileOf(1 - alpha/2)),
+	}, true
+}
+
+func printRepeatSummary(w io.Writer, s *RepeatSummary) {
+	order := "sequential"
+	if s.Interleaved {
+		order = "interleaved"
+	}
+	printutils.NeutralFprintf(w, "\nRepeated trials (%s %s trials, %s confidence intervals):\n",
+		printutils.HighlightSprint(s.Trials), order, printutils.HighlightSprint(strconv.FormatFloat(s.ConfidenceLevel*100, 'f', -1, 64)+"%"))
+
+	bootstrap := make(map[string]BootstrapInterval)
+	for _, b := range s.Bootstrap {
+		bootstrap[b.Metric] = b
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Metric", "Mean", "StdDev", "Confidence interval", "Bootstrap interval"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, m := range s.Metrics {
+		bootstrapCI := "-"
+		if b, ok := bootstrap[m.Metric]; ok {
+			bootstrapCI = formatMetricValue(millisecondsUnit, b.Low) + " - " + formatMetricValue(millisecondsUnit, b.High)
+		}
+		table.Append([]string{
+			m.Metric,
+			formatMetricValue(m.unit, m.Mean),
+			formatMetricValue(m.unit, m.StdDev),
+			formatMetricValue(m.unit, m.Low) + " - " + formatMetricValue(m.unit, m.High),
+			bootstrapCI,
+		})
+	}
+	table.Render()
+}
@@ -0,0 +1,90 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+func Test_newTrialMetric(t *testing.T) {
+	got := newTrialMetric("p99", millisecondsUnit, []float64{10, 12, 14})
+
+	assert.Equal(t, "p99", got.Metric)
+	assert.InDelta(t, 12, got.Mean, 0.0001)
+	assert.InDelta(t, 2, got.StdDev, 0.0001)
+	assert.InDelta(t, 7.032, got.Low, 0.001)
+	assert.InDelta(t, 16.968, got.High, 0.001)
+
+	single := newTrialMetric("qps", numberUnit, []float64{100})
+	assert.Equal(t, TrialMetric{Metric: "qps", Values: []float64{100}, Mean: 100, Low: 100, High: 100, unit: numberUnit}, single)
+}
+
+func Test_bootstrapPercentile(t *testing.T) {
+	hist := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+	for i := 1; i <= 1000; i++ {
+		require.NoError(t, hist.RecordValue((time.Duration(i) * time.Millisecond).Nanoseconds()))
+	}
+
+	got, ok := bootstrapPercentile(hist, 50, 0.95)
+
+	require.True(t, ok)
+	assert.Equal(t, "p50", got.Metric)
+	assert.InDelta(t, 500, got.Value, 1)
+	// the rank of the resampled median is binomial with standard deviation ~15.8 samples
+	assert.InDelta(t, 469, got.Low, 3)
+	assert.InDelta(t, 531, got.High, 3)
+
+	_, ok = bootstrapPercentile(hdrhistogram.New(0, time.Second.Nanoseconds(), 3), 50, 0.95)
+	assert.False(t, ok)
+}
+
+func TestSummarizeTrials(t *testing.T) {
+	b := dnsbench.Benchmark{HistMin: 0, HistMax: time.Second, HistPre: 3}
+	trial := func(latency time.Duration, requests int) Trial {
+		hist := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+		for i := 0; i < requests; i++ {
+			require.NoError(t, hist.RecordValue(latency.Nanoseconds()))
+		}
+		return Trial{
+			Stats:    []*dnsbench.ResultStats{{Hist: hist, Counters: &dnsbench.Counters{Total: int64(requests), Success: int64(requests)}}},
+			Duration: time.Second,
+		}
+	}
+	trials := []Trial{trial(10*time.Millisecond, 100), trial(20*time.Millisecond, 200), trial(30*time.Millisecond, 300)}
+
+	got := SummarizeTrials(&b, trials)
+
+	assert.Equal(t, 3, got.Trials)
+	assert.Equal(t, ConfidenceLevel, got.ConfidenceLevel)
+	p50, ok := got.Metric("p50")
+	require.True(t, ok)
+	assert.InDeltaSlice(t, []float64{10, 20, 30}, p50.Values, 0.05)
+	assert.InDelta(t, 20, p50.Mean, 0.05)
+	assert.Less(t, p50.Low, 20.0)
+	assert.Greater(t, p50.High, 20.0)
+	qps, ok := got.Metric("qps")
+	require.True(t, ok)
+	assert.Equal(t, []float64{100, 200, 300}, qps.Values)
+	_, ok = got.Metric("score")
+	assert.True(t, ok)
+	require.Len(t, got.Bootstrap, len(repeatedPercentiles))
+	assert.Equal(t, "p50", got.Bootstrap[0].Metric)
+
+	stats, duration := MergeTrials(trials)
+	assert.Len(t, stats, 3)
+	assert.Equal(t, 3*time.Second, duration)
+
+	// the total score is the mean of the trial scores and it is consistent with the mean breakdown
+	score := got.Score()
+	scoreMetric, _ := got.Metric("score")
+	assert.InDelta(t, scoreMetric.Mean, score.Total, 1e-9)
+	assert.InDelta(t, (score.SuccessRate*scoring.SuccessRateScoreWeight+score.ErrorRate*scoring.ErrorRateScoreWeight+
+		score.Latency*scoring.LatencyScoreWeight+score.QPS*scoring.QPSScoreWeight)/100, score.Total, 1e-9)
+	require.NotNil(t, score.ConfidenceInterval)
+	assert.Equal(t, scoring.Interval{Low: scoreMetric.Low, High: scoreMetric.High}, *score.ConfidenceInterval)
+}
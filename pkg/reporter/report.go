@@ -1,6 +1,7 @@
 package reporter
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/faultproxy"
 )
 
 type orderedMap struct {
@@ -27,6 +29,25 @@ type reportParameters struct {
 	authenticatedDomains      map[string]struct{}
 	benchmarkDuration         time.Duration
 	dohResponseStatusesTotals map[int]int64
+	tlsSummary                *tlsSummary
+	dohConnections            *dohConnectionsSummary
+	tcFallback                *tcFallbackSummary
+	tcFallbackHist            *hdrhistogram.Histogram
+	retries                   *retriesSummary
+	firstAttemptHist          *hdrhistogram.Histogram
+	malformedReactions        malformedSummary
+	ecs                       *ecsSummary
+	nsids                     map[string]int64
+	extendedErrors            []extendedErrorSummary
+	instances                 *instancesSummary
+	instanceStats             map[string]*dnsbench.InstanceStats
+	dnssec                    *dnssecSummary
+	dnssecStats               *dnsbench.DNSSECValidationStats
+	faults                    *faultsSummary
+	faultStats                *faultproxy.Stats
+	slo                       []ThresholdResult
+	assertions                AssertionSuite
+	repeat                    *RepeatSummary
 	geocode                   string // 添加地区信息字段
 }
 
@@ -35,8 +56,26 @@ type reportPrinter interface {
 }
 
 // PrintReport prints formatted benchmark result to stdout, exports graphs and generates CSV output if configured.
-// If there is a fatal error while printing report, an error is returned.
-func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStart time.Time, benchDuration time.Duration, geocode string) error {
+// The fail conditions and SLOs of the benchmark are evaluated for the report and returned, so the caller can decide on the exit code
+// without evaluating them again. If there is a fatal error while printing report, an error is returned.
+func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStart time.Time, benchDuration time.Duration, geocode string) (AssertionSuite, error) {
+	return printReport(b, stats, benchStart, benchDuration, geocode, nil)
+}
+
+// PrintRepeatedReport prints results of the repeated benchmark runs as a single benchmark run extended by the confidence intervals
+// of the metrics computed from the trials. The evaluated fail conditions and SLOs are returned the same way as by PrintReport.
+func PrintRepeatedReport(b *dnsbench.Benchmark, trials []Trial, geocode string) (AssertionSuite, error) {
+	if len(trials) == 0 {
+		return AssertionSuite{}, errors.New("no trials to report")
+	}
+	stats, duration := MergeTrials(trials)
+	summary := SummarizeTrials(b, trials)
+	return printReport(b, stats, trials[0].Start, duration, geocode, &summary)
+}
+
+func printReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStart time.Time, benchDuration time.Duration, geocode string,
+	repeat *RepeatSummary,
+) (AssertionSuite, error) {
 	totals := Merge(b, stats)
 
 	top3errs := make(map[string]int)
@@ -59,17 +98,18 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 
 	if len(b.PlotDir) != 0 {
 		if err := directoryExists(b.PlotDir); err != nil {
-			return fmt.Errorf("unable to plot results: %w", err)
+			return AssertionSuite{}, fmt.Errorf("unable to plot results: %w", err)
 		}
 
 		now := time.Now().Format("2006-01-02T15-04-05")
 		dir := filepath.Join(b.PlotDir, fmt.Sprintf("graphs-%s", now))
 		if err := os.Mkdir(dir, os.ModePerm); err != nil {
-			return fmt.Errorf("unable to plot results: %w", err)
+			return AssertionSuite{}, fmt.Errorf("unable to plot results: %w", err)
 		}
 		plotHistogramLatency(fileName(b, dir, "latency-histogram"), totals.Timings)
 		plotBoxPlotLatency(fileName(b, dir, "latency-boxplot"), b.Server, totals.Timings)
 		plotResponses(fileName(b, dir, "responses-barchart"), totals.Codes)
+		plotExtendedErrors(fileName(b, dir, "extended-errors-barchart"), summarizeExtendedErrors(totals.ExtendedErrors))
 		plotLineThroughput(fileName(b, dir, "throughput-lineplot"), benchStart, totals.Timings)
 		plotLineLatencies(fileName(b, dir, "latency-lineplot"), benchStart, totals.Timings)
 		plotErrorRate(fileName(b, dir, "errorrate-lineplot"), benchStart, totals.Errors)
@@ -79,7 +119,7 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 	if b.Csv != "" {
 		f, err := os.Create(b.Csv)
 		if err != nil {
-			return fmt.Errorf("failed to create file for CSV export due to '%v'", err)
+			return AssertionSuite{}, fmt.Errorf("failed to create file for CSV export due to '%v'", err)
 		}
 
 		csv = f
@@ -95,8 +135,18 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 		writeBars(csv, totals.Hist.Distribution())
 	}
 
+	assertions, err := EvaluateAssertions(b, totals, benchStart, benchDuration)
+	if err != nil {
+		return AssertionSuite{}, err
+	}
+	if len(b.JUnit) != 0 {
+		if err := writeJUnitFile(b.JUnit, []AssertionSuite{assertions}); err != nil {
+			return AssertionSuite{}, fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+	}
+
 	if b.Silent {
-		return nil
+		return assertions, nil
 	}
 	topErrs := orderedMap{m: top3errs, order: top3errorsInOrder}
 	params := reportParameters{
@@ -110,9 +160,28 @@ func PrintReport(b *dnsbench.Benchmark, stats []*dnsbench.ResultStats, benchStar
 		authenticatedDomains:      totals.AuthenticatedDomains,
 		benchmarkDuration:         benchDuration,
 		dohResponseStatusesTotals: totals.DoHStatusCodes,
+		tlsSummary:                summarizeTLS(totals.TLS, time.Now()),
+		dohConnections:            summarizeDoHConnections(totals.DoHConnections),
+		tcFallback:                summarizeTCFallback(totals.Counters, totals.TCFallbackHist),
+		tcFallbackHist:            totals.TCFallbackHist,
+		retries:                   summarizeRetries(totals.Counters, totals.Attempts, totals.FirstAttemptHist),
+		firstAttemptHist:          totals.FirstAttemptHist,
+		malformedReactions:        totals.MalformedReactions,
+		ecs:                       summarizeECS(totals.ECS, totals.ECSAnswers),
+		nsids:                     totals.NSIDs,
+		extendedErrors:            summarizeExtendedErrors(totals.ExtendedErrors),
+		instances:                 summarizeInstances(totals.Counters, totals.Instances),
+		instanceStats:             totals.Instances,
+		dnssec:                    summarizeDNSSEC(totals.DNSSECValidation),
+		dnssecStats:               totals.DNSSECValidation,
+		faults:                    summarizeFaults(totals.Counters, totals.InjectedFaults),
+		faultStats:                totals.InjectedFaults,
+		slo:                       assertions.SLO,
+		assertions:                assertions,
+		repeat:                    repeat,
 		geocode:                   geocode, // 添加地区信息
 	}
-	return printer(b).print(params)
+	return assertions, printer(b).print(params)
 }
 
 func directoryExists(plotDir string) error {
@@ -130,8 +199,12 @@ func directoryExists(plotDir string) error {
 
 func printer(b *dnsbench.Benchmark) reportPrinter {
 	switch {
-	case b.JSON:
+	case b.JSON || b.Format == dnsbench.FormatJSON:
 		return &jsonReporter{}
+	case b.Format == dnsbench.FormatJUnit:
+		return &junitReporter{}
+	case b.Format == dnsbench.FormatTAP:
+		return &tapReporter{}
 	default:
 		return &standardReporter{}
 	}
@@ -0,0 +1,53 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type retriesSummary struct {
+	Retried int64 `json:"retried"`
+	// Attempts maps number of attempts to the number of queries, which needed that many attempts.
+	Attempts                 map[int]int64 `json:"attempts"`
+	FirstAttemptLatencyStats latencyStats  `json:"firstAttemptLatencyStats"`
+}
+
+// summarizeRetries aggregates attempts of the queries, nil is returned if retries were not enabled.
+func summarizeRetries(c dnsbench.Counters, attempts map[int]int64, firstAttemptHist *hdrhistogram.Histogram) *retriesSummary {
+	if attempts == nil || firstAttemptHist == nil {
+		return nil
+	}
+	return &retriesSummary{
+		Retried:                  c.Retried,
+		Attempts:                 attempts,
+		FirstAttemptLatencyStats: newLatencyStats(firstAttemptHist),
+	}
+}
+
+func printRetries(w io.Writer, summary *retriesSummary, firstAttemptHist *hdrhistogram.Histogram) {
+	printutils.NeutralFprintf(w, "\nRetried queries:\t%s\n", printutils.HighlightSprint(summary.Retried))
+
+	attempts := make([]int, 0, len(summary.Attempts))
+	for k := range summary.Attempts {
+		attempts = append(attempts, k)
+	}
+	sort.Ints(attempts)
+	printutils.NeutralFprintf(w, "Attempts per query:\n")
+	for _, a := range attempts {
+		printutils.NeutralFprintf(w, "\t%d:\t%d\n", a, summary.Attempts[a])
+	}
+
+	if tc := firstAttemptHist.TotalCount(); tc > 0 {
+		printutils.NeutralFprintf(w, "DNS timings of first attempts, %s datapoints\n", printutils.HighlightSprint(tc))
+		printutils.NeutralFprintf(w, "\t min:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(firstAttemptHist.Min()))))
+		printutils.NeutralFprintf(w, "\t mean:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(firstAttemptHist.Mean()))))
+		printutils.NeutralFprintf(w, "\t max:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(firstAttemptHist.Max()))))
+		printutils.NeutralFprintf(w, "\t p99:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(firstAttemptHist.ValueAtQuantile(99)))))
+		printutils.NeutralFprintf(w, "\t p50:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(firstAttemptHist.ValueAtQuantile(50)))))
+	}
+}
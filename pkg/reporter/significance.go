@@ -0,0 +1,155 @@
+package reporter
+
+import (
+	"math"
+	"sort"
+)
+
+// SignificanceTest represents results of the statistical tests comparing latency distributions of two benchmark runs.
+type SignificanceTest struct {
+	// BaselineSamples and CurrentSamples are the numbers of latency samples in the compared distributions.
+	BaselineSamples int64 `json:"baselineSamples"`
+	CurrentSamples  int64 `json:"currentSamples"`
+	// MannWhitneyZ is z-score of Mann-Whitney U test corrected for ties, positive value means the current latencies tend to be higher.
+	MannWhitneyZ float64 `json:"mannWhitneyZ"`
+	// MannWhitneyP is two-sided p-value of Mann-Whitney U test.
+	MannWhitneyP float64 `json:"mannWhitneyP"`
+	// ProbabilitySlower is the probability, that randomly chosen current latency is higher than randomly chosen baseline latency
+	// (ties count as half), 0.5 means no shift.
+	ProbabilitySlower float64 `json:"probabilitySlower"`
+	// KolmogorovSmirnovD is the maximal distance between the empirical distribution functions.
+	KolmogorovSmirnovD float64 `json:"kolmogorovSmirnovD"`
+	// KolmogorovSmirnovP is asymptotic p-value of two-sample Kolmogorov-Smirnov test.
+	KolmogorovSmirnovP float64 `json:"kolmogorovSmirnovP"`
+	Alpha              float64 `json:"alpha"`
+	// Significant is true, when Mann-Whitney U test rejects the hypothesis, that the latencies are not shifted, at Alpha level.
+	Significant bool `json:"significant"`
+	// Slower is true, when the current latencies are significantly higher than the baseline latencies.
+	Slower bool `json:"slower"`
+}
+
+// latencyBin is the number of latency samples with the same value.
+type latencyBin struct {
+	value float64
+	count int64
+}
+
+// latencyBins converts latency distribution of the JSON output to sorted bins.
+func latencyBins(dist []histogramPoint) []latencyBin {
+	bins := make([]latencyBin, 0, len(dist))
+	for _, p := range dist {
+		if p.Count > 0 {
+			bins = append(bins, latencyBin{value: float64(p.LatencyMs), count: p.Count})
+		}
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].value < bins[j].value })
+	return bins
+}
+
+// latencyBinsUs converts latency distribution in microseconds of the JSON output to sorted bins.
+func latencyBinsUs(dist []histogramPointUs) []latencyBin {
+	bins := make([]latencyBin, 0, len(dist))
+	for _, p := range dist {
+		if p.Count > 0 {
+			bins = append(bins, latencyBin{value: p.LatencyUs, count: p.Count})
+		}
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].value < bins[j].value })
+	return bins
+}
+
+// testSignificance compares the binned latency distributions using Mann-Whitney U test and two-sample Kolmogorov-Smirnov test.
+// The distributions of the JSON output are binned (to milliseconds in schema version 1 or to the buckets of HDR histogram),
+// so there are a lot of ties, which are handled by both tests.
+// Returns nil, when any of the distributions is empty.
+func testSignificance(baseline, current []latencyBin, alpha float64) *SignificanceTest {
+	var n1, n2 int64
+	for _, b := range baseline {
+		n1 += b.count
+	}
+	for _, b := range current {
+		n2 += b.count
+	}
+	if n1 == 0 || n2 == 0 {
+		return nil
+	}
+	res := &SignificanceTest{BaselineSamples: n1, CurrentSamples: n2, Alpha: alpha}
+
+	// merge the bins, so each distinct value has counts of both distributions
+	type mergedBin struct {
+		baseline, current int64
+	}
+	var values []float64
+	merged := make(map[float64]*mergedBin)
+	for _, b := range baseline {
+		if merged[b.value] == nil {
+			merged[b.value] = &mergedBin{}
+			values = append(values, b.value)
+		}
+		merged[b.value].baseline += b.count
+	}
+	for _, b := range current {
+		if merged[b.value] == nil {
+			merged[b.value] = &mergedBin{}
+			values = append(values, b.value)
+		}
+		merged[b.value].current += b.count
+	}
+	sort.Float64s(values)
+
+	fn1, fn2 := float64(n1), float64(n2)
+	n := fn1 + fn2
+	var rankSum, tieSum, rank, cdf1, cdf2, maxDistance float64
+	for _, v := range values {
+		b := merged[v]
+		t := float64(b.baseline + b.current)
+		// tied values get the average of the ranks they occupy
+		avgRank := rank + (t+1)/2
+		rankSum += float64(b.current) * avgRank
+		tieSum += t*t*t - t
+		rank += t
+
+		cdf1 += float64(b.baseline) / fn1
+		cdf2 += float64(b.current) / fn2
+		maxDistance = math.Max(maxDistance, math.Abs(cdf1-cdf2))
+	}
+
+	u := rankSum - fn2*(fn2+1)/2
+	mean := fn1 * fn2 / 2
+	variance := fn1 * fn2 / 12 * ((n + 1) - tieSum/(n*(n-1)))
+	res.ProbabilitySlower = u / (fn1 * fn2)
+	if variance > 0 {
+		res.MannWhitneyZ = (u - mean) / math.Sqrt(variance)
+		res.MannWhitneyP = math.Erfc(math.Abs(res.MannWhitneyZ) / math.Sqrt2)
+	} else {
+		// all the samples are the same
+		res.MannWhitneyP = 1
+	}
+
+	res.KolmogorovSmirnovD = maxDistance
+	en := math.Sqrt(fn1 * fn2 / n)
+	res.KolmogorovSmirnovP = kolmogorovQ((en + 0.12 + 0.11/en) * maxDistance)
+
+	res.Significant = res.MannWhitneyP < alpha
+	res.Slower = res.Significant && res.MannWhitneyZ > 0
+	return res
+}
+
+// kolmogorovQ returns the complementary cumulative distribution function of Kolmogorov distribution.
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 0.2 {
+		return 1
+	}
+	var sum, prev float64
+	sign := 1.0
+	for j := 1; j <= 100; j++ {
+		term := sign * 2 * math.Exp(-2*float64(j*j)*lambda*lambda)
+		sum += term
+		if math.Abs(term) <= 1e-10*prev || math.Abs(term) <= 1e-16*sum {
+			return math.Max(0, math.Min(1, sum))
+		}
+		sign = -sign
+		prev = math.Abs(term)
+	}
+	return 1
+}
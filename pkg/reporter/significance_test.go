@@ -0,0 +1,70 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_testSignificance(t *testing.T) {
+	t.Run("shifted distributions", func(t *testing.T) {
+		baseline := []latencyBin{{value: 1, count: 1}, {value: 2, count: 1}, {value: 3, count: 1}}
+		current := []latencyBin{{value: 4, count: 1}, {value: 5, count: 1}, {value: 6, count: 1}}
+
+		got := testSignificance(baseline, current, 0.05)
+
+		require.NotNil(t, got)
+		assert.Equal(t, int64(3), got.BaselineSamples)
+		assert.Equal(t, int64(3), got.CurrentSamples)
+		assert.InDelta(t, 1.964, got.MannWhitneyZ, 0.001)
+		assert.InDelta(t, 0.0495, got.MannWhitneyP, 0.0001)
+		assert.InDelta(t, 1, got.ProbabilitySlower, 0.0001)
+		assert.InDelta(t, 1, got.KolmogorovSmirnovD, 0.0001)
+		assert.InDelta(t, 0.0326, got.KolmogorovSmirnovP, 0.001)
+		assert.True(t, got.Significant)
+		assert.True(t, got.Slower)
+	})
+
+	t.Run("same distributions with ties", func(t *testing.T) {
+		bins := []latencyBin{{value: 10, count: 500}, {value: 11, count: 300}, {value: 20, count: 10}}
+
+		got := testSignificance(bins, bins, 0.05)
+
+		require.NotNil(t, got)
+		assert.InDelta(t, 0, got.MannWhitneyZ, 0.0001)
+		assert.InDelta(t, 1, got.MannWhitneyP, 0.0001)
+		assert.InDelta(t, 0.5, got.ProbabilitySlower, 0.0001)
+		assert.InDelta(t, 0, got.KolmogorovSmirnovD, 0.0001)
+		assert.InDelta(t, 1, got.KolmogorovSmirnovP, 0.0001)
+		assert.False(t, got.Significant)
+		assert.False(t, got.Slower)
+	})
+
+	t.Run("faster with ties", func(t *testing.T) {
+		baseline := []latencyBin{{value: 10, count: 400}, {value: 11, count: 400}}
+		current := []latencyBin{{value: 9, count: 300}, {value: 10, count: 500}}
+
+		got := testSignificance(baseline, current, 0.05)
+
+		require.NotNil(t, got)
+		assert.Less(t, got.MannWhitneyZ, 0.0)
+		assert.Less(t, got.MannWhitneyP, 0.001)
+		assert.True(t, got.Significant)
+		assert.False(t, got.Slower)
+	})
+
+	t.Run("single value", func(t *testing.T) {
+		bins := []latencyBin{{value: 10, count: 5}}
+
+		got := testSignificance(bins, bins, 0.05)
+
+		require.NotNil(t, got)
+		assert.InDelta(t, 1, got.MannWhitneyP, 0.0001)
+		assert.False(t, got.Significant)
+	})
+
+	t.Run("empty distribution", func(t *testing.T) {
+		assert.Nil(t, testSignificance(nil, []latencyBin{{value: 10, count: 5}}, 0.05))
+	})
+}
@@ -52,6 +52,51 @@ func (s *standardReporter) print(params reportParameters) error {
 		}
 	}
 
+	if params.dohConnections != nil {
+		printDoHConnections(params.outputWriter, params.dohConnections)
+	}
+
+	if params.tcFallback != nil {
+		printTCFallback(params.outputWriter, params.tcFallback, params.tcFallbackHist)
+	}
+
+	if params.retries != nil {
+		printRetries(params.outputWriter, params.retries, params.firstAttemptHist)
+	}
+
+	if len(params.extendedErrors) > 0 {
+		printExtendedErrors(params.outputWriter, params.extendedErrors)
+	}
+
+	if len(params.nsids) > 0 {
+		printutils.NeutralFprintf(params.outputWriter, "\n")
+		printCounts(params.outputWriter, "NSID", params.nsids)
+	}
+
+	if params.faults != nil {
+		printFaults(params.outputWriter, params.faults, params.faultStats)
+	}
+
+	if params.dnssec != nil {
+		printDNSSEC(params.outputWriter, params.dnssec, params.dnssecStats)
+	}
+
+	if params.instances != nil {
+		printInstances(params.outputWriter, params.instances, params.instanceStats)
+	}
+
+	if params.ecs != nil {
+		printECSSummary(params.outputWriter, params.ecs)
+	}
+
+	if params.malformedReactions != nil {
+		printMalformedReactions(params.outputWriter, params.malformedReactions)
+	}
+
+	if params.tlsSummary != nil {
+		printTLSSummary(params.outputWriter, params.tlsSummary)
+	}
+
 	if len(params.qtypeTotals) > 0 {
 		printutils.NeutralFprintf(params.outputWriter, "\nDNS question types:\n")
 		for k, v := range params.qtypeTotals {
@@ -112,6 +157,14 @@ func (s *standardReporter) print(params reportParameters) error {
 		}
 	}
 
+	if params.repeat != nil {
+		printRepeatSummary(params.outputWriter, params.repeat)
+	}
+
+	if len(params.slo) > 0 {
+		PrintThresholds(params.outputWriter, params.slo)
+	}
+
 	return nil
 }
 
@@ -139,6 +192,10 @@ func printProgress(w io.Writer, c dnsbench.Counters) {
 	if c.Truncated > 0 {
 		printutils.ErrFprintf(w, "Truncated responses:\t%d\n", c.Truncated)
 	}
+
+	if c.OutOfOrder > 0 {
+		printutils.NeutralFprintf(w, "Out of order responses:\t%d\n", c.OutOfOrder)
+	}
 }
 
 func printBars(w io.Writer, bars []hdrhistogram.Bar) {
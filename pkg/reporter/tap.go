@@ -0,0 +1,74 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type tapReporter struct{}
+
+func (s *tapReporter) print(params reportParameters) error {
+	return WriteTAP(params.outputWriter, []AssertionSuite{params.assertions})
+}
+
+// WriteTAP writes the assertion suites in Test Anything Protocol version 13. Test points are the same as the test cases
+// of WriteJUnit, they are prefixed by the name of the suite and the failures are explained in YAML diagnostic blocks.
+func WriteTAP(w io.Writer, suites []AssertionSuite) error {
+	total := 0
+	for _, s := range suites {
+		total += s.Tests()
+	}
+	if _, err := fmt.Fprintf(w, "TAP version 13\n1..%d\n", total); err != nil {
+		return err
+	}
+
+	n := 0
+	for _, s := range suites {
+		n++
+		diag := map[string]string{"duration_ms": strconv.FormatInt(s.Duration.Milliseconds(), 10)}
+		if len(s.Error) != 0 {
+			diag["message"] = strconv.Quote(s.Error)
+		}
+		if err := writeTAPPoint(w, n, len(s.Error) == 0, s.Name+" "+benchmarkTestCase, diag); err != nil {
+			return err
+		}
+		for _, a := range s.Assertions {
+			n++
+			var diag map[string]string
+			if !a.Passed {
+				diag = map[string]string{"message": strconv.Quote(a.Message)}
+			}
+			if err := writeTAPPoint(w, n, a.Passed, s.Name+" "+a.Kind+" "+a.Name, diag); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeTAPPoint(w io.Writer, n int, ok bool, description string, diag map[string]string) error {
+	status := "ok"
+	if !ok {
+		status = "not ok"
+	}
+	if _, err := fmt.Fprintf(w, "%s %d - %s\n", status, n, description); err != nil {
+		return err
+	}
+	if len(diag) == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(w, "  ---\n"); err != nil {
+		return err
+	}
+	// keys are written in fixed order, so the output is stable
+	for _, k := range []string{"message", "duration_ms"} {
+		if v, ok := diag[k]; ok {
+			if _, err := fmt.Fprintf(w, "  %s: %s\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "  ...\n")
+	return err
+}
@@ -0,0 +1,34 @@
+package reporter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTAP(&buf, testAssertionSuites())
+
+	require.NoError(t, err)
+	assert.Equal(t, `TAP version 13
+1..5
+ok 1 - 8.8.8.8:53 benchmark
+  ---
+  duration_ms: 1500
+  ...
+ok 2 - 8.8.8.8:53 fail ioerror
+ok 3 - 8.8.8.8:53 slo p99<50ms
+not ok 4 - 8.8.8.8:53 slo qps>=20000
+  ---
+  message: "qps 1000 is not >= 20000"
+  ...
+not ok 5 - 1.1.1.1:53 benchmark
+  ---
+  message: "connection refused"
+  duration_ms: 0
+  ...
+`, buf.String())
+}
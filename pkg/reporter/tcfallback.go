@@ -0,0 +1,43 @@
+package reporter
+
+import (
+	"io"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+type tcFallbackSummary struct {
+	Queries      int64        `json:"queries"`
+	Errors       int64        `json:"errors"`
+	LatencyStats latencyStats `json:"latencyStats"`
+}
+
+// summarizeTCFallback aggregates queries retried over TCP after truncated UDP response, nil is returned if no query was retried.
+func summarizeTCFallback(c dnsbench.Counters, hist *hdrhistogram.Histogram) *tcFallbackSummary {
+	if c.TCFallback == 0 || hist == nil {
+		return nil
+	}
+	return &tcFallbackSummary{
+		Queries:      c.TCFallback,
+		Errors:       c.TCFallbackError,
+		LatencyStats: newLatencyStats(hist),
+	}
+}
+
+func printTCFallback(w io.Writer, summary *tcFallbackSummary, hist *hdrhistogram.Histogram) {
+	printutils.NeutralFprintf(w, "\nQueries retried over TCP:\t%s\n", printutils.HighlightSprint(summary.Queries))
+	if summary.Errors > 0 {
+		printutils.ErrFprintf(w, "Failed TCP retries:\t%d\n", summary.Errors)
+	}
+	if tc := hist.TotalCount(); tc > 0 {
+		printutils.NeutralFprintf(w, "DNS timings of queries retried over TCP, %s datapoints\n", printutils.HighlightSprint(tc))
+		printutils.NeutralFprintf(w, "\t min:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Min()))))
+		printutils.NeutralFprintf(w, "\t mean:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Mean()))))
+		printutils.NeutralFprintf(w, "\t max:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.Max()))))
+		printutils.NeutralFprintf(w, "\t p99:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.ValueAtQuantile(99)))))
+		printutils.NeutralFprintf(w, "\t p50:\t\t%s\n", printutils.HighlightSprint(roundDuration(time.Duration(hist.ValueAtQuantile(50)))))
+	}
+}
@@ -0,0 +1,240 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+	"github.com/tantalor93/dnspyre/v3/pkg/scoring"
+)
+
+// thresholdRegexp matches threshold expressions in format <metric><operator><value>, e.g. p99<50ms.
+var thresholdRegexp = regexp.MustCompile(`^\s*([a-z0-9_.]+)\s*(<=|>=|==|!=|<|>)\s*(\S+)\s*$`)
+
+// counterMetrics are the metrics evaluated against the counters of the benchmark, they can be used either as absolute counts
+// or as ratios to the total number of requests using _ratio suffix (e.g. ioerror_ratio).
+var counterMetrics = map[string]func(c dnsbench.Counters) int64{
+	"total":      func(c dnsbench.Counters) int64 { return c.Total },
+	"success":    func(c dnsbench.Counters) int64 { return c.Success },
+	"negative":   func(c dnsbench.Counters) int64 { return c.Negative },
+	"error":      func(c dnsbench.Counters) int64 { return c.Error },
+	"ioerror":    func(c dnsbench.Counters) int64 { return c.IOError },
+	"idmismatch": func(c dnsbench.Counters) int64 { return c.IDmismatch },
+	"truncated":  func(c dnsbench.Counters) int64 { return c.Truncated },
+}
+
+type metricKind int
+
+const (
+	latencyMetric metricKind = iota
+	ratioMetric
+	numberMetric
+)
+
+// Threshold represents assertion on a metric of the benchmark results, e.g. p99<50ms, ioerror_ratio<0.1%, qps>=20000 or score>=80.
+//
+// Supported metrics are latency percentiles (p50, p99, p99.9, ...), min, mean and max latency, which are compared to durations,
+// counters (total, success, negative, error, ioerror, idmismatch, truncated) and their ratios to the total number of requests
+// (e.g. error_ratio) compared to fractions or percents, qps and score.
+type Threshold struct {
+	// Expression is the original threshold expression.
+	Expression string
+	Metric     string
+	Operator   string
+	Value      float64
+
+	kind     metricKind
+	quantile float64
+}
+
+// ParseThreshold parses threshold expression in format <metric><operator><value>, supported operators are <, <=, >, >=, == and !=.
+func ParseThreshold(expr string) (Threshold, error) {
+	m := thresholdRegexp.FindStringSubmatch(strings.ToLower(expr))
+	if m == nil {
+		return Threshold{}, fmt.Errorf("threshold '%s' is not in format <metric><operator><value>, e.g. p99<50ms", expr)
+	}
+	t := Threshold{Expression: strings.TrimSpace(expr), Metric: m[1], Operator: m[2]}
+
+	switch {
+	case t.Metric == "min" || t.Metric == "mean" || t.Metric == "max":
+		t.kind = latencyMetric
+	case strings.HasPrefix(t.Metric, "p"):
+		q, err := strconv.ParseFloat(t.Metric[1:], 64)
+		if err != nil || q <= 0 || q > 100 {
+			return Threshold{}, fmt.Errorf("threshold '%s' uses invalid percentile '%s'", expr, t.Metric)
+		}
+		t.kind = latencyMetric
+		t.quantile = q
+	case strings.HasSuffix(t.Metric, "_ratio") && counterMetrics[strings.TrimSuffix(t.Metric, "_ratio")] != nil:
+		t.kind = ratioMetric
+	case t.Metric == "qps" || t.Metric == "score" || counterMetrics[t.Metric] != nil:
+		t.kind = numberMetric
+	default:
+		return Threshold{}, fmt.Errorf("threshold '%s' uses unknown metric '%s'", expr, t.Metric)
+	}
+
+	var err error
+	switch t.kind {
+	case latencyMetric:
+		var d time.Duration
+		d, err = time.ParseDuration(m[3])
+		t.Value = float64(d)
+	case ratioMetric:
+		if strings.HasSuffix(m[3], "%") {
+			t.Value, err = strconv.ParseFloat(strings.TrimSuffix(m[3], "%"), 64)
+			t.Value /= 100
+		} else {
+			t.Value, err = strconv.ParseFloat(m[3], 64)
+		}
+	default:
+		t.Value, err = strconv.ParseFloat(m[3], 64)
+	}
+	if err != nil {
+		return Threshold{}, fmt.Errorf("threshold '%s' has invalid value '%s'", expr, m[3])
+	}
+	return t, nil
+}
+
+// ParseThresholds parses all the threshold expressions.
+func ParseThresholds(exprs []string) ([]Threshold, error) {
+	thresholds := make([]Threshold, 0, len(exprs))
+	for _, expr := range exprs {
+		t, err := ParseThreshold(expr)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// ThresholdResult represents result of the Threshold evaluation.
+type ThresholdResult struct {
+	Threshold string `json:"threshold"`
+	// Actual is the formatted actual value of the metric.
+	Actual string `json:"actual"`
+	Passed bool   `json:"passed"`
+	// Explanation describes the result, e.g. "p99 62.5ms is not < 50ms".
+	Explanation string `json:"explanation"`
+}
+
+// EvaluateThresholds evaluates the thresholds against the merged benchmark results, benchDuration is used for computing QPS and score.
+func EvaluateThresholds(stats BenchmarkResultStats, benchDuration time.Duration, thresholds []Threshold) []ThresholdResult {
+	results := make([]ThresholdResult, 0, len(thresholds))
+	for _, t := range thresholds {
+		if t.needsLatencies() && (stats.Hist == nil || stats.Hist.TotalCount() == 0) {
+			// empty histogram yields zero latencies, which would make any upper bound on latency pass
+			results = append(results, ThresholdResult{
+				Threshold:   t.Expression,
+				Actual:      "n/a",
+				Explanation: fmt.Sprintf("%s cannot be evaluated, no successful responses", t.Metric),
+			})
+			continue
+		}
+		actual := t.actual(stats, benchDuration)
+		passed := t.compare(actual)
+		actualStr, expectedStr := t.format(actual), t.format(t.Value)
+		res := ThresholdResult{Threshold: t.Expression, Actual: actualStr, Passed: passed}
+		if passed {
+			res.Explanation = fmt.Sprintf("%s %s %s %s", t.Metric, actualStr, t.Operator, expectedStr)
+		} else {
+			res.Explanation = fmt.Sprintf("%s %s is not %s %s", t.Metric, actualStr, t.Operator, expectedStr)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// ThresholdsPassed returns true, if all the threshold results passed.
+func ThresholdsPassed(results []ThresholdResult) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// needsLatencies returns true, if the threshold metric is computed from the latency histogram.
+func (t Threshold) needsLatencies() bool {
+	return t.kind == latencyMetric || t.Metric == "score"
+}
+
+func (t Threshold) actual(stats BenchmarkResultStats, benchDuration time.Duration) float64 {
+	switch {
+	case t.Metric == "min":
+		return float64(stats.Hist.Min())
+	case t.Metric == "mean":
+		return stats.Hist.Mean()
+	case t.Metric == "max":
+		return float64(stats.Hist.Max())
+	case t.kind == latencyMetric:
+		return float64(stats.Hist.ValueAtQuantile(t.quantile))
+	case t.Metric == "qps":
+		return queriesPerSecond(stats.Counters, benchDuration)
+	case t.Metric == "score":
+		return scoring.CalculateScore(scoreMetrics(stats.Counters, stats.Hist, benchDuration)).Total
+	case t.kind == ratioMetric:
+		if stats.Counters.Total == 0 {
+			return 0
+		}
+		return float64(counterMetrics[strings.TrimSuffix(t.Metric, "_ratio")](stats.Counters)) / float64(stats.Counters.Total)
+	default:
+		return float64(counterMetrics[t.Metric](stats.Counters))
+	}
+}
+
+func (t Threshold) compare(actual float64) bool {
+	switch t.Operator {
+	case "<":
+		return actual < t.Value
+	case "<=":
+		return actual <= t.Value
+	case ">":
+		return actual > t.Value
+	case ">=":
+		return actual >= t.Value
+	case "==":
+		return actual == t.Value
+	default:
+		return actual != t.Value
+	}
+}
+
+func (t Threshold) format(v float64) string {
+	switch {
+	case t.kind == latencyMetric:
+		return roundDuration(time.Duration(v)).String()
+	case t.kind == ratioMetric:
+		return strconv.FormatFloat(v*100, 'f', -1, 64) + "%"
+	case t.Metric == "qps" || t.Metric == "score":
+		return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+}
+
+// PrintThresholds prints results of the thresholds evaluation followed by the overall verdict.
+func PrintThresholds(w io.Writer, results []ThresholdResult) {
+	printutils.NeutralFprintf(w, "\nSLO thresholds:\n")
+	failed := 0
+	for _, r := range results {
+		if r.Passed {
+			printutils.SuccessFprintf(w, "\tPASS\t%s\n", r.Explanation)
+		} else {
+			failed++
+			printutils.ErrFprintf(w, "\tFAIL\t%s\n", r.Explanation)
+		}
+	}
+	if failed == 0 {
+		printutils.SuccessFprintf(w, "SLO passed\n")
+	} else {
+		printutils.ErrFprintf(w, "SLO failed, %d of %d thresholds violated\n", failed, len(results))
+	}
+}
@@ -0,0 +1,89 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Threshold
+		wantErr bool
+	}{
+		{expr: "p99<50ms", want: Threshold{Expression: "p99<50ms", Metric: "p99", Operator: "<", Value: float64(50 * time.Millisecond), kind: latencyMetric, quantile: 99}},
+		{expr: "p99.9 <= 1s", want: Threshold{Expression: "p99.9 <= 1s", Metric: "p99.9", Operator: "<=", Value: float64(time.Second), kind: latencyMetric, quantile: 99.9}},
+		{expr: "mean<10ms", want: Threshold{Expression: "mean<10ms", Metric: "mean", Operator: "<", Value: float64(10 * time.Millisecond), kind: latencyMetric}},
+		{expr: "ioerror_ratio<0.1%", want: Threshold{Expression: "ioerror_ratio<0.1%", Metric: "ioerror_ratio", Operator: "<", Value: 0.001, kind: ratioMetric}},
+		{expr: "success_ratio>=0.99", want: Threshold{Expression: "success_ratio>=0.99", Metric: "success_ratio", Operator: ">=", Value: 0.99, kind: ratioMetric}},
+		{expr: "qps>=20000", want: Threshold{Expression: "qps>=20000", Metric: "qps", Operator: ">=", Value: 20000, kind: numberMetric}},
+		{expr: "score>=80", want: Threshold{Expression: "score>=80", Metric: "score", Operator: ">=", Value: 80, kind: numberMetric}},
+		{expr: "idmismatch==0", want: Threshold{Expression: "idmismatch==0", Metric: "idmismatch", Operator: "==", Value: 0, kind: numberMetric}},
+		{expr: "p99", wantErr: true},
+		{expr: "p101<1s", wantErr: true},
+		{expr: "latency<1s", wantErr: true},
+		{expr: "p99<50", wantErr: true},
+		{expr: "foo_ratio<1%", wantErr: true},
+		{expr: "qps>=many", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseThreshold(tt.expr)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	hist := hdrhistogram.New(0, time.Second.Nanoseconds(), 3)
+	for _, d := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 40 * time.Millisecond} {
+		require.NoError(t, hist.RecordValue(d.Nanoseconds()))
+	}
+	stats := BenchmarkResultStats{
+		Hist:     hist,
+		Counters: dnsbench.Counters{Total: 1000, Success: 990, IOError: 10},
+	}
+	thresholds, err := ParseThresholds([]string{"p50<50ms", "min>10ms", "ioerror_ratio<0.1%", "ioerror_ratio<=1%", "qps>=100", "total==1000", "score>=80"})
+	require.NoError(t, err)
+
+	got := EvaluateThresholds(stats, 2*time.Second, thresholds)
+
+	require.Len(t, got, 7)
+	assert.Equal(t, ThresholdResult{Threshold: "p50<50ms", Actual: "20ms", Passed: true, Explanation: "p50 20ms < 50ms"}, got[0])
+	assert.Equal(t, ThresholdResult{Threshold: "min>10ms", Actual: "9.99ms", Passed: false, Explanation: "min 9.99ms is not > 10ms"}, got[1])
+	assert.Equal(t, ThresholdResult{Threshold: "ioerror_ratio<0.1%", Actual: "1%", Passed: false, Explanation: "ioerror_ratio 1% is not < 0.1%"}, got[2])
+	assert.True(t, got[3].Passed)
+	assert.Equal(t, ThresholdResult{Threshold: "qps>=100", Actual: "500", Passed: true, Explanation: "qps 500 >= 100"}, got[4])
+	assert.True(t, got[5].Passed)
+	assert.True(t, got[6].Passed, got[6].Explanation)
+	assert.False(t, ThresholdsPassed(got))
+	assert.True(t, ThresholdsPassed(got[3:]))
+}
+
+func TestEvaluateThresholds_noSuccessfulResponses(t *testing.T) {
+	stats := BenchmarkResultStats{
+		Hist:     hdrhistogram.New(0, time.Second.Nanoseconds(), 3),
+		Counters: dnsbench.Counters{Total: 100, IOError: 100},
+	}
+	thresholds, err := ParseThresholds([]string{"p99<50ms", "mean<10ms", "score>=0", "ioerror_ratio<=100%"})
+	require.NoError(t, err)
+
+	got := EvaluateThresholds(stats, time.Second, thresholds)
+
+	require.Len(t, got, 4)
+	assert.Equal(t, ThresholdResult{Threshold: "p99<50ms", Actual: "n/a", Passed: false, Explanation: "p99 cannot be evaluated, no successful responses"}, got[0])
+	assert.False(t, got[1].Passed)
+	assert.Equal(t, ThresholdResult{Threshold: "score>=0", Actual: "n/a", Passed: false, Explanation: "score cannot be evaluated, no successful responses"}, got[2])
+	assert.True(t, got[3].Passed)
+	assert.False(t, ThresholdsPassed(got))
+}
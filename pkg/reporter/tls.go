@@ -0,0 +1,95 @@
+package reporter
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/printutils"
+)
+
+// certExpiryWarningPeriod is a period before the certificate expiry, when the certificate is reported as expiring soon.
+const certExpiryWarningPeriod = 30 * 24 * time.Hour
+
+type tlsSummary struct {
+	Connections  int64                `json:"connections"`
+	Versions     map[string]int64     `json:"versions"`
+	CipherSuites map[string]int64     `json:"cipherSuites"`
+	ALPN         map[string]int64     `json:"alpn,omitempty"`
+	OCSPStapled  int64                `json:"ocspStapled"`
+	Resumed      int64                `json:"resumed"`
+	Certificates []certificateSummary `json:"certificates,omitempty"`
+}
+
+type certificateSummary struct {
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	NotAfter      time.Time `json:"notAfter"`
+	ExpiresInDays int64     `json:"expiresInDays"`
+	ExpiringSoon  bool      `json:"expiringSoon"`
+}
+
+// summarizeTLS summarizes TLS handshakes, nil is returned if there were no TLS handshakes.
+func summarizeTLS(st *dnsbench.TLSStats, now time.Time) *tlsSummary {
+	if st == nil || st.Handshakes == 0 {
+		return nil
+	}
+	summary := tlsSummary{
+		Connections:  st.Handshakes,
+		Versions:     st.Versions,
+		CipherSuites: st.CipherSuites,
+		ALPN:         st.ALPN,
+		OCSPStapled:  st.OCSPStapled,
+		Resumed:      st.Resumed,
+	}
+	for _, c := range st.Certificates {
+		expiresIn := c.NotAfter.Sub(now)
+		summary.Certificates = append(summary.Certificates, certificateSummary{
+			Subject:       c.Subject,
+			Issuer:        c.Issuer,
+			NotAfter:      c.NotAfter,
+			ExpiresInDays: int64(expiresIn / (24 * time.Hour)),
+			ExpiringSoon:  expiresIn < certExpiryWarningPeriod,
+		})
+	}
+	return &summary
+}
+
+func printTLSSummary(w io.Writer, summary *tlsSummary) {
+	printutils.NeutralFprintf(w, "\nTLS connections:\t%s\n", printutils.HighlightSprint(summary.Connections))
+	printCounts(w, "TLS versions", summary.Versions)
+	printCounts(w, "TLS cipher suites", summary.CipherSuites)
+	printCounts(w, "TLS ALPN", summary.ALPN)
+	printutils.NeutralFprintf(w, "OCSP stapled:\t%s\n", printutils.HighlightSprint(summary.OCSPStapled))
+	printutils.NeutralFprintf(w, "TLS sessions resumed:\t%s\n", printutils.HighlightSprint(summary.Resumed))
+
+	if len(summary.Certificates) > 0 {
+		printutils.NeutralFprintf(w, "Server certificates:\n")
+		for _, c := range summary.Certificates {
+			printutils.NeutralFprintf(w, "\t%s\n", c.Subject)
+			printutils.NeutralFprintf(w, "\t\tissuer:\t%s\n", c.Issuer)
+			if c.ExpiringSoon {
+				printutils.ErrFprintf(w, "\t\texpires:\t%s (in %d days, expires soon!)\n", c.NotAfter.Format(time.DateOnly), c.ExpiresInDays)
+			} else {
+				printutils.NeutralFprintf(w, "\t\texpires:\t%s (in %d days)\n", c.NotAfter.Format(time.DateOnly), c.ExpiresInDays)
+			}
+		}
+	}
+}
+
+func printCounts(w io.Writer, title string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	printutils.NeutralFprintf(w, "%s:\n", title)
+	for _, k := range keys {
+		printutils.NeutralFprintf(w, "\t%s:\t%d\n", k, counts[k])
+	}
+}
@@ -0,0 +1,54 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+)
+
+func Test_summarizeTLS(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaf := dnsbench.CertificateInfo{Subject: "CN=dns.example", Issuer: "CN=Example CA", NotAfter: now.Add(10 * 24 * time.Hour)}
+	ca := dnsbench.CertificateInfo{Subject: "CN=Example CA", Issuer: "CN=Example CA", NotAfter: now.Add(365 * 24 * time.Hour)}
+
+	tests := []struct {
+		name  string
+		stats *dnsbench.TLSStats
+		want  *tlsSummary
+	}{
+		{
+			name: "no handshakes",
+		},
+		{
+			name: "handshakes",
+			stats: &dnsbench.TLSStats{
+				Handshakes:   3,
+				Versions:     map[string]int64{"TLS 1.3": 2, "TLS 1.2": 1},
+				CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 2, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 1},
+				ALPN:         map[string]int64{"h2": 2},
+				OCSPStapled:  1,
+				Resumed:      1,
+				Certificates: []dnsbench.CertificateInfo{leaf, ca},
+			},
+			want: &tlsSummary{
+				Connections:  3,
+				Versions:     map[string]int64{"TLS 1.3": 2, "TLS 1.2": 1},
+				CipherSuites: map[string]int64{"TLS_AES_128_GCM_SHA256": 2, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": 1},
+				ALPN:         map[string]int64{"h2": 2},
+				OCSPStapled:  1,
+				Resumed:      1,
+				Certificates: []certificateSummary{
+					{Subject: "CN=dns.example", Issuer: "CN=Example CA", NotAfter: leaf.NotAfter, ExpiresInDays: 10, ExpiringSoon: true},
+					{Subject: "CN=Example CA", Issuer: "CN=Example CA", NotAfter: ca.NotAfter, ExpiresInDays: 365},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeTLS(tt.stats, now))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package scoring
 
 import (
 	"math"
+	"sort"
 )
 
 // ScoreResult represents the scoring breakdown for a DNS server
@@ -12,6 +13,14 @@ type ScoreResult struct {
 	ErrorRate   float64 `json:"errorRate"`
 	Latency     float64 `json:"latency"`
 	QPS         float64 `json:"qps"`
+	// ConfidenceInterval of the total score, it is set only for scores estimated from repeated benchmark runs.
+	ConfidenceInterval *Interval `json:"confidenceInterval,omitempty"`
+}
+
+// Interval represents confidence interval of a score
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
 }
 
 // Scoring configuration constants
@@ -22,7 +31,7 @@ const (
 	QPSScoreWeight         = 5.0
 
 	LatencyRangeMax      = 1000.0 // Above this latency gets 0 points
-	LatencyRangeMin      = 0.1    // Below this latency gets 0 points
+	LatencyRangeMin      = 0.1    // Below this latency gets full points
 	LatencyFullMarkPoint = 50.0   // Below this latency gets full points
 	MaxQPS               = 100.0  // This QPS gets full points
 )
@@ -37,12 +46,12 @@ type BenchmarkMetrics struct {
 	LatencyStats          LatencyMetrics
 }
 
-// LatencyMetrics represents latency statistics
+// LatencyMetrics represents latency statistics in milliseconds, the values are fractional, so sub-millisecond latencies can be distinguished
 type LatencyMetrics struct {
-	MeanMs int64
-	StdMs  int64
-	P50Ms  int64
-	P95Ms  int64
+	MeanMs float64
+	StdMs  float64
+	P50Ms  float64
+	P95Ms  float64
 }
 
 // CalculateScore computes the performance score for a DNS server based on benchmark results
@@ -63,26 +72,24 @@ func CalculateScore(metrics BenchmarkMetrics) ScoreResult {
 
 	// Calculate latency score: considers both mean and median for stability
 	var latencyScore float64
-	meanMs := float64((metrics.LatencyStats.MeanMs + metrics.LatencyStats.P50Ms) / 2)
+	meanMs := (metrics.LatencyStats.MeanMs + metrics.LatencyStats.P50Ms) / 2
 
-	if meanMs < LatencyRangeMin {
-		// Very low latency gets high score, but not perfect to account for measurement accuracy
-		latencyScore = 95.0
-	} else if meanMs > LatencyRangeMax {
+	if meanMs > LatencyRangeMax {
 		// Very high latency gets 0 points
 		latencyScore = 0
 	} else {
-		// Linear scoring between min and max thresholds
-		latencyScore = 100 * (1 - (meanMs-LatencyRangeMin)/(LatencyRangeMax-LatencyRangeMin))
+		// Linear scoring between min and max thresholds, latencies below the min threshold get full points,
+		// so the lower latency never gets lower score
+		latencyScore = 100 * (1 - (math.Max(meanMs, LatencyRangeMin)-LatencyRangeMin)/(LatencyRangeMax-LatencyRangeMin))
 		latencyScore = math.Max(0, math.Min(100, latencyScore))
 
 		// Penalize for high standard deviation (instability) only if we have meaningful data
 		if meanMs > 0 {
-			stdPenalty := float64(metrics.LatencyStats.StdMs) / meanMs * 5 // Reduced penalty
+			stdPenalty := metrics.LatencyStats.StdMs / meanMs * 5 // Reduced penalty
 			latencyScore = math.Max(0, latencyScore-stdPenalty)
 		}
 	} // Further penalize if P95 latency is very high
-	if metrics.LatencyStats.P95Ms > int64(LatencyRangeMax) {
+	if metrics.LatencyStats.P95Ms > LatencyRangeMax {
 		latencyScore *= 0.7 // Reduce score for instability
 	}
 
@@ -105,7 +112,10 @@ func CalculateScore(metrics BenchmarkMetrics) ScoreResult {
 	}
 }
 
-// RankServers sorts DNS servers by their total score in descending order
+// RankServers sorts DNS servers by their total score in descending order. Server is tied with its neighbour in the order, when they
+// have the same total score or overlapping confidence intervals of the score. The ties are chained, so the servers A, B and C are tied
+// together, when A is tied with B and B is tied with C, even if A and C do not overlap. The tied servers share the rank of the best
+// server of the tie (e.g. 1, 2, 2, 4).
 func RankServers(servers map[string]ScoreResult) []ServerRank {
 	var rankings []ServerRank
 
@@ -116,20 +126,43 @@ func RankServers(servers map[string]ScoreResult) []ServerRank {
 		})
 	}
 
-	// Sort by total score in descending order
-	for i := 0; i < len(rankings); i++ {
-		for j := i + 1; j < len(rankings); j++ {
-			if rankings[i].Score.Total < rankings[j].Score.Total {
-				rankings[i], rankings[j] = rankings[j], rankings[i]
-			}
+	// Sort by total score in descending order, servers with the same score are sorted by name, so the ranking is stable
+	sort.Slice(rankings, func(i, j int) bool {
+		if rankings[i].Score.Total != rankings[j].Score.Total {
+			return rankings[i].Score.Total > rankings[j].Score.Total
 		}
+		return rankings[i].Server < rankings[j].Server
+	})
+
+	for i := range rankings {
+		if i > 0 && tied(rankings[i-1].Score, rankings[i].Score) {
+			rankings[i].Rank = rankings[i-1].Rank
+			rankings[i].Tied = true
+			rankings[i-1].Tied = true
+			continue
+		}
+		rankings[i].Rank = i + 1
 	}
 
 	return rankings
 }
 
+// tied returns true, if the scores are equal or their confidence intervals overlap.
+func tied(a, b ScoreResult) bool {
+	if a.Total == b.Total {
+		return true
+	}
+	if a.ConfidenceInterval == nil || b.ConfidenceInterval == nil {
+		return false
+	}
+	return a.ConfidenceInterval.Low <= b.ConfidenceInterval.High && b.ConfidenceInterval.Low <= a.ConfidenceInterval.High
+}
+
 // ServerRank represents a server and its score for ranking
 type ServerRank struct {
 	Server string      `json:"server"`
 	Score  ScoreResult `json:"score"`
+	// Rank is the position of the server in the ranking starting from 1, tied servers have the same rank.
+	Rank int  `json:"rank"`
+	Tied bool `json:"tied,omitempty"`
 }
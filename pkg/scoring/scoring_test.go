@@ -0,0 +1,122 @@
+package scoring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateScore(t *testing.T) {
+	metrics := BenchmarkMetrics{
+		TotalRequests:         100,
+		TotalSuccessResponses: 90,
+		TotalErrorResponses:   5,
+		TotalIOErrors:         5,
+		QueriesPerSecond:      100,
+		LatencyStats:          LatencyMetrics{MeanMs: 100.09, P50Ms: 100.09, P95Ms: 200},
+	}
+
+	got := CalculateScore(metrics)
+
+	assert.InDelta(t, 90, got.SuccessRate, 1e-9)
+	assert.InDelta(t, 90, got.ErrorRate, 1e-9)
+	assert.InDelta(t, 90, got.Latency, 1e-6)
+	assert.InDelta(t, 100, got.QPS, 1e-9)
+	assert.InDelta(t, (90*SuccessRateScoreWeight+90*ErrorRateScoreWeight+90*LatencyScoreWeight+100*QPSScoreWeight)/100, got.Total, 1e-6)
+	assert.Nil(t, got.ConfidenceInterval)
+}
+
+func TestCalculateScore_noSuccessfulResponses(t *testing.T) {
+	assert.Equal(t, ScoreResult{}, CalculateScore(BenchmarkMetrics{TotalRequests: 10, TotalIOErrors: 10}))
+}
+
+func TestCalculateScore_latencyMonotonic(t *testing.T) {
+	latencyScore := func(ms float64) float64 {
+		return CalculateScore(BenchmarkMetrics{
+			TotalRequests:         1,
+			TotalSuccessResponses: 1,
+			LatencyStats:          LatencyMetrics{MeanMs: ms, P50Ms: ms, P95Ms: ms},
+		}).Latency
+	}
+
+	assert.InDelta(t, 100, latencyScore(0.01), 1e-9)
+	assert.InDelta(t, 100, latencyScore(LatencyRangeMin), 1e-9)
+	assert.Zero(t, latencyScore(LatencyRangeMax))
+	assert.Zero(t, latencyScore(2*LatencyRangeMax))
+
+	// sub-millisecond latencies measured on localhost or LAN are ordered as well
+	latencies := []float64{0.01, 0.05, 0.1, 0.2, 0.35, 1, 10, 500, 999.9}
+	for i := 1; i < len(latencies); i++ {
+		assert.GreaterOrEqual(t, latencyScore(latencies[i-1]), latencyScore(latencies[i]), "%vms vs %vms", latencies[i-1], latencies[i])
+	}
+	assert.Greater(t, latencyScore(0.1), latencyScore(0.2))
+}
+
+func TestRankServers(t *testing.T) {
+	tests := []struct {
+		name      string
+		servers   map[string]ScoreResult
+		wantOrder []string
+		wantRanks []int
+		wantTied  []bool
+	}{
+		{
+			name:      "without confidence intervals",
+			servers:   map[string]ScoreResult{"a": {Total: 90}, "b": {Total: 80}, "c": {Total: 80}, "d": {Total: 70}},
+			wantOrder: []string{"a", "b", "c", "d"},
+			wantRanks: []int{1, 2, 2, 4},
+			wantTied:  []bool{false, true, true, false},
+		},
+		{
+			name: "overlapping confidence intervals",
+			servers: map[string]ScoreResult{
+				"a": {Total: 90, ConfidenceInterval: &Interval{Low: 89, High: 91}},
+				"b": {Total: 88, ConfidenceInterval: &Interval{Low: 87, High: 89.5}},
+				"c": {Total: 80, ConfidenceInterval: &Interval{Low: 79, High: 81}},
+			},
+			wantOrder: []string{"a", "b", "c"},
+			wantRanks: []int{1, 1, 3},
+			wantTied:  []bool{true, true, false},
+		},
+		{
+			name: "chained ties",
+			// a overlaps with b and b overlaps with c, but a does not overlap with c
+			servers: map[string]ScoreResult{
+				"a": {Total: 90, ConfidenceInterval: &Interval{Low: 88, High: 92}},
+				"b": {Total: 87, ConfidenceInterval: &Interval{Low: 85, High: 89}},
+				"c": {Total: 84, ConfidenceInterval: &Interval{Low: 82, High: 86}},
+				"d": {Total: 70, ConfidenceInterval: &Interval{Low: 69, High: 71}},
+			},
+			wantOrder: []string{"a", "b", "c", "d"},
+			wantRanks: []int{1, 1, 1, 4},
+			wantTied:  []bool{true, true, true, false},
+		},
+		{
+			name: "mixed with and without confidence intervals",
+			servers: map[string]ScoreResult{
+				"a": {Total: 90, ConfidenceInterval: &Interval{Low: 85, High: 95}},
+				"b": {Total: 88},
+			},
+			wantOrder: []string{"a", "b"},
+			wantRanks: []int{1, 2},
+			wantTied:  []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RankServers(tt.servers)
+
+			var order []string
+			var ranks []int
+			var ties []bool
+			for _, r := range got {
+				order = append(order, r.Server)
+				ranks = append(ranks, r.Rank)
+				ties = append(ties, r.Tied)
+			}
+			assert.Equal(t, tt.wantOrder, order)
+			assert.Equal(t, tt.wantRanks, ranks)
+			assert.Equal(t, tt.wantTied, ties)
+		})
+	}
+}
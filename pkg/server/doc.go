@@ -0,0 +1,4 @@
+// Package server contains configurable local DNS responder, which can be used for validating benchmark setups
+// without touching real resolvers. The responder serves plain DNS over UDP and TCP, DoT, DoH and DoQ and supports
+// programmable latency, error injection, truncation and zone data.
+package server
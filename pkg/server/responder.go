@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultTTL is TTL of the synthesized records.
+const defaultTTL = 60
+
+// Config configures behaviour of the Server.
+type Config struct {
+	// Addr is the address of plain DNS listeners (both UDP and TCP), plain DNS is disabled when empty.
+	Addr string
+	// DoTAddr is the address of DoT listener, DoT is disabled when empty.
+	DoTAddr string
+	// DoHAddr is the address of DoH listener serving /dns-query path over HTTPS, DoH is disabled when empty.
+	DoHAddr string
+	// DoQAddr is the address of DoQ listener, DoQ is disabled when empty.
+	DoQAddr string
+	// TLSCert and TLSKey are paths to the certificate and key used by DoT, DoH and DoQ. When empty, self-signed
+	// certificate for localhost is generated.
+	TLSCert string
+	TLSKey  string
+
+	// Latency is added to each response.
+	Latency time.Duration
+	// Jitter is maximum random latency added to each response on top of Latency.
+	Jitter time.Duration
+	// ErrorRate is the fraction of queries answered with ErrorRcode.
+	ErrorRate float64
+	// ErrorRcode is the response code of the injected errors, SERVFAIL is used when 0.
+	ErrorRcode int
+	// DropRate is the fraction of queries, which are not answered at all.
+	DropRate float64
+	// TruncateRate is the fraction of UDP queries answered with empty truncated response. UDP responses larger than
+	// the buffer size announced by the client are always truncated.
+	TruncateRate float64
+
+	// ZoneFile is path to the zone file in RFC 1035 format, the queries are answered from the zone data.
+	// When empty, A and AAAA records pointing to localhost are synthesized for any name.
+	ZoneFile string
+}
+
+// responder creates responses to the queries according to the Config.
+type responder struct {
+	config Config
+	// zone contains records of the zone file by the owner name and type, it is nil when the zone file is not used.
+	zone map[string]map[uint16][]dns.RR
+	// soa is the SOA record of the zone file added to the authority section of negative responses, nil when the zone file
+	// does not contain SOA record.
+	soa *dns.SOA
+}
+
+func newResponder(config Config) (*responder, error) {
+	if config.ErrorRcode == 0 {
+		config.ErrorRcode = dns.RcodeServerFailure
+	}
+	for name, rate := range map[string]float64{"error rate": config.ErrorRate, "drop rate": config.DropRate, "truncate rate": config.TruncateRate} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s %v is not in the interval [0, 1]", name, rate)
+		}
+	}
+	r := &responder{config: config}
+	if len(config.ZoneFile) != 0 {
+		zone, soa, err := loadZone(config.ZoneFile)
+		if err != nil {
+			return nil, err
+		}
+		r.zone = zone
+		r.soa = soa
+	}
+	return r, nil
+}
+
+// loadZone loads records of the zone file, the first SOA record of the file is returned as well.
+func loadZone(path string) (map[string]map[uint16][]dns.RR, *dns.SOA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open zone file: %w", err)
+	}
+	defer f.Close()
+
+	zone := make(map[string]map[uint16][]dns.RR)
+	var soa *dns.SOA
+	zp := dns.NewZoneParser(f, "", path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if s, isSOA := rr.(*dns.SOA); isSOA && soa == nil {
+			soa = s
+		}
+		name := strings.ToLower(rr.Header().Name)
+		if zone[name] == nil {
+			zone[name] = make(map[uint16][]dns.RR)
+		}
+		zone[name][rr.Header().Rrtype] = append(zone[name][rr.Header().Rrtype], rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse zone file: %w", err)
+	}
+	return zone, soa, nil
+}
+
+// respond returns response to the query, nil is returned when the query should not be answered. udp controls
+// whether the response is sent over UDP, so it can be truncated.
+func (r *responder) respond(req *dns.Msg, udp bool) *dns.Msg {
+	// nolint:gosec
+	if r.config.DropRate > 0 && rand.Float64() < r.config.DropRate {
+		return nil
+	}
+	r.delay()
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = r.zone != nil
+	if req.IsEdns0() != nil {
+		resp.SetEdns0(dns.DefaultMsgSize, false)
+	}
+
+	// nolint:gosec
+	if r.config.ErrorRate > 0 && rand.Float64() < r.config.ErrorRate {
+		resp.Rcode = r.config.ErrorRcode
+		return resp
+	}
+	if len(req.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+
+	r.answer(req.Question[0], resp)
+
+	if udp {
+		// nolint:gosec
+		if r.config.TruncateRate > 0 && rand.Float64() < r.config.TruncateRate {
+			resp.Answer, resp.Ns, resp.Extra = nil, nil, nil
+			resp.Truncated = true
+			return resp
+		}
+		size := dns.MinMsgSize
+		if o := req.IsEdns0(); o != nil {
+			size = int(o.UDPSize())
+		}
+		resp.Truncate(size)
+	}
+	return resp
+}
+
+func (r *responder) delay() {
+	d := r.config.Latency
+	if r.config.Jitter > 0 {
+		// nolint:gosec
+		d += time.Duration(rand.Int63n(int64(r.config.Jitter)))
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// answer fills the answer of the response to the question either from the zone data or using synthesized records.
+func (r *responder) answer(q dns.Question, resp *dns.Msg) {
+	if r.zone == nil {
+		var rr dns.RR
+		switch q.Qtype {
+		case dns.TypeA:
+			rr = &dns.A{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: defaultTTL}, A: []byte{127, 0, 0, 1}}
+		case dns.TypeAAAA:
+			rr = &dns.AAAA{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: defaultTTL}, AAAA: make([]byte, 16)}
+			rr.(*dns.AAAA).AAAA[15] = 1
+		}
+		if rr != nil {
+			resp.Answer = append(resp.Answer, rr)
+		}
+		return
+	}
+
+	records, ok := r.zone[strings.ToLower(q.Name)]
+	if !ok {
+		resp.Rcode = dns.RcodeNameError
+		r.addNegativeSOA(resp)
+		return
+	}
+	if rrs, ok := records[q.Qtype]; ok {
+		resp.Answer = append(resp.Answer, rrs...)
+		return
+	}
+	if cname, ok := records[dns.TypeCNAME]; ok {
+		resp.Answer = append(resp.Answer, cname...)
+		return
+	}
+	// otherwise NODATA response
+	r.addNegativeSOA(resp)
+}
+
+// addNegativeSOA adds the SOA record of the zone to the authority section of NXDOMAIN or NODATA response, so the response
+// can be cached by the resolvers. The TTL of the record is the negative caching TTL, see https://www.rfc-editor.org/rfc/rfc2308#section-3.
+func (r *responder) addNegativeSOA(resp *dns.Msg) {
+	if r.soa == nil {
+		return
+	}
+	soa := dns.Copy(r.soa).(*dns.SOA)
+	soa.Hdr.Ttl = min(soa.Hdr.Ttl, soa.Minttl)
+	resp.Ns = append(resp.Ns, soa)
+}
+
+// ServeDNS implements dns.Handler.
+func (r *responder) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	_, udp := w.RemoteAddr().(*net.UDPAddr)
+	resp := r.respond(req, udp)
+	if resp == nil {
+		return
+	}
+	_ = w.WriteMsg(resp)
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// dohMediaType is the media type of DNS messages sent over DoH.
+const dohMediaType = "application/dns-message"
+
+// Server is a local DNS responder configured by Config.
+type Server struct {
+	// Addr, TCPAddr, DoTAddr, DoHAddr and DoQAddr are the actual addresses of the listeners, they are set by Start.
+	Addr    string
+	TCPAddr string
+	DoTAddr string
+	DoHAddr string
+	DoQAddr string
+
+	config       Config
+	responder    *responder
+	dnsServers   []*dns.Server
+	httpServer   *http.Server
+	quicListener *quic.Listener
+}
+
+// New creates new Server, the server has to be started using Start.
+func New(config Config) (*Server, error) {
+	if len(config.Addr) == 0 && len(config.DoTAddr) == 0 && len(config.DoHAddr) == 0 && len(config.DoQAddr) == 0 {
+		return nil, errors.New("at least one listener address has to be configured")
+	}
+	r, err := newResponder(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{config: config, responder: r}, nil
+}
+
+// Start starts all the configured listeners, the listeners serve the queries in the background until Close is called.
+func (s *Server) Start() error {
+	if err := s.start(); err != nil {
+		_ = s.Close()
+		return err
+	}
+	return nil
+}
+
+func (s *Server) start() error {
+	if len(s.config.Addr) != 0 {
+		pc, err := net.ListenPacket("udp", s.config.Addr)
+		if err != nil {
+			return err
+		}
+		s.Addr = pc.LocalAddr().String()
+		s.serveDNS(&dns.Server{PacketConn: pc, Net: "udp", Handler: s.responder})
+
+		// TCP listener uses the same port as UDP listener, so the port chosen by the OS is shared
+		l, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			return err
+		}
+		s.TCPAddr = l.Addr().String()
+		s.serveDNS(&dns.Server{Listener: l, Net: "tcp", Handler: s.responder})
+	}
+
+	if len(s.config.DoTAddr) == 0 && len(s.config.DoHAddr) == 0 && len(s.config.DoQAddr) == 0 {
+		return nil
+	}
+	cert, err := s.config.certificate()
+	if err != nil {
+		return err
+	}
+
+	if len(s.config.DoTAddr) != 0 {
+		l, err := tls.Listen("tcp", s.config.DoTAddr, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+		if err != nil {
+			return err
+		}
+		s.DoTAddr = l.Addr().String()
+		s.serveDNS(&dns.Server{Listener: l, Net: "tcp-tls", Handler: s.responder})
+	}
+
+	if len(s.config.DoHAddr) != 0 {
+		l, err := net.Listen("tcp", s.config.DoHAddr)
+		if err != nil {
+			return err
+		}
+		s.DoHAddr = l.Addr().String()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/dns-query", s.serveDoH)
+		// nolint:gosec
+		s.httpServer = &http.Server{
+			Handler:   mux,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		}
+		go func() {
+			_ = s.httpServer.ServeTLS(l, "", "")
+		}()
+	}
+
+	if len(s.config.DoQAddr) != 0 {
+		l, err := quic.ListenAddr(s.config.DoQAddr, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"doq"},
+			MinVersion:   tls.VersionTLS13,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		s.DoQAddr = l.Addr().String()
+		s.quicListener = l
+		go s.serveDoQ(l)
+	}
+	return nil
+}
+
+func (s *Server) serveDNS(server *dns.Server) {
+	s.dnsServers = append(s.dnsServers, server)
+	started := make(chan struct{})
+	server.NotifyStartedFunc = func() { close(started) }
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	// the server can be shut down only after it is started
+	<-started
+}
+
+// Close stops all the listeners of the server.
+func (s *Server) Close() error {
+	var errs []error
+	for _, server := range s.dnsServers {
+		errs = append(errs, server.Shutdown())
+	}
+	if s.httpServer != nil {
+		errs = append(errs, s.httpServer.Close())
+	}
+	if s.quicListener != nil {
+		errs = append(errs, s.quicListener.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) serveDoH(w http.ResponseWriter, r *http.Request) {
+	var packed []byte
+	switch r.Method {
+	case http.MethodGet:
+		var err error
+		packed, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil {
+			http.Error(w, "dns parameter is not base64url encoded", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != dohMediaType {
+			http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var err error
+		packed, err = io.ReadAll(io.LimitReader(r.Body, dns.MaxMsgSize))
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := new(dns.Msg)
+	if err := req.Unpack(packed); err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse DNS message: %v", err), http.StatusBadRequest)
+		return
+	}
+	resp := s.responder.respond(req, false)
+	if resp == nil {
+		// dropped query, the client times out
+		<-r.Context().Done()
+		return
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		http.Error(w, "failed to create DNS response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", dohMediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(packed)))
+	_, _ = w.Write(packed)
+}
+
+func (s *Server) serveDoQ(l *quic.Listener) {
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			return
+		}
+		go func() {
+			for {
+				stream, err := conn.AcceptStream(context.Background())
+				if err != nil {
+					return
+				}
+				go s.serveDoQStream(stream)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveDoQStream(stream quic.Stream) {
+	// DNS messages sent over DoQ are prefixed by 2-octet length field, see https://www.rfc-editor.org/rfc/rfc9250.html#section-4.2
+	var size uint16
+	if err := binary.Read(stream, binary.BigEndian, &size); err != nil {
+		stream.CancelRead(0)
+		return
+	}
+	packed := make([]byte, size)
+	if _, err := io.ReadFull(stream, packed); err != nil {
+		stream.CancelRead(0)
+		return
+	}
+	req := new(dns.Msg)
+	if err := req.Unpack(packed); err != nil {
+		stream.CancelRead(0)
+		stream.CancelWrite(0)
+		return
+	}
+
+	resp := s.responder.respond(req, false)
+	if resp == nil {
+		// dropped query, the stream is cancelled, so it does not stay open until the connection is closed
+		stream.CancelRead(0)
+		stream.CancelWrite(0)
+		return
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		stream.CancelWrite(0)
+		return
+	}
+	_, _ = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+	_, _ = stream.Write(packed)
+	_ = stream.Close()
+}
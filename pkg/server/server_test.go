@@ -0,0 +1,248 @@
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tantalor93/dnspyre/v3/pkg/dnsbench"
+	"github.com/tantalor93/dnspyre/v3/pkg/server"
+)
+
+func startServer(t *testing.T, config server.Config) *server.Server {
+	t.Helper()
+	s, err := server.New(config)
+	require.NoError(t, err)
+	require.NoError(t, s.Start())
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+func TestServer_synthesized(t *testing.T) {
+	s := startServer(t, server.Config{Addr: "127.0.0.1:0"})
+
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			addr := s.Addr
+			if network == "tcp" {
+				addr = s.TCPAddr
+			}
+			c := dns.Client{Net: network}
+
+			m := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+			resp, _, err := c.Exchange(m, addr)
+			require.NoError(t, err)
+			require.Len(t, resp.Answer, 1)
+			assert.Equal(t, "127.0.0.1", resp.Answer[0].(*dns.A).A.String())
+
+			m = new(dns.Msg).SetQuestion("example.org.", dns.TypeAAAA)
+			resp, _, err = c.Exchange(m, addr)
+			require.NoError(t, err)
+			require.Len(t, resp.Answer, 1)
+			assert.Equal(t, "::1", resp.Answer[0].(*dns.AAAA).AAAA.String())
+		})
+	}
+}
+
+func TestServer_zone(t *testing.T) {
+	zoneFile := filepath.Join(t.TempDir(), "example.org.zone")
+	require.NoError(t, os.WriteFile(zoneFile, []byte(`$ORIGIN example.org.
+$TTL 300
+@	IN	SOA	ns.example.org. admin.example.org. 1 3600 600 86400 60
+@	IN	A	192.0.2.1
+www	IN	CNAME	example.org.
+`), 0o600))
+
+	s := startServer(t, server.Config{Addr: "127.0.0.1:0", ZoneFile: zoneFile})
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		wantRcode int
+		wantTypes []uint16
+		wantSOA   bool
+	}{
+		{name: "answer", qname: "example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeSuccess, wantTypes: []uint16{dns.TypeA}},
+		{name: "NODATA", qname: "example.org.", qtype: dns.TypeAAAA, wantRcode: dns.RcodeSuccess, wantSOA: true},
+		{name: "CNAME", qname: "WWW.example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeSuccess, wantTypes: []uint16{dns.TypeCNAME}},
+		{name: "NXDOMAIN", qname: "missing.example.org.", qtype: dns.TypeA, wantRcode: dns.RcodeNameError, wantSOA: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := dns.Exchange(new(dns.Msg).SetQuestion(tt.qname, tt.qtype), s.Addr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRcode, resp.Rcode)
+			assert.True(t, resp.Authoritative)
+			var types []uint16
+			for _, rr := range resp.Answer {
+				types = append(types, rr.Header().Rrtype)
+			}
+			assert.Equal(t, tt.wantTypes, types)
+			if !tt.wantSOA {
+				assert.Empty(t, resp.Ns)
+				return
+			}
+			require.Len(t, resp.Ns, 1)
+			soa, ok := resp.Ns[0].(*dns.SOA)
+			require.True(t, ok, "authority section should contain SOA record")
+			assert.Equal(t, "example.org.", soa.Hdr.Name)
+			assert.Equal(t, uint32(60), soa.Hdr.Ttl, "TTL should be the negative caching TTL")
+		})
+	}
+}
+
+func TestServer_faults(t *testing.T) {
+	tests := []struct {
+		name   string
+		config server.Config
+		check  func(t *testing.T, resp *dns.Msg, rtt time.Duration, err error)
+	}{
+		{
+			name:   "errors",
+			config: server.Config{ErrorRate: 1, ErrorRcode: dns.RcodeRefused},
+			check: func(t *testing.T, resp *dns.Msg, _ time.Duration, err error) {
+				require.NoError(t, err)
+				assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+			},
+		},
+		{
+			name:   "truncation",
+			config: server.Config{TruncateRate: 1},
+			check: func(t *testing.T, resp *dns.Msg, _ time.Duration, err error) {
+				require.NoError(t, err)
+				assert.True(t, resp.Truncated)
+				assert.Empty(t, resp.Answer)
+			},
+		},
+		{
+			name:   "drops",
+			config: server.Config{DropRate: 1},
+			check: func(t *testing.T, _ *dns.Msg, _ time.Duration, err error) {
+				assert.Error(t, err)
+			},
+		},
+		{
+			name:   "latency",
+			config: server.Config{Latency: 100 * time.Millisecond, Jitter: 10 * time.Millisecond},
+			check: func(t *testing.T, _ *dns.Msg, rtt time.Duration, err error) {
+				require.NoError(t, err)
+				assert.GreaterOrEqual(t, rtt, 100*time.Millisecond)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Addr = "127.0.0.1:0"
+			s := startServer(t, tt.config)
+
+			c := dns.Client{Timeout: 500 * time.Millisecond}
+			resp, rtt, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), s.Addr)
+			tt.check(t, resp, rtt, err)
+		})
+	}
+}
+
+func TestServer_doqDropCancelsStream(t *testing.T) {
+	s := startServer(t, server.Config{DoQAddr: "127.0.0.1:0", DropRate: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := quic.DialAddr(ctx, s.DoQAddr, &tls.Config{NextProtos: []string{"doq"}, InsecureSkipVerify: true}, nil) // nolint:gosec
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+
+	stream, err := conn.OpenStreamSync(ctx)
+	require.NoError(t, err)
+	packed, err := new(dns.Msg).SetQuestion("example.org.", dns.TypeA).Pack()
+	require.NoError(t, err)
+	_, err = stream.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+	require.NoError(t, err)
+	_, err = stream.Write(packed)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+
+	require.NoError(t, stream.SetReadDeadline(time.Now().Add(3*time.Second)))
+	_, err = io.ReadAll(stream)
+	var streamErr *quic.StreamError
+	require.ErrorAs(t, err, &streamErr, "dropped query should cancel the stream instead of leaving it open")
+	assert.True(t, streamErr.Remote)
+}
+
+func TestServer_invalidConfig(t *testing.T) {
+	_, err := server.New(server.Config{})
+	require.Error(t, err)
+
+	_, err = server.New(server.Config{Addr: "127.0.0.1:0", DropRate: 2})
+	require.Error(t, err)
+
+	_, err = server.New(server.Config{Addr: "127.0.0.1:0", ZoneFile: "missing.zone"})
+	require.Error(t, err)
+}
+
+func TestServer_benchmark(t *testing.T) {
+	s := startServer(t, server.Config{Addr: "127.0.0.1:0", DoTAddr: "127.0.0.1:0", DoHAddr: "127.0.0.1:0", DoQAddr: "127.0.0.1:0"})
+
+	tests := []struct {
+		name  string
+		bench dnsbench.Benchmark
+	}{
+		{name: "UDP", bench: dnsbench.Benchmark{Server: s.Addr}},
+		{name: "TCP", bench: dnsbench.Benchmark{Server: s.TCPAddr, TCP: true}},
+		{name: "DoT", bench: dnsbench.Benchmark{Server: s.DoTAddr, DOT: true, Insecure: true}},
+		{name: "DoH", bench: dnsbench.Benchmark{Server: "https://" + s.DoHAddr, Insecure: true}},
+		{name: "DoH GET", bench: dnsbench.Benchmark{Server: "https://" + s.DoHAddr, Insecure: true, DohMethod: "get"}},
+		{name: "DoH HTTP/2", bench: dnsbench.Benchmark{Server: "https://" + s.DoHAddr, Insecure: true, DohProtocol: "2"}},
+		{name: "DoQ", bench: dnsbench.Benchmark{Server: "quic://" + s.DoQAddr, Insecure: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bench := tt.bench
+			bench.Queries = []string{"example.org"}
+			bench.Types = []string{"A", "AAAA"}
+			bench.Concurrency = 2
+			bench.Count = 2
+			bench.Probability = 1
+			bench.WriteTimeout = time.Second
+			bench.ReadTimeout = time.Second
+			bench.ConnectTimeout = time.Second
+			bench.RequestTimeout = 2 * time.Second
+			bench.Rcodes = true
+			bench.Silent = true
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			rs, err := bench.Run(ctx)
+			require.NoError(t, err)
+			require.Len(t, rs, 2)
+			for _, r := range rs {
+				assert.EqualValues(t, 4, r.Counters.Success)
+				assert.Zero(t, r.Counters.IOError)
+			}
+		})
+	}
+}
+
+func TestServer_tlsCertificate(t *testing.T) {
+	// certificate is loaded on start
+	s, err := server.New(server.Config{DoTAddr: "127.0.0.1:0", TLSCert: "missing.crt", TLSKey: "missing.key"})
+	require.NoError(t, err)
+	require.Error(t, s.Start())
+
+	s = startServer(t, server.Config{DoTAddr: "127.0.0.1:0"})
+	c := dns.Client{Net: "tcp-tls", TLSConfig: &tls.Config{ServerName: "localhost", InsecureSkipVerify: true}} // nolint:gosec
+	resp, _, err := c.Exchange(new(dns.Msg).SetQuestion("example.org.", dns.TypeA), s.DoTAddr)
+	require.NoError(t, err)
+	assert.Len(t, resp.Answer, 1)
+}